@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/bulkhead/internal/bulkhead"
+)
+
+func main() {
+	log.Println("🚢 Bulkhead Pattern Demonstration")
+	log.Println("=================================")
+
+	log.Println()
+
+	// Demonstrate rejecting calls once the bulkhead is saturated
+	demonstrateRejection()
+
+	log.Println()
+
+	// Demonstrate a slow dependency not starving a fast one
+	demonstrateIsolation()
+
+	log.Println()
+	log.Println("🎉 Bulkhead pattern demonstration complete!")
+}
+
+// slowCall simulates a call to a dependency that takes d to respond
+func slowCall(d time.Duration) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(d):
+			return "ok", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func demonstrateRejection() {
+	log.Println("🚫 Saturation Demo")
+	log.Println("------------------")
+
+	// Allow 3 calls in flight and 2 waiting
+	b, err := bulkhead.New(3, 2)
+	if err != nil {
+		log.Fatalf("Failed to create bulkhead: %v", err)
+	}
+
+	ctx := context.Background()
+
+	log.Println("📨 Sending 8 concurrent requests to a slow dependency...")
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			_, err := bulkhead.Do(ctx, b, slowCall(500*time.Millisecond))
+			switch {
+			case errors.Is(err, bulkhead.ErrBulkheadFull):
+				log.Printf("🚫 Request %d rejected immediately - bulkhead full", i)
+			case err != nil:
+				log.Printf("❌ Request %d failed: %v", i, err)
+			default:
+				log.Printf("✅ Request %d completed in %v", i, time.Since(start).Round(100*time.Millisecond))
+			}
+		}(i)
+		// Stagger the requests slightly so the output is ordered
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	log.Printf("🔍 In flight: %d, Queued: %d", b.InFlight(), b.Queued())
+	wg.Wait()
+}
+
+func demonstrateIsolation() {
+	log.Println("🧱 Isolation Demo")
+	log.Println("-----------------")
+
+	// Give each dependency its own bulkhead so a slow one can't consume every goroutine
+	slowBulkhead, err := bulkhead.New(2, 0)
+	if err != nil {
+		log.Fatalf("Failed to create bulkhead: %v", err)
+	}
+	fastBulkhead, err := bulkhead.New(2, 0)
+	if err != nil {
+		log.Fatalf("Failed to create bulkhead: %v", err)
+	}
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := bulkhead.Do(ctx, slowBulkhead, slowCall(time.Second)); err != nil {
+				log.Printf("🐢 Slow request %d rejected: %v", i, err)
+				return
+			}
+			log.Printf("🐢 Slow request %d completed", i)
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 1; i <= 2; i++ {
+		start := time.Now()
+		if _, err := bulkhead.Do(ctx, fastBulkhead, slowCall(50*time.Millisecond)); err != nil {
+			log.Printf("❌ Fast request %d failed: %v", i, err)
+			continue
+		}
+		log.Printf("⚡ Fast request %d completed in %v while the slow dependency is saturated", i, time.Since(start).Round(10*time.Millisecond))
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,118 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// AdaptiveBulkhead limits concurrent calls like a bulkhead, but adjusts its limit to the dependency's latency.
+// Every call slower than the target latency halves the limit and every other call raises it by one (AIMD),
+// always staying within the configured bounds. It rejects rather than queueing once the limit is reached,
+// as waiting callers would only add to the latency it is shedding.
+type AdaptiveBulkhead struct {
+	lock          sync.Mutex
+	clock         clockwork.Clock
+	minLimit      int
+	maxLimit      int
+	targetLatency time.Duration // Calls slower than this shrink the limit
+
+	limit    int // Current concurrency limit, between minLimit and maxLimit
+	inFlight int
+}
+
+// AdaptiveOption is a functional option for configuring an adaptive bulkhead
+type AdaptiveOption func(*AdaptiveBulkhead) error
+
+// WithClock sets a custom clock used to measure call latency
+func WithClock(clock clockwork.Clock) AdaptiveOption {
+	return func(b *AdaptiveBulkhead) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		b.clock = clock
+		return nil
+	}
+}
+
+// NewAdaptive creates a new adaptive bulkhead whose limit moves between minConcurrent and maxConcurrent,
+// starting at maxConcurrent, and shrinks whenever a call takes longer than targetLatency
+func NewAdaptive(minConcurrent, maxConcurrent int, targetLatency time.Duration, opts ...AdaptiveOption) (*AdaptiveBulkhead, error) {
+	switch {
+	case minConcurrent <= 0:
+		return nil, errors.New("minConcurrent must be greater than 0")
+	case maxConcurrent < minConcurrent:
+		return nil, errors.New("maxConcurrent must not be less than minConcurrent")
+	case targetLatency <= 0:
+		return nil, errors.New("targetLatency must be greater than 0")
+	}
+
+	b := &AdaptiveBulkhead{
+		clock:         clockwork.NewRealClock(), // Default to real clock
+		minLimit:      minConcurrent,
+		maxLimit:      maxConcurrent,
+		targetLatency: targetLatency,
+		limit:         maxConcurrent,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Do executes fn if the current limit allows, returning ErrBulkheadFull otherwise
+func (b *AdaptiveBulkhead) Do(ctx context.Context, fn func(context.Context) error) error {
+	_, err := Do(ctx, b, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// acquire admits a call if fewer than the current limit are in flight
+func (b *AdaptiveBulkhead) acquire(context.Context) (func(), error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.inFlight >= b.limit {
+		return nil, ErrBulkheadFull
+	}
+	b.inFlight++
+
+	start := b.clock.Now()
+	return func() { b.release(b.clock.Since(start)) }, nil
+}
+
+// release frees the call's slot and adjusts the limit to how long it took
+func (b *AdaptiveBulkhead) release(latency time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.inFlight--
+	if latency > b.targetLatency {
+		b.limit = max(b.minLimit, b.limit/2)
+	} else {
+		b.limit = min(b.maxLimit, b.limit+1)
+	}
+}
+
+// Limit returns the current concurrency limit
+func (b *AdaptiveBulkhead) Limit() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.limit
+}
+
+// InFlight returns the number of calls currently executing
+func (b *AdaptiveBulkhead) InFlight() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.inFlight
+}
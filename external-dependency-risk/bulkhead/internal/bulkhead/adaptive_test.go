@@ -0,0 +1,135 @@
+package bulkhead_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/bulkhead/internal/bulkhead"
+)
+
+func TestNewAdaptive(t *testing.T) {
+	t.Run("invalid min concurrent", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(0, 10, time.Second)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "minConcurrent must be greater than 0")
+	})
+
+	t.Run("max concurrent below min", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(5, 4, time.Second)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "maxConcurrent must not be less than minConcurrent")
+	})
+
+	t.Run("invalid target latency", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(1, 10, 0)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "targetLatency must be greater than 0")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(1, 10, time.Second, bulkhead.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("starts at the max limit", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(1, 10, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, 10, b.Limit())
+	})
+}
+
+func TestAdaptiveBulkhead(t *testing.T) {
+	// call simulates a call taking latency by advancing the fake clock while it's in flight
+	call := func(b *bulkhead.AdaptiveBulkhead, fakeClock *clockwork.FakeClock, latency time.Duration) error {
+		return b.Do(context.Background(), func(context.Context) error {
+			fakeClock.Advance(latency)
+			return nil
+		})
+	}
+
+	t.Run("limit shrinks under high latency then recovers", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := bulkhead.NewAdaptive(2, 16, 100*time.Millisecond, bulkhead.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		// Every slow call halves the limit, down to the minimum
+		for _, want := range []int{8, 4, 2, 2} {
+			require.NoError(t, call(b, fakeClock, 500*time.Millisecond))
+			require.Equal(t, want, b.Limit())
+		}
+
+		// Every fast call raises it by one, up to the maximum
+		for want := 3; want <= 16; want++ {
+			require.NoError(t, call(b, fakeClock, 10*time.Millisecond))
+			require.Equal(t, want, b.Limit())
+		}
+		require.NoError(t, call(b, fakeClock, 10*time.Millisecond))
+		require.Equal(t, 16, b.Limit())
+	})
+
+	t.Run("call at the target latency doesn't shrink the limit", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := bulkhead.NewAdaptive(1, 4, 100*time.Millisecond, bulkhead.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		require.NoError(t, call(b, fakeClock, 100*time.Millisecond))
+		require.Equal(t, 4, b.Limit())
+	})
+
+	t.Run("rejects once the current limit is reached", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := bulkhead.NewAdaptive(1, 4, 100*time.Millisecond, bulkhead.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		// Shrink the limit from 4 to 1
+		require.NoError(t, call(b, fakeClock, time.Second))
+		require.NoError(t, call(b, fakeClock, time.Second))
+		require.Equal(t, 1, b.Limit())
+
+		ctx := context.Background()
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- b.Do(ctx, func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool {
+			return b.InFlight() == 1
+		}, time.Second, time.Millisecond)
+
+		called := false
+		err = b.Do(ctx, func(context.Context) error {
+			called = true
+			return nil
+		})
+		require.ErrorIs(t, err, bulkhead.ErrBulkheadFull)
+		require.False(t, called)
+
+		close(release)
+		require.NoError(t, <-done)
+		require.Zero(t, b.InFlight())
+	})
+
+	t.Run("generic Do returns the result of fn", func(t *testing.T) {
+		b, err := bulkhead.NewAdaptive(1, 4, time.Second, bulkhead.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		res, err := bulkhead.Do(context.Background(), b, func(context.Context) (string, error) {
+			return "ok", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "ok", res)
+		require.Zero(t, b.InFlight())
+	})
+}
@@ -0,0 +1,200 @@
+package bulkhead
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+// defaultAgingInterval is how long a queued caller waits to gain one priority level
+const defaultAgingInterval = time.Second
+
+// limiter admits calls, returning a func that must be called once the admitted call completes
+type limiter interface {
+	acquire(ctx context.Context) (func(), error)
+}
+
+// bulkhead limits the number of concurrent calls, queueing a bounded number of callers
+type bulkhead struct {
+	lock          sync.Mutex
+	maxConcurrent int
+	maxQueue      int
+	agingInterval time.Duration // Wait that raises a queued caller's priority by one
+	clock         clockwork.Clock
+
+	inFlight int
+	waiters  waitQueue // Callers waiting for a slot, next to be admitted first
+	seq      uint64    // Orders waiters with the same rank by arrival
+}
+
+// Option is a functional option for configuring a bulkhead
+type Option func(*bulkhead) error
+
+// WithAging sets how long a queued caller waits to gain one priority level, so low-priority callers
+// aren't deferred indefinitely by a steady stream of higher-priority ones
+func WithAging(interval time.Duration) Option {
+	return func(b *bulkhead) error {
+		if interval <= 0 {
+			return errors.New("aging interval must be greater than 0")
+		}
+		b.agingInterval = interval
+		return nil
+	}
+}
+
+// WithAgingClock sets a custom clock used to measure how long queued callers have waited
+func WithAgingClock(clock clockwork.Clock) Option {
+	return func(b *bulkhead) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		b.clock = clock
+		return nil
+	}
+}
+
+// New creates a new bulkhead allowing maxConcurrent calls in flight and maxQueue callers waiting for a slot
+func New(maxConcurrent, maxQueue int, opts ...Option) (*bulkhead, error) {
+	switch {
+	case maxConcurrent <= 0:
+		return nil, errors.New("maxConcurrent must be greater than 0")
+	case maxQueue < 0:
+		return nil, errors.New("maxQueue must not be negative")
+	}
+
+	b := &bulkhead{
+		maxConcurrent: maxConcurrent,
+		maxQueue:      maxQueue,
+		agingInterval: defaultAgingInterval,
+		clock:         clockwork.NewRealClock(), // Default to real clock
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Do executes fn once a slot is free, returning ErrBulkheadFull if every slot and queue position is taken
+func (b *bulkhead) Do(ctx context.Context, fn func(context.Context) error) error {
+	_, err := Do(ctx, b, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Execute executes fn like Do, but while every slot is taken queued callers are admitted highest priority first
+// rather than in arrival order. Do queues callers at priority 0. A queued caller gains a priority level for every
+// aging interval it waits, so it is eventually admitted ahead of callers that arrived later with a higher priority.
+func (b *bulkhead) Execute(ctx context.Context, priority int, fn func(context.Context) error) error {
+	release, err := b.admit(ctx, priority)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn(ctx)
+}
+
+// Do executes fn within the bulkhead, fixed or adaptive, and returns its result
+func Do[T any](ctx context.Context, b limiter, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	release, err := b.acquire(ctx)
+	if err != nil {
+		return zero, err
+	}
+	defer release()
+
+	return fn(ctx)
+}
+
+// acquire takes a slot at the default priority, waiting in the queue if none are free
+func (b *bulkhead) acquire(ctx context.Context) (func(), error) {
+	return b.admit(ctx, 0)
+}
+
+// admit takes a slot, waiting in the queue at the given priority if none are free
+func (b *bulkhead) admit(ctx context.Context, priority int) (func(), error) {
+	b.lock.Lock()
+
+	// Fast path: a slot is free
+	if b.inFlight < b.maxConcurrent {
+		b.inFlight++
+		b.lock.Unlock()
+		return b.release, nil
+	}
+
+	// Join the queue if there is room
+	if len(b.waiters) >= b.maxQueue {
+		b.lock.Unlock()
+		return nil, ErrBulkheadFull
+	}
+	w := &waiter{
+		// Ranking by arrival time brought forward an aging interval per priority level is the same as
+		// boosting every waiter's priority by one per interval waited, without having to re-rank the queue
+		rank:  b.clock.Now().Add(-time.Duration(priority) * b.agingInterval),
+		seq:   b.seq,
+		ready: make(chan struct{}),
+	}
+	b.seq++
+	heap.Push(&b.waiters, w)
+	b.lock.Unlock()
+
+	// Wait for a slot or for the caller to give up
+	select {
+	case <-w.ready:
+		return b.release, nil
+	case <-ctx.Done():
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if w.index < 0 {
+			// The slot was handed over as the caller gave up, so pass it on
+			b.releaseLocked()
+		} else {
+			heap.Remove(&b.waiters, w.index)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release frees a slot for the next caller
+func (b *bulkhead) release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.releaseLocked()
+}
+
+// releaseLocked frees a slot, handing it straight to the next waiter so a new caller can't take it first
+func (b *bulkhead) releaseLocked() {
+	if len(b.waiters) == 0 {
+		b.inFlight--
+		return
+	}
+	w := heap.Pop(&b.waiters).(*waiter)
+	close(w.ready)
+}
+
+// InFlight returns the number of calls currently executing
+func (b *bulkhead) InFlight() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.inFlight
+}
+
+// Queued returns the number of callers waiting for a slot
+func (b *bulkhead) Queued() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.waiters)
+}
@@ -0,0 +1,315 @@
+package bulkhead_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/bulkhead/internal/bulkhead"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("invalid max concurrent", func(t *testing.T) {
+		b, err := bulkhead.New(0, 1)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "maxConcurrent must be greater than 0")
+	})
+
+	t.Run("negative max queue", func(t *testing.T) {
+		b, err := bulkhead.New(1, -1)
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "maxQueue must not be negative")
+	})
+
+	t.Run("invalid aging interval", func(t *testing.T) {
+		b, err := bulkhead.New(1, 0, bulkhead.WithAging(0))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "aging interval must be greater than 0")
+	})
+
+	t.Run("nil aging clock", func(t *testing.T) {
+		b, err := bulkhead.New(1, 0, bulkhead.WithAgingClock(nil))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		b, err := bulkhead.New(1, 0)
+		require.NoError(t, err)
+		require.NotNil(t, b)
+	})
+}
+
+func TestDo(t *testing.T) {
+	t.Run("returns the result of fn", func(t *testing.T) {
+		b, err := bulkhead.New(1, 0)
+		require.NoError(t, err)
+
+		res, err := bulkhead.Do(context.Background(), b, func(context.Context) (string, error) {
+			return "ok", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "ok", res)
+	})
+
+	t.Run("rejects once concurrency and queue are exceeded", func(t *testing.T) {
+		b, err := bulkhead.New(2, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		release := make(chan struct{})
+		blocking := func(context.Context) error {
+			<-release
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = b.Do(ctx, blocking)
+			}(i)
+		}
+
+		// Two calls in flight and one waiting
+		require.Eventually(t, func() bool {
+			return b.InFlight() == 2 && b.Queued() == 1
+		}, time.Second, time.Millisecond)
+
+		called := false
+		err = b.Do(ctx, func(context.Context) error {
+			called = true
+			return nil
+		})
+		require.ErrorIs(t, err, bulkhead.ErrBulkheadFull)
+		require.False(t, called)
+
+		close(release)
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.Zero(t, b.InFlight())
+		require.Zero(t, b.Queued())
+	})
+
+	t.Run("slot is released on success and error", func(t *testing.T) {
+		b, err := bulkhead.New(1, 0)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		callErr := errors.New("dependency failed")
+
+		err = b.Do(ctx, func(context.Context) error { return callErr })
+		require.ErrorIs(t, err, callErr)
+		require.Zero(t, b.InFlight())
+
+		err = b.Do(ctx, func(context.Context) error { return nil })
+		require.NoError(t, err)
+		require.Zero(t, b.InFlight())
+
+		err = b.Do(ctx, func(context.Context) error { return nil })
+		require.NoError(t, err)
+	})
+
+	t.Run("context cancelled while queued", func(t *testing.T) {
+		b, err := bulkhead.New(1, 1)
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = b.Do(context.Background(), func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool {
+			return b.InFlight() == 1
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- b.Do(ctx, func(context.Context) error { return nil })
+		}()
+		require.Eventually(t, func() bool {
+			return b.Queued() == 1
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-errChan, context.Canceled)
+		require.Zero(t, b.Queued())
+
+		close(release)
+		<-done
+	})
+
+	t.Run("queued caller runs when a slot frees up", func(t *testing.T) {
+		b, err := bulkhead.New(1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		release := make(chan struct{})
+		go func() {
+			_ = b.Do(ctx, func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool {
+			return b.InFlight() == 1
+		}, time.Second, time.Millisecond)
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- b.Do(ctx, func(context.Context) error { return nil })
+		}()
+		require.Eventually(t, func() bool {
+			return b.Queued() == 1
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		require.NoError(t, <-errChan)
+	})
+}
+
+func TestExecute(t *testing.T) {
+	// occupy holds b's only slot until the returned func is called
+	occupy := func(t *testing.T, b interface {
+		Execute(context.Context, int, func(context.Context) error) error
+		InFlight() int
+	}) func() {
+		t.Helper()
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = b.Execute(context.Background(), 0, func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool {
+			return b.InFlight() == 1
+		}, time.Second, time.Millisecond)
+		return func() {
+			close(release)
+			<-done
+		}
+	}
+
+	// record returns a call appending name to the calls admitted so far
+	var lock sync.Mutex
+	record := func(admitted *[]string, name string) func(context.Context) error {
+		return func(context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			*admitted = append(*admitted, name)
+			return nil
+		}
+	}
+
+	t.Run("high priority request jumps the queue", func(t *testing.T) {
+		b, err := bulkhead.New(1, 2)
+		require.NoError(t, err)
+		release := occupy(t, b)
+
+		var admitted []string
+		var wg sync.WaitGroup
+		queue := func(name string, priority int) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := b.Execute(context.Background(), priority, record(&admitted, name)); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		queue("low", 0)
+		require.Eventually(t, func() bool {
+			return b.Queued() == 1
+		}, time.Second, time.Millisecond)
+		queue("high", 1)
+		require.Eventually(t, func() bool {
+			return b.Queued() == 2
+		}, time.Second, time.Millisecond)
+
+		release()
+		wg.Wait()
+		require.Equal(t, []string{"high", "low"}, admitted)
+	})
+
+	t.Run("starving low priority request eventually runs", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := bulkhead.New(1, 2, bulkhead.WithAging(time.Minute), bulkhead.WithAgingClock(fakeClock))
+		require.NoError(t, err)
+		release := occupy(t, b)
+
+		var admitted []string
+		var wg sync.WaitGroup
+		queue := func(name string, priority int) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := b.Execute(context.Background(), priority, record(&admitted, name)); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		queue("low", 0)
+		require.Eventually(t, func() bool {
+			return b.Queued() == 1
+		}, time.Second, time.Millisecond)
+
+		// Waiting far longer than the aging interval boosts the low priority request past a new high priority one
+		fakeClock.Advance(2 * time.Minute)
+		queue("high", 1)
+		require.Eventually(t, func() bool {
+			return b.Queued() == 2
+		}, time.Second, time.Millisecond)
+
+		release()
+		wg.Wait()
+		require.Equal(t, []string{"low", "high"}, admitted)
+	})
+
+	t.Run("equal priorities are admitted in arrival order", func(t *testing.T) {
+		b, err := bulkhead.New(1, 3)
+		require.NoError(t, err)
+		release := occupy(t, b)
+
+		var admitted []string
+		var wg sync.WaitGroup
+		for i, name := range []string{"first", "second", "third"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := b.Execute(context.Background(), 1, record(&admitted, name)); err != nil {
+					t.Error(err)
+				}
+			}()
+			require.Eventually(t, func() bool {
+				return b.Queued() == i+1
+			}, time.Second, time.Millisecond)
+		}
+
+		release()
+		wg.Wait()
+		require.Equal(t, []string{"first", "second", "third"}, admitted)
+	})
+}
@@ -0,0 +1,45 @@
+package bulkhead
+
+import "time"
+
+// waiter is a caller queued for a slot
+type waiter struct {
+	rank  time.Time     // Waiters are admitted earliest rank first
+	seq   uint64        // Breaks ties between waiters with the same rank
+	ready chan struct{} // Closed once the waiter has been handed a slot
+	index int           // Position in the queue, -1 once removed
+}
+
+// waitQueue is a heap of waiters implementing heap.Interface, with the next waiter to admit at the root
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if !q[i].rank.Equal(q[j].rank) {
+		return q[i].rank.Before(q[j].rank)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*q = old[:n-1]
+	return w
+}
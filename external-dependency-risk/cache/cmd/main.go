@@ -19,8 +19,8 @@ func main() {
 		log.Fatalf("Failed to create user service: %v", err)
 	}
 
-	// Create cache with 30 second TTL
-	userCache, err := cache.New(userService, 30*time.Second)
+	// Create cache with 30 second TTL, retrying the service's occasional failures
+	userCache, err := cache.New(userService, 30*time.Second, cache.WithLoaderRetries(2, 100*time.Millisecond))
 	if err != nil {
 		log.Fatalf("Failed to create cache: %v", err)
 	}
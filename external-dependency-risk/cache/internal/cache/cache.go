@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"fmt"
@@ -8,96 +9,1004 @@ import (
 	"time"
 
 	"github.com/jonboulle/clockwork"
-	
+
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/cache/internal/service"
 )
 
-// entry represents a cached item with expiration
-type entry struct {
-	Value     service.User
+var (
+	// ErrCacheClosed is returned by Get once the cache has been closed
+	ErrCacheClosed = errors.New("cache is closed")
+	// ErrLoadLimitExceeded is returned when a load is rejected because too many are already running
+	ErrLoadLimitExceeded = errors.New("too many concurrent loads")
+)
+
+// LoadLimitPolicy decides what happens to a load when the maximum number of concurrent loads are running
+type LoadLimitPolicy int
+
+const (
+	// QueueLoads makes the load wait for a running one to finish, or for its context to be done
+	QueueLoads LoadLimitPolicy = iota
+	// RejectLoads fails the load immediately with ErrLoadLimitExceeded
+	RejectLoads
+)
+
+// Entry represents a cached item with expiration
+type Entry[V any] struct {
+	Value     V
+	Err       error // Set for negatively cached load failures
 	ExpiresAt time.Time
 }
 
 // IsExpired checks if the cache entry has expired
-func (e entry) IsExpired(clock clockwork.Clock) bool {
+func (e Entry[V]) IsExpired(clock clockwork.Clock) bool {
 	return clock.Now().After(e.ExpiresAt)
 }
 
+// IsStale checks if an expired entry can still be served while it is refreshed
+func (e Entry[V]) IsStale(clock clockwork.Clock, maxStale time.Duration) bool {
+	return e.Err == nil && maxStale > 0 && !clock.Now().After(e.ExpiresAt.Add(maxStale))
+}
+
+// call represents an in-flight load whose result is shared by every caller waiting on the same key
+type call[V any] struct {
+	done       chan struct{}
+	value      V
+	err        error
+	abandoned  bool // Set when the load failed after the caller running it gave up
+	superseded bool // Set when a value was stored for the key while loading, so the loaded one is out of date
+}
+
+// EvictionReason describes why an entry left the cache
+type EvictionReason int
+
+const (
+	// EvictionExpired means the entry's TTL passed
+	EvictionExpired EvictionReason = iota
+	// EvictionDeleted means the entry was removed by Delete or Clear
+	EvictionDeleted
+	// EvictionCapacity means the entry was the least recently used when the cache was full
+	EvictionCapacity
+)
+
+// String returns the string representation of the eviction reason
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionExpired:
+		return "Expired"
+	case EvictionDeleted:
+		return "Deleted"
+	case EvictionCapacity:
+		return "Capacity"
+	default:
+		return "Unknown"
+	}
+}
+
+// Outcome classifies how a lookup was answered
+type Outcome int
+
+const (
+	// OutcomeHit means the value was cached and fresh, or was served stale while it is refreshed
+	OutcomeHit Outcome = iota
+	// OutcomeMiss means the key wasn't cached, so the value was loaded
+	OutcomeMiss
+	// OutcomeExpired means the cached value had expired, so it was loaded again
+	OutcomeExpired
+	// OutcomeError means the lookup returned an error, whether from the loader, a negatively cached failure or the context
+	OutcomeError
+)
+
+// String returns the string representation of the outcome
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeHit:
+		return "Hit"
+	case OutcomeMiss:
+		return "Miss"
+	case OutcomeExpired:
+		return "Expired"
+	case OutcomeError:
+		return "Error"
+	default:
+		return "Unknown"
+	}
+}
+
+// ExpiryEvent is sent to subscribers when an entry is evicted because its TTL passed
+type ExpiryEvent[K comparable] struct {
+	Key K
+	At  time.Time // When the entry was evicted, on the cache's clock
+}
+
+// subscriberBuffer is how many expiry events a subscriber can fall behind by before events are dropped
+const subscriberBuffer = 64
+
+// eviction records an evicted value so the callback can run after the lock is released
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
 // UserService defines the interface for user operations
 type UserService interface {
 	GetUser(ctx context.Context, id string) (service.User, error)
 }
 
-// cache provides a thread-safe in-memory cache with TTL support
-type cache struct {
-	service UserService
-	lock    sync.RWMutex
-	entries map[string]entry
-	ttl     time.Duration
-	clock   clockwork.Clock
+// options holds the configuration shared by every cache regardless of its key and value types
+type options struct {
+	clock      clockwork.Clock
+	logger     Logger
+	maxEntries int           // 0 means unbounded
+	maxStale   time.Duration // 0 disables stale-while-revalidate
+	negTTL     time.Duration // 0 disables negative caching
+	negative   func(error) bool
+	cleanup    time.Duration   // 0 disables the background janitor
+	retries    int             // Additional loader attempts after a failure, 0 disables retries
+	backoff    time.Duration   // Delay between loader attempts
+	maxLoads   int             // Loader calls allowed to run at once, 0 means unbounded
+	loadPolicy LoadLimitPolicy // Whether loads beyond maxLoads queue or are rejected
+	staleOnErr bool            // Whether a failed load returns the expired value instead of the error
+	timeout    time.Duration   // Deadline for each loader call, 0 means no deadline
+	onEvict    any             // func(K, V, EvictionReason), checked against the cache types in NewCache
+	backend    any             // Store[K, V], checked against the cache types in NewCache
+
+	// observer is notified of every GetUser call, nil if not set
+	observer func(id string, outcome Outcome, d time.Duration)
+	// writer updates the backing store for WriteThrough, nil if not set
+	writer func(ctx context.Context, id string, user service.User) error
 }
 
 // Option is a functional option for configuring the cache
-type Option func(*cache) error
+type Option func(*options) error
 
 // WithClock sets a custom clock for the cache
 func WithClock(clock clockwork.Clock) Option {
-	return func(c *cache) error {
+	return func(o *options) error {
 		if clock == nil {
 			return errors.New("clock is nil")
 		}
-		c.clock = clock
+		o.clock = clock
 		return nil
 	}
 }
 
-// New creates a new cache with the specified TTL and optional configurations
-func New(service UserService, ttl time.Duration, opts ...Option) (*cache, error) {
-	switch {
-	case service == nil:
-		return nil, errors.New("service is nil")
-	case ttl <= 0:
+// WithLogger sets the logger the cache reports failed loads and evictions to
+func WithLogger(logger Logger) Option {
+	return func(o *options) error {
+		if logger == nil {
+			return errors.New("logger is nil")
+		}
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithMaxEntries bounds the cache size, evicting the least recently used entry when full
+func WithMaxEntries(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("maxEntries must be greater than 0")
+		}
+		o.maxEntries = n
+		return nil
+	}
+}
+
+// WithStaleWhileRevalidate serves expired entries for up to maxStale past their expiry
+// while refreshing them in the background
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(o *options) error {
+		if maxStale <= 0 {
+			return errors.New("maxStale must be greater than 0")
+		}
+		o.maxStale = maxStale
+		return nil
+	}
+}
+
+// WithNegativeTTL caches load errors for ttl so repeated lookups for a failing key don't reach the loader.
+// Loads that fail after the caller running them gave up aren't cached.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) error {
+		if ttl <= 0 {
+			return errors.New("negative ttl must be greater than 0")
+		}
+		o.negTTL = ttl
+		return nil
+	}
+}
+
+// WithNegativeCacheable restricts negative caching to errors matching fn, by default every error is cached
+func WithNegativeCacheable(fn func(error) bool) Option {
+	return func(o *options) error {
+		if fn == nil {
+			return errors.New("negative cacheable predicate is nil")
+		}
+		o.negative = fn
+		return nil
+	}
+}
+
+// WithCleanupInterval starts a background janitor that removes expired entries every interval
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *options) error {
+		if interval <= 0 {
+			return errors.New("cleanup interval must be greater than 0")
+		}
+		o.cleanup = interval
+		return nil
+	}
+}
+
+// WithLoaderRetries retries a failed load up to n more times, waiting backoff between attempts,
+// before the error is returned or negatively cached. No further attempts are made once the load's context is done.
+func WithLoaderRetries(n int, backoff time.Duration) Option {
+	return func(o *options) error {
+		switch {
+		case n <= 0:
+			return errors.New("loader retries must be greater than 0")
+		case backoff < 0:
+			return errors.New("loader backoff cannot be negative")
+		}
+		o.retries = n
+		o.backoff = backoff
+		return nil
+	}
+}
+
+// WithMaxConcurrentLoads bounds how many loader calls run at once across every key, so a stampede of
+// distinct cold keys can't overwhelm the underlying service. Loads beyond the limit are queued or
+// rejected according to policy. Waiting between loader retries doesn't hold a slot.
+func WithMaxConcurrentLoads(n int, policy LoadLimitPolicy) Option {
+	return func(o *options) error {
+		switch {
+		case n <= 0:
+			return errors.New("max concurrent loads must be greater than 0")
+		case policy != QueueLoads && policy != RejectLoads:
+			return errors.New("unknown load limit policy")
+		}
+		o.maxLoads = n
+		o.loadPolicy = policy
+		return nil
+	}
+}
+
+// WithServeStaleOnError returns the last value loaded for a key, however long ago it expired, when loading
+// it again fails. Unlike stale-while-revalidate, expired values are only served once the loader has failed.
+// Expired values are still removed by the janitor and capacity eviction.
+func WithServeStaleOnError() Option {
+	return func(o *options) error {
+		o.staleOnErr = true
+		return nil
+	}
+}
+
+// WithLoaderTimeout gives each loader call at most timeout to complete, cancelling its context once the
+// deadline passes. A timed out load fails with an error wrapping context.DeadlineExceeded, which is retried,
+// negatively cached or answered with a stale value like any other load failure.
+func WithLoaderTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return errors.New("loader timeout must be greater than 0")
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithObserver sets a callback invoked exactly once for every GetUser call as it returns, with the outcome of
+// the lookup and how long it took on the cache's clock. It has no effect on the generic Cache.
+func WithObserver(fn func(id string, outcome Outcome, d time.Duration)) Option {
+	return func(o *options) error {
+		if fn == nil {
+			return errors.New("observer is nil")
+		}
+		o.observer = fn
+		return nil
+	}
+}
+
+// WithWriter sets the function WriteThrough uses to update the backing store before caching a user.
+// It has no effect on the generic Cache.
+func WithWriter(fn func(ctx context.Context, id string, user service.User) error) Option {
+	return func(o *options) error {
+		if fn == nil {
+			return errors.New("writer is nil")
+		}
+		o.writer = fn
+		return nil
+	}
+}
+
+// WithStore sets where the cache keeps its entries, by default an in-memory map. The store is used as given,
+// so entries already in it are served but only count towards the WithMaxEntries limit once written by the cache.
+func WithStore[K comparable, V any](store Store[K, V]) Option {
+	return func(o *options) error {
+		if store == nil {
+			return errors.New("store is nil")
+		}
+		o.backend = store
+		return nil
+	}
+}
+
+// WithOnEvict sets a callback invoked once for every value that leaves the cache.
+// It runs without the cache lock held so it may safely call back into the cache.
+func WithOnEvict[K comparable, V any](fn func(key K, value V, reason EvictionReason)) Option {
+	return func(o *options) error {
+		if fn == nil {
+			return errors.New("onEvict is nil")
+		}
+		o.onEvict = fn
+		return nil
+	}
+}
+
+// Cache provides a thread-safe in-memory cache with TTL support for any key and value types
+type Cache[K comparable, V any] struct {
+	options
+	lock     sync.RWMutex
+	entries  Store[K, V]
+	inflight map[K]*call[V]
+	order    *list.List          // Keys ordered from most to least recently used
+	elements map[K]*list.Element // Position of each key in order
+	ttl      time.Duration
+	stop     chan struct{}
+	stopped  chan struct{} // Closed once the janitor has exited
+	stopOnce sync.Once
+	onEvict  func(K, V, EvictionReason)
+	loads    chan struct{} // Holds a token for every loader call running, nil if loads are unbounded
+
+	subLock     sync.Mutex
+	subscribers []chan ExpiryEvent[K]
+	subsClosed  bool // Set by Close, after which subscribers get a closed channel
+}
+
+// NewCache creates a new generic cache with the specified TTL and optional configurations
+func NewCache[K comparable, V any](ttl time.Duration, opts ...Option) (*Cache[K, V], error) {
+	if ttl <= 0 {
 		return nil, errors.New("ttl must be greater than 0")
 	}
 
-	c := &cache{
-		service: service,
-		entries: make(map[string]entry),
-		ttl:     ttl,
-		clock:   clockwork.NewRealClock(), // Default to real clock
+	c := &Cache[K, V]{
+		options: options{
+			clock:  clockwork.NewRealClock(), // Default to real clock
+			logger: noopLogger{},
+		},
+		entries:  NewMemoryStore[K, V](),
+		inflight: make(map[K]*call[V]),
+		order:    list.New(),
+		elements: make(map[K]*list.Element),
+		ttl:      ttl,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
 	// Apply options
 	for _, opt := range opts {
-		if err := opt(c); err != nil {
+		if err := opt(&c.options); err != nil {
 			return nil, err
 		}
 	}
 
+	if c.maxLoads > 0 {
+		c.loads = make(chan struct{}, c.maxLoads)
+	}
+
+	if c.options.backend != nil {
+		store, ok := c.options.backend.(Store[K, V])
+		if !ok {
+			return nil, errors.New("store key and value types don't match the cache")
+		}
+		c.entries = store
+	}
+
+	if c.options.onEvict != nil {
+		fn, ok := c.options.onEvict.(func(K, V, EvictionReason))
+		if !ok {
+			return nil, errors.New("onEvict key and value types don't match the cache")
+		}
+		c.onEvict = fn
+	}
+
+	if c.cleanup > 0 {
+		// Create the ticker before returning so time advanced by the caller is observed
+		go c.janitor(c.clock.NewTicker(c.cleanup))
+	} else {
+		close(c.stopped)
+	}
+
 	return c, nil
 }
 
-// GetUser retrieves a value from the cache
-func (c *cache) GetUser(ctx context.Context, id string) (service.User, error) {
+// Close stops the background janitor and waits for it to exit. Subsequent calls to Get return ErrCacheClosed,
+// while loads already in flight complete for the callers waiting on them. It is safe to call more than once.
+func (c *Cache[K, V]) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.stopped
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+	if !c.subsClosed {
+		for _, ch := range c.subscribers {
+			close(ch)
+		}
+		c.subscribers = nil
+		c.subsClosed = true
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving an event whenever an entry is evicted because its TTL passed, whether
+// by the janitor or when it's replaced. Every subscriber receives every event. Events are dropped rather than
+// blocking the cache if a subscriber falls too far behind. The channel is closed by Close.
+func (c *Cache[K, V]) Subscribe() <-chan ExpiryEvent[K] {
+	ch := make(chan ExpiryEvent[K], subscriberBuffer)
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+	if c.subsClosed {
+		close(ch)
+		return ch
+	}
+	c.subscribers = append(c.subscribers, ch)
+	return ch
+}
+
+// publish sends an expiry event for key to every subscriber, it must be called without the lock held
+func (c *Cache[K, V]) publish(key K) {
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	event := ExpiryEvent[K]{Key: key, At: c.clock.Now()}
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+			c.logger.Debugf("dropped expiry event for %v, subscriber is full", key)
+		}
+	}
+}
+
+// closed reports whether Close has been called
+func (c *Cache[K, V]) closed() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// janitor removes expired entries on every tick until the cache is closed
+func (c *Cache[K, V]) janitor(ticker clockwork.Ticker) {
+	defer close(c.stopped)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.Chan():
+			c.sweep()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// sweep removes every entry that has expired and can no longer be served stale
+func (c *Cache[K, V]) sweep() {
+	var evicted []eviction[K, V]
+
+	c.lock.Lock()
+	for key, e := range c.all() {
+		if e.IsExpired(c.clock) && !e.IsStale(c.clock, c.maxStale) {
+			evicted = c.remove(key, e, EvictionExpired, evicted)
+		}
+	}
+	c.lock.Unlock()
+
+	c.notify(evicted)
+}
+
+// Peek returns the cached value for key if it is present and not expired, without loading it
+// or counting as a use of the entry. Negatively cached errors are reported as absent.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	e, ok := c.entries.Get(key)
+	if !ok || e.Err != nil || e.IsExpired(c.clock) {
+		var zero V
+		return zero, false
+	}
+	return e.Value, true
+}
+
+// TTL returns how long the entry for key has left before it expires, measured on the cache's clock.
+// A missing or expired key returns (0, false). It doesn't trigger a load or count as a use of the entry.
+func (c *Cache[K, V]) TTL(key K) (time.Duration, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	e, ok := c.entries.Get(key)
+	if !ok || e.IsExpired(c.clock) {
+		return 0, false
+	}
+	return e.ExpiresAt.Sub(c.clock.Now()), true
+}
+
+// Len returns the number of entries held, including expired entries not yet removed
+func (c *Cache[K, V]) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	n := 0
+	c.entries.Range(func(K, Entry[V]) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// TTLLoader loads the value for a key along with how long to cache it.
+// A TTL that isn't positive falls back to the cache's configured TTL.
+type TTLLoader[K comparable, V any] func(ctx context.Context, key K) (V, time.Duration, error)
+
+// Get retrieves a value from the cache, calling loader on a miss or expiry
+func (c *Cache[K, V]) Get(ctx context.Context, key K, loader func(context.Context, K) (V, error)) (V, error) {
+	return c.GetWithTTL(ctx, key, withoutTTL(loader))
+}
+
+// withoutTTL adapts loader to a TTLLoader that caches its values for the configured TTL
+func withoutTTL[K comparable, V any](loader func(context.Context, K) (V, error)) TTLLoader[K, V] {
+	return func(ctx context.Context, key K) (V, time.Duration, error) {
+		value, err := loader(ctx, key)
+		return value, 0, err
+	}
+}
+
+// GetWithTTL retrieves a value from the cache like Get, caching a loaded value for the TTL the loader returns
+func (c *Cache[K, V]) GetWithTTL(ctx context.Context, key K, loader TTLLoader[K, V]) (V, error) {
+	value, _, err := c.get(ctx, key, loader)
+	return value, err
+}
+
+// get retrieves a value like GetWithTTL, also returning how the lookup was answered
+func (c *Cache[K, V]) get(ctx context.Context, key K, loader TTLLoader[K, V]) (V, Outcome, error) {
+	value, outcome, err := c.lookup(ctx, key, loader)
+	if err != nil {
+		outcome = OutcomeError
+	}
+	return value, outcome, err
+}
+
+// lookup retrieves a value like GetWithTTL, also returning whether the key was cached, expired or missing
+func (c *Cache[K, V]) lookup(ctx context.Context, key K, loader TTLLoader[K, V]) (V, Outcome, error) {
+	if c.closed() {
+		var zero V
+		return zero, OutcomeError, ErrCacheClosed
+	}
 
 	// Check cache first
 	c.lock.RLock()
-	cu, ok := c.entries[id]
+	e, ok := c.entries.Get(key)
 	c.lock.RUnlock()
-	if ok && !cu.IsExpired(c.clock) {
-		return cu.Value, nil // Cache hit & not expired
+	outcome := OutcomeMiss
+	if ok {
+		switch {
+		case !e.IsExpired(c.clock):
+			c.touch(key)
+			return e.Value, OutcomeHit, e.Err // Cache hit & not expired
+		case e.IsStale(c.clock, c.maxStale):
+			c.touch(key)
+			c.refresh(ctx, key, loader)
+			return e.Value, OutcomeHit, nil // Serve stale value while it is refreshed
+		}
+		outcome = OutcomeExpired
 	}
 
-	// Miss/expired: call underlying service
-	user, err := c.service.GetUser(ctx, id)
-	if err != nil {
-		return service.User{}, fmt.Errorf("failed to get user: %w", err)
+	for {
+		// Don't start or wait for a load the caller has already given up on
+		if err := ctx.Err(); err != nil {
+			var zero V
+			return zero, outcome, err
+		}
+
+		// Miss/expired: join an in-flight load for this key or start a new one
+		c.lock.Lock()
+		if e, ok := c.entries.Get(key); ok && !e.IsExpired(c.clock) {
+			c.lock.Unlock()
+			return e.Value, OutcomeHit, e.Err // Loaded by another caller while we waited for the lock
+		}
+		cl, leader := c.begin(key)
+		c.lock.Unlock()
+
+		if leader {
+			// The load has finished, so its result takes precedence over the context being done
+			c.load(ctx, key, loader, cl)
+			return cl.value, outcome, cl.err
+		}
+
+		select {
+		case <-cl.done:
+			if !cl.abandoned {
+				return cl.value, outcome, cl.err
+			}
+			// The load failed because the caller running it gave up, so load the key again
+		case <-ctx.Done():
+			// Stop waiting on another caller's load, which carries on for the others
+			var zero V
+			return zero, outcome, ctx.Err()
+		}
+	}
+}
+
+// refresh reloads key in the background unless a load is already in flight
+func (c *Cache[K, V]) refresh(ctx context.Context, key K, loader TTLLoader[K, V]) {
+	c.lock.Lock()
+	cl, leader := c.begin(key)
+	c.lock.Unlock()
+
+	if leader {
+		// The refresh outlives the request that triggered it
+		go func() {
+			c.load(context.WithoutCancel(ctx), key, loader, cl)
+			if cl.err != nil {
+				// Nobody is waiting on the refresh to see its error
+				c.logger.Warnf("background refresh of %v failed, serving stale value: %v", key, cl.err)
+			}
+		}()
+	}
+}
+
+// begin joins the in-flight load for key or registers a new one, reporting whether the caller must run it.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) begin(key K) (*call[V], bool) {
+	if cl, ok := c.inflight[key]; ok {
+		return cl, false
+	}
+	cl := &call[V]{done: make(chan struct{})}
+	c.inflight[key] = cl
+	return cl, true
+}
+
+// load runs loader for key, caches a successful result and releases any callers waiting on cl.
+// A failed load leaves any servable stale entry in place.
+func (c *Cache[K, V]) load(ctx context.Context, key K, loader TTLLoader[K, V], cl *call[V]) {
+	var ttl time.Duration
+	cl.value, ttl, cl.err = c.callLoader(ctx, key, loader)
+	if cl.err != nil {
+		var zero V
+		cl.value = zero
+	}
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	var (
+		evicted []eviction[K, V]
+		loadErr error // Set when a failed load is served a stale value instead
+	)
+
+	c.lock.Lock()
+	e, ok := c.entries.Get(key)
+	switch {
+	case ok && cl.superseded:
+		// Share the value stored while loading with the waiters, rather than the older one loaded
+		cl.value, cl.err = e.Value, e.Err
+	case ok && cl.err != nil && c.staleOnErr && e.Err == nil:
+		loadErr = cl.err
+		cl.value, cl.err = e.Value, nil
+	}
+	cl.abandoned = cl.err != nil && done(ctx)
+	switch {
+	case cl.superseded:
+		// Keep the value stored while loading, which is newer than anything the loader read
+	case loadErr != nil:
+		// Leave the stale entry expired, so the next lookup tries the loader again
+	case cl.err == nil:
+		evicted = c.store(key, Entry[V]{Value: cl.value, ExpiresAt: c.clock.Now().Add(ttl)})
+	case c.cacheError(ctx, key, cl.err):
+		evicted = c.store(key, Entry[V]{Err: cl.err, ExpiresAt: c.clock.Now().Add(c.negTTL)})
+	}
+	delete(c.inflight, key)
+	c.lock.Unlock()
+	close(cl.done)
+
+	if loadErr != nil {
+		c.logger.Warnf("loading %v failed, serving stale value: %v", key, loadErr)
+	}
+	c.notify(evicted)
+}
+
+// callLoader calls loader, retrying failures as configured by WithLoaderRetries
+func (c *Cache[K, V]) callLoader(ctx context.Context, key K, loader TTLLoader[K, V]) (V, time.Duration, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := c.acquireLoad(ctx)
+		if err != nil {
+			var zero V
+			return zero, 0, err
+		}
+		value, ttl, err := c.callWithTimeout(ctx, key, loader)
+		release()
+		if err == nil || attempt >= c.retries || ctx.Err() != nil {
+			return value, ttl, err
+		}
+
+		c.logger.Debugf("loading %v failed on attempt %d/%d, retrying in %v: %v", key, attempt+1, c.retries+1, c.backoff, err)
+
+		select {
+		case <-c.clock.After(c.backoff):
+		case <-ctx.Done():
+			return value, ttl, err
+		}
+	}
+}
+
+// callWithTimeout makes a single loader call, bounded by the timeout set with WithLoaderTimeout
+func (c *Cache[K, V]) callWithTimeout(ctx context.Context, key K, loader TTLLoader[K, V]) (V, time.Duration, error) {
+	if c.timeout == 0 {
+		return loader(ctx, key)
+	}
+
+	loadCtx, cancel := clockwork.WithTimeout(ctx, c.clock, c.timeout)
+	defer cancel()
+
+	value, ttl, err := loader(loadCtx, key)
+	if err != nil && done(loadCtx) && !done(ctx) {
+		// Our deadline passed rather than the caller giving up
+		return value, ttl, fmt.Errorf("loading %v timed out after %v: %w", key, c.timeout, context.DeadlineExceeded)
+	}
+	return value, ttl, err
+}
+
+// done reports whether ctx is done without waiting for it
+func done(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// acquireLoad takes a slot for a loader call when loads are bounded, returning a func releasing it
+func (c *Cache[K, V]) acquireLoad(ctx context.Context) (func(), error) {
+	if c.loads == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-c.loads }
+
+	if c.loadPolicy == RejectLoads {
+		select {
+		case c.loads <- struct{}{}:
+			return release, nil
+		default:
+			return nil, ErrLoadLimitExceeded
+		}
+	}
+
+	select {
+	case c.loads <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Set stores value for key with an expiry computed from the configured TTL
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores value for key with its own TTL, falling back to the configured TTL if ttl isn't positive.
+// A load of key already in flight is superseded, so its result won't overwrite value.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
 	}
 
-	// Cache the result with new expiry
 	c.lock.Lock()
-	c.entries[id] = entry{Value: user, ExpiresAt: c.clock.Now().Add(c.ttl)}
+	evicted := c.store(key, Entry[V]{Value: value, ExpiresAt: c.clock.Now().Add(ttl)})
+	if cl, ok := c.inflight[key]; ok {
+		cl.superseded = true
+	}
+	c.lock.Unlock()
+
+	c.notify(evicted)
+}
+
+// Delete removes the entry for key, doing nothing if it isn't cached
+func (c *Cache[K, V]) Delete(key K) {
+	var evicted []eviction[K, V]
+
+	c.lock.Lock()
+	if e, ok := c.entries.Get(key); ok {
+		evicted = c.remove(key, e, EvictionDeleted, evicted)
+	}
+	c.lock.Unlock()
+
+	c.notify(evicted)
+}
+
+// Clear removes every entry from the cache
+func (c *Cache[K, V]) Clear() {
+	var evicted []eviction[K, V]
+
+	c.lock.Lock()
+	for key, e := range c.all() {
+		evicted = c.remove(key, e, EvictionDeleted, evicted)
+	}
 	c.lock.Unlock()
 
+	c.notify(evicted)
+}
+
+// touch marks key as most recently used, only bounded caches track recency on reads
+func (c *Cache[K, V]) touch(key K) {
+	if c.maxEntries == 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// The entry may have been removed since it was read
+	if el, ok := c.elements[key]; ok {
+		c.order.MoveToFront(el)
+	}
+}
+
+// cacheError reports whether a load error for key should be negatively cached.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) cacheError(ctx context.Context, key K, err error) bool {
+	if c.negTTL == 0 || (c.negative != nil && !c.negative(err)) {
+		return false
+	}
+	// The caller that ran the load gave up, so the failure is theirs rather than the key's
+	if done(ctx) {
+		return false
+	}
+	// Being rejected says nothing about the key, so the next lookup should try again
+	if errors.Is(err, ErrLoadLimitExceeded) {
+		return false
+	}
+	// Prefer serving a stale value over caching the failure to refresh it
+	e, ok := c.entries.Get(key)
+	return !ok || !e.IsStale(c.clock, c.maxStale)
+}
+
+// store caches e for key, evicting the least recently used entry when full, and returns the evicted values.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) store(key K, e Entry[V]) []eviction[K, V] {
+	var evicted []eviction[K, V]
+
+	if existing, ok := c.entries.Get(key); ok {
+		// Replacing an expired value evicts it, replacing a fresh one is an update
+		if existing.Err == nil && existing.IsExpired(c.clock) {
+			evicted = append(evicted, eviction[K, V]{key: key, value: existing.Value, reason: EvictionExpired})
+		}
+	}
+	c.entries.Set(key, e)
+
+	if el, ok := c.elements[key]; ok {
+		c.order.MoveToFront(el)
+		return evicted
+	}
+	c.elements[key] = c.order.PushFront(key)
+
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back().Value.(K)
+		if e, ok := c.entries.Get(oldest); ok {
+			evicted = c.remove(oldest, e, EvictionCapacity, evicted)
+		} else {
+			// Removed from the store behind the cache's back, so there is no value to report
+			c.forget(oldest)
+		}
+	}
+
+	return evicted
+}
+
+// remove deletes the entry for key and appends its value to evicted.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) remove(key K, e Entry[V], reason EvictionReason, evicted []eviction[K, V]) []eviction[K, V] {
+	c.forget(key)
+	c.entries.Delete(key)
+	// Negatively cached errors hold no value to report
+	if e.Err != nil {
+		return evicted
+	}
+	return append(evicted, eviction[K, V]{key: key, value: e.Value, reason: reason})
+}
+
+// forget stops tracking the recency of key.
+// It must be called with the write lock held.
+func (c *Cache[K, V]) forget(key K) {
+	if el, ok := c.elements[key]; ok {
+		c.order.Remove(el)
+		delete(c.elements, key)
+	}
+}
+
+// all returns a snapshot of every entry in the store, so entries can be removed while iterating over it.
+// It must be called with the lock held.
+func (c *Cache[K, V]) all() map[K]Entry[V] {
+	entries := make(map[K]Entry[V])
+	c.entries.Range(func(key K, e Entry[V]) bool {
+		entries[key] = e
+		return true
+	})
+	return entries
+}
+
+// notify invokes the eviction callback, it must be called without the lock held
+func (c *Cache[K, V]) notify(evicted []eviction[K, V]) {
+	for _, e := range evicted {
+		c.logger.Debugf("evicted %v: %s", e.key, e.reason)
+		if e.reason == EvictionExpired {
+			c.publish(e.key)
+		}
+	}
+
+	if c.onEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		c.onEvict(e.key, e.value, e.reason)
+	}
+}
+
+// cache wraps a user service with a Cache
+type cache struct {
+	*Cache[string, service.User]
+	service UserService
+}
+
+// New creates a new user cache with the specified TTL and optional configurations
+func New(svc UserService, ttl time.Duration, opts ...Option) (*cache, error) {
+	if svc == nil {
+		return nil, errors.New("service is nil")
+	}
+
+	c, err := NewCache[string, service.User](ttl, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cache{
+		Cache:   c,
+		service: svc,
+	}, nil
+}
+
+// GetUser retrieves a user from the cache, reporting the outcome to the observer if one is set
+func (c *cache) GetUser(ctx context.Context, id string) (service.User, error) {
+	start := c.clock.Now()
+	user, outcome, err := c.get(ctx, id, withoutTTL(c.service.GetUser))
+	if c.observer != nil {
+		c.observer(id, outcome, c.clock.Since(start))
+	}
+	if err != nil {
+		return service.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
 	return user, nil
 }
+
+// WriteThrough updates the backing store with user, then caches it with a fresh TTL.
+// If the write fails, the cached entry is left untouched.
+func (c *cache) WriteThrough(ctx context.Context, id string, user service.User) error {
+	if c.writer == nil {
+		return errors.New("writer is not configured")
+	}
+
+	if err := c.writer(ctx, id, user); err != nil {
+		return fmt.Errorf("failed to write user: %w", err)
+	}
+
+	c.Set(id, user)
+	return nil
+}
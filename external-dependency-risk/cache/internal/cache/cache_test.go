@@ -3,6 +3,7 @@ package cache_test
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,7 +25,7 @@ func TestNew(t *testing.T) {
 		c, err := cache.New(mockService, 5*time.Minute)
 		require.NoError(t, err)
 		require.NotNil(t, c)
-	})	
+	})
 
 	t.Run("nil service", func(t *testing.T) {
 		c, err := cache.New(nil, 5*time.Minute)
@@ -209,4 +210,2125 @@ func TestGetUser(t *testing.T) {
 		require.Equal(t, service.User{}, user)
 		require.Contains(t, err.Error(), "failed to get user")
 	})
+
+	t.Run("concurrent misses share a single load", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		release := make(chan struct{})
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			DoAndReturn(func(context.Context, string) (service.User, error) {
+				<-release
+				return expectedUser, nil
+			}).
+			Times(1)
+
+		const callers = 50
+		var wg sync.WaitGroup
+		users := make([]service.User, callers)
+		errs := make([]error, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				users[i], errs[i] = c.GetUser(ctx, "1")
+			}(i)
+		}
+
+		close(release)
+		wg.Wait()
+
+		for i := 0; i < callers; i++ {
+			require.NoError(t, errs[i])
+			require.Equal(t, expectedUser, users[i])
+		}
+	})
+}
+
+func TestGetUserCancelledContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expectedUser := service.User{ID: "1", Name: "Test User"}
+
+	t.Run("pre-cancelled context never calls the service", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		user, err := c.GetUser(ctx, "1")
+		require.ErrorIs(t, err, context.Canceled)
+		require.Contains(t, err.Error(), "failed to get user")
+		require.Equal(t, service.User{}, user)
+	})
+
+	t.Run("expired deadline never calls the service", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err = c.GetUser(ctx, "1")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("cached value is still served", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		c.Set("1", expectedUser)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, expectedUser, user)
+	})
+
+	t.Run("waiter stops waiting on another caller's load when cancelled", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			DoAndReturn(func(context.Context, string) (service.User, error) {
+				close(started)
+				<-release
+				return expectedUser, nil
+			}).
+			Times(1)
+
+		leaderErr := make(chan error)
+		go func() {
+			_, err := c.GetUser(ctx, "1")
+			leaderErr <- err
+		}()
+		<-started
+
+		waiterCtx, cancel := context.WithCancel(context.Background())
+		waiterErr := make(chan error)
+		go func() {
+			_, err := c.GetUser(waiterCtx, "1")
+			waiterErr <- err
+		}()
+
+		cancel()
+		require.ErrorIs(t, <-waiterErr, context.Canceled)
+
+		// The load carries on for the caller that started it
+		close(release)
+		require.NoError(t, <-leaderErr)
+	})
+
+	t.Run("waiter loads again when the caller running the load gives up", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		started := make(chan struct{})
+		gomock.InOrder(
+			mockService.EXPECT().
+				GetUser(gomock.Any(), "1").
+				DoAndReturn(func(ctx context.Context, _ string) (service.User, error) {
+					close(started)
+					<-ctx.Done()
+					return service.User{}, ctx.Err()
+				}),
+			mockService.EXPECT().
+				GetUser(gomock.Any(), "1").
+				Return(expectedUser, nil),
+		)
+
+		leaderCtx, cancel := context.WithCancel(context.Background())
+		leaderErr := make(chan error)
+		go func() {
+			_, err := c.GetUser(leaderCtx, "1")
+			leaderErr <- err
+		}()
+		<-started
+
+		type result struct {
+			user service.User
+			err  error
+		}
+		waiter := make(chan result)
+		go func() {
+			user, err := c.GetUser(context.Background(), "1")
+			waiter <- result{user, err}
+		}()
+		// Give the waiter time to join the load before the caller running it gives up
+		time.Sleep(10 * time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-leaderErr, context.Canceled)
+
+		got := <-waiter
+		require.NoError(t, got.err)
+		require.Equal(t, expectedUser, got.user)
+	})
+}
+
+type product struct {
+	SKU   string
+	Price float64
+}
+
+func TestCache(t *testing.T) {
+	t.Run("invalid TTL", func(t *testing.T) {
+		c, err := cache.NewCache[int, product](0)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "ttl must be greater than 0")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		c, err := cache.NewCache[int, product](time.Minute, cache.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("caches values for any key and value types", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[int, product](time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		loads := 0
+		loader := func(ctx context.Context, id int) (product, error) {
+			loads++
+			return product{SKU: "sku-1", Price: float64(loads)}, nil
+		}
+
+		p, err := c.Get(ctx, 1, loader)
+		require.NoError(t, err)
+		require.Equal(t, product{SKU: "sku-1", Price: 1}, p)
+
+		p, err = c.Get(ctx, 1, loader)
+		require.NoError(t, err)
+		require.Equal(t, product{SKU: "sku-1", Price: 1}, p)
+		require.Equal(t, 1, loads)
+
+		fakeClock.Advance(2 * time.Minute)
+
+		p, err = c.Get(ctx, 1, loader)
+		require.NoError(t, err)
+		require.Equal(t, product{SKU: "sku-1", Price: 2}, p)
+		require.Equal(t, 2, loads)
+	})
+
+	t.Run("loader error is returned unwrapped and not cached", func(t *testing.T) {
+		c, err := cache.NewCache[int, product](time.Minute)
+		require.NoError(t, err)
+
+		loadErr := errors.New("database unavailable")
+		p, err := c.Get(context.Background(), 1, func(ctx context.Context, id int) (product, error) {
+			return product{SKU: "partial"}, loadErr
+		})
+		require.ErrorIs(t, err, loadErr)
+		require.Equal(t, product{}, p)
+
+		p, err = c.Get(context.Background(), 1, func(ctx context.Context, id int) (product, error) {
+			return product{SKU: "sku-1"}, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "sku-1", p.SKU)
+	})
+}
+
+func TestInvalidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expectedUser := service.User{ID: "1", Name: "John Doe", Email: "john@example.com"}
+
+	t.Run("delete forces a fresh load", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			Return(expectedUser, nil).
+			Times(2)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+
+		c.Delete("1")
+
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, expectedUser, user)
+	})
+
+	t.Run("delete missing key is a no-op", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		require.NotPanics(t, func() { c.Delete("missing") })
+	})
+
+	t.Run("clear forces a fresh load for every key", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		otherUser := service.User{ID: "2", Name: "Jane Doe", Email: "jane@example.com"}
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			Return(expectedUser, nil).
+			Times(2)
+		mockService.EXPECT().
+			GetUser(ctx, "2").
+			Return(otherUser, nil).
+			Times(2)
+
+		for i := 0; i < 2; i++ {
+			_, err = c.GetUser(ctx, "1")
+			require.NoError(t, err)
+			_, err = c.GetUser(ctx, "2")
+			require.NoError(t, err)
+
+			c.Clear()
+		}
+	})
+}
+
+func TestMaxEntries(t *testing.T) {
+	t.Run("invalid max entries", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithMaxEntries(0))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "maxEntries must be greater than 0")
+	})
+
+	t.Run("evicts least recently used entry when full", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithMaxEntries(2))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		loads := make(map[string]int)
+		loader := func(ctx context.Context, key string) (int, error) {
+			loads[key]++
+			return len(key), nil
+		}
+
+		_, err = c.Get(ctx, "a", loader)
+		require.NoError(t, err)
+		_, err = c.Get(ctx, "b", loader)
+		require.NoError(t, err)
+
+		// Reading "a" makes "b" the least recently used
+		_, err = c.Get(ctx, "a", loader)
+		require.NoError(t, err)
+
+		_, err = c.Get(ctx, "c", loader)
+		require.NoError(t, err)
+
+		// "a" and "c" are still cached, "b" was evicted and must be reloaded
+		_, err = c.Get(ctx, "a", loader)
+		require.NoError(t, err)
+		_, err = c.Get(ctx, "c", loader)
+		require.NoError(t, err)
+		_, err = c.Get(ctx, "b", loader)
+		require.NoError(t, err)
+
+		require.Equal(t, map[string]int{"a": 1, "b": 2, "c": 1}, loads)
+	})
+
+	t.Run("delete frees capacity", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithMaxEntries(2))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		loads := make(map[string]int)
+		loader := func(ctx context.Context, key string) (int, error) {
+			loads[key]++
+			return len(key), nil
+		}
+
+		for _, key := range []string{"a", "b"} {
+			_, err = c.Get(ctx, key, loader)
+			require.NoError(t, err)
+		}
+		c.Delete("b")
+		for _, key := range []string{"c", "a", "c"} {
+			_, err = c.Get(ctx, key, loader)
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, loads)
+	})
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	t.Run("invalid max stale", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithStaleWhileRevalidate(0))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "maxStale must be greater than 0")
+	})
+
+	// versionedLoader returns an incrementing value per load, failing while fail is set
+	type versionedLoader struct {
+		lock    sync.Mutex
+		version int
+		fail    bool
+	}
+	load := func(l *versionedLoader) func(context.Context, string) (int, error) {
+		return func(context.Context, string) (int, error) {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			l.version++
+			if l.fail {
+				return 0, errors.New("service unavailable")
+			}
+			return l.version, nil
+		}
+	}
+	loads := func(l *versionedLoader) int {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return l.version
+	}
+
+	t.Run("fresh entry is served without loading", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStaleWhileRevalidate(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		l := &versionedLoader{}
+
+		v, err := c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+
+		fakeClock.Advance(30 * time.Second)
+
+		v, err = c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+		require.Equal(t, 1, loads(l))
+	})
+
+	t.Run("stale entry is served while refreshed in the background", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStaleWhileRevalidate(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		l := &versionedLoader{}
+
+		_, err = c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+
+		fakeClock.Advance(90 * time.Second)
+
+		v, err := c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+
+		require.Eventually(t, func() bool {
+			v, err := c.Get(ctx, "key", load(l))
+			return err == nil && v == 2
+		}, time.Second, time.Millisecond)
+		require.Equal(t, 2, loads(l))
+	})
+
+	t.Run("failed refresh keeps serving stale until max stale passes", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStaleWhileRevalidate(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		l := &versionedLoader{}
+
+		_, err = c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+
+		l.lock.Lock()
+		l.fail = true
+		l.lock.Unlock()
+		fakeClock.Advance(90 * time.Second)
+
+		v, err := c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+
+		require.Eventually(t, func() bool {
+			return loads(l) >= 2
+		}, time.Second, time.Millisecond)
+
+		v, err = c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+
+		fakeClock.Advance(time.Minute)
+
+		_, err = c.Get(ctx, "key", load(l))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "service unavailable")
+	})
+
+	t.Run("fully expired entry blocks on a fresh load", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStaleWhileRevalidate(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		l := &versionedLoader{}
+
+		_, err = c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+
+		fakeClock.Advance(3 * time.Minute)
+
+		v, err := c.Get(ctx, "key", load(l))
+		require.NoError(t, err)
+		require.Equal(t, 2, v)
+		require.Equal(t, 2, loads(l))
+	})
+}
+
+func TestNegativeCaching(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	errNotFound := errors.New("user not found")
+
+	t.Run("invalid options", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithNegativeTTL(0))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "negative ttl must be greater than 0")
+
+		c, err = cache.New(mockService, 5*time.Minute, cache.WithNegativeCacheable(nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "negative cacheable predicate is nil")
+	})
+
+	t.Run("cached not found error suppresses the second service call", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithClock(fakeClock), cache.WithNegativeTTL(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		mockService.EXPECT().
+			GetUser(ctx, "missing").
+			Return(service.User{}, errNotFound).
+			Times(1)
+
+		for i := 0; i < 2; i++ {
+			user, err := c.GetUser(ctx, "missing")
+			require.ErrorIs(t, err, errNotFound)
+			require.Equal(t, service.User{}, user)
+		}
+
+		// Once the negative ttl passes the service is called again
+		fakeClock.Advance(2 * time.Minute)
+
+		mockService.EXPECT().
+			GetUser(ctx, "missing").
+			Return(service.User{ID: "missing"}, nil).
+			Times(1)
+
+		user, err := c.GetUser(ctx, "missing")
+		require.NoError(t, err)
+		require.Equal(t, "missing", user.ID)
+	})
+
+	t.Run("only errors matching the predicate are cached", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute,
+			cache.WithNegativeTTL(time.Minute),
+			cache.WithNegativeCacheable(func(err error) bool { return errors.Is(err, errNotFound) }),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		mockService.EXPECT().
+			GetUser(ctx, "missing").
+			Return(service.User{}, errNotFound).
+			Times(1)
+		mockService.EXPECT().
+			GetUser(ctx, "flaky").
+			Return(service.User{}, errors.New("service unavailable")).
+			Times(2)
+
+		for i := 0; i < 2; i++ {
+			_, err = c.GetUser(ctx, "missing")
+			require.ErrorIs(t, err, errNotFound)
+
+			_, err = c.GetUser(ctx, "flaky")
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("load cancelled by its caller isn't cached", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](5*time.Minute, cache.WithNegativeTTL(time.Minute))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = c.Get(ctx, "key", func(ctx context.Context, _ string) (int, error) {
+			cancel()
+			return 0, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.Canceled)
+
+		// A fresh caller runs the loader rather than getting the cancellation back
+		loads := 0
+		v, err := c.Get(context.Background(), "key", func(context.Context, string) (int, error) {
+			loads++
+			return 42, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 42, v)
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("load abandoned while queued isn't cached", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](5*time.Minute, cache.WithNegativeTTL(time.Minute), cache.WithMaxConcurrentLoads(1, cache.QueueLoads))
+		require.NoError(t, err)
+
+		// Hold the only load slot
+		started, release := make(chan struct{}), make(chan struct{})
+		go func() {
+			_, _ = c.Get(context.Background(), "other", func(context.Context, string) (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = c.Get(ctx, "key", func(context.Context, string) (int, error) {
+			t.Error("queued load ran after its caller gave up")
+			return 0, nil
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		close(release)
+
+		v, err := c.Get(context.Background(), "key", func(context.Context, string) (int, error) {
+			return 42, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 42, v)
+	})
+}
+
+func TestCleanupInterval(t *testing.T) {
+	t.Run("invalid interval", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithCleanupInterval(0))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "cleanup interval must be greater than 0")
+	})
+
+	t.Run("expired entries are removed without being accessed", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithCleanupInterval(30*time.Second))
+		require.NoError(t, err)
+		defer c.Close()
+
+		ctx := context.Background()
+		loader := func(context.Context, string) (int, error) { return 1, nil }
+
+		_, err = c.Get(ctx, "old", loader)
+		require.NoError(t, err)
+
+		fakeClock.Advance(45 * time.Second)
+
+		_, err = c.Get(ctx, "new", loader)
+		require.NoError(t, err)
+
+		fakeClock.Advance(30 * time.Second)
+
+		require.Eventually(t, func() bool {
+			return c.Len() == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("close stops the janitor", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithCleanupInterval(30*time.Second))
+		require.NoError(t, err)
+
+		_, err = c.Get(context.Background(), "key", func(context.Context, string) (int, error) { return 1, nil })
+		require.NoError(t, err)
+
+		require.NoError(t, c.Close())
+		require.NoError(t, c.Close())
+
+		fakeClock.Advance(2 * time.Minute)
+		require.Never(t, func() bool {
+			return c.Len() == 0
+		}, 50*time.Millisecond, time.Millisecond)
+	})
+}
+
+func TestSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	seededUser := service.User{ID: "1", Name: "Seeded User", Email: "seeded@example.com"}
+
+	t.Run("set value is a cache hit", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		c.Set("1", seededUser)
+
+		user, err := c.GetUser(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, seededUser, user)
+	})
+
+	t.Run("set overwrites a loaded value", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			Return(service.User{ID: "1", Name: "Old Name"}, nil).
+			Times(1)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+
+		c.Set("1", seededUser)
+
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, seededUser, user)
+	})
+
+	t.Run("set with ttl expires independently", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		c.SetWithTTL("1", seededUser, time.Minute)
+		c.Set("2", service.User{ID: "2"})
+
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, seededUser, user)
+
+		fakeClock.Advance(2 * time.Minute)
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			Return(seededUser, nil).
+			Times(1)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+
+		user, err = c.GetUser(ctx, "2")
+		require.NoError(t, err)
+		require.Equal(t, "2", user.ID)
+	})
+}
+
+func TestLoaderRetries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expectedUser := service.User{ID: "1", Name: "Test User"}
+
+	t.Run("invalid options", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithLoaderRetries(0, time.Second))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "loader retries must be greater than 0")
+
+		c, err = cache.NewCache[string, int](time.Minute, cache.WithLoaderRetries(1, -time.Second))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "loader backoff cannot be negative")
+	})
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithClock(fakeClock), cache.WithLoaderRetries(2, time.Second))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errors.New("service temporarily unavailable")).Times(2),
+			mockService.EXPECT().GetUser(ctx, "1").Return(expectedUser, nil).Times(1),
+		)
+
+		type result struct {
+			user service.User
+			err  error
+		}
+		resultChan := make(chan result)
+		go func() {
+			user, err := c.GetUser(ctx, "1")
+			resultChan <- result{user, err}
+		}()
+
+		for i := 0; i < 2; i++ {
+			fakeClock.BlockUntilContext(ctx, 1)
+			fakeClock.Advance(time.Second)
+		}
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, expectedUser, res.user)
+
+		// The retried value is cached
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, expectedUser, user)
+	})
+
+	t.Run("last error is returned once retries are exhausted", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithLoaderRetries(2, 0))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errors.New("first failure")).Times(2),
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errors.New("last failure")).Times(1),
+		)
+
+		_, err = c.GetUser(ctx, "1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "last failure")
+	})
+
+	t.Run("context cancelled during backoff", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithClock(fakeClock), cache.WithLoaderRetries(2, time.Second))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errors.New("service temporarily unavailable")).Times(1)
+
+		errChan := make(chan error)
+		go func() {
+			_, err := c.GetUser(ctx, "1")
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		err = <-errChan
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "service temporarily unavailable")
+	})
+}
+
+func TestPeek(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expectedUser := service.User{ID: "1", Name: "Test User"}
+	errNotFound := errors.New("user not found")
+
+	t.Run("hit", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		c.Set("1", expectedUser)
+
+		user, ok := c.Peek("1")
+		require.True(t, ok)
+		require.Equal(t, expectedUser, user)
+	})
+
+	t.Run("miss never calls the service", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute)
+		require.NoError(t, err)
+
+		user, ok := c.Peek("1")
+		require.False(t, ok)
+		require.Equal(t, service.User{}, user)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		c.Set("1", expectedUser)
+		fakeClock.Advance(6 * time.Minute)
+
+		user, ok := c.Peek("1")
+		require.False(t, ok)
+		require.Equal(t, service.User{}, user)
+	})
+
+	t.Run("negatively cached error", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithNegativeTTL(time.Minute))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errNotFound).Times(1)
+
+		_, err = c.GetUser(ctx, "1")
+		require.ErrorIs(t, err, errNotFound)
+
+		_, ok := c.Peek("1")
+		require.False(t, ok)
+	})
+
+	t.Run("does not extend recency", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithMaxEntries(2))
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+
+		// Peeking the oldest entry leaves it first in line for eviction
+		_, ok := c.Peek("a")
+		require.True(t, ok)
+
+		c.Set("c", 3)
+
+		_, ok = c.Peek("a")
+		require.False(t, ok)
+		_, ok = c.Peek("b")
+		require.True(t, ok)
+		_, ok = c.Peek("c")
+		require.True(t, ok)
+	})
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	loader := func(context.Context, string) (int, error) { return 1, nil }
+
+	t.Run("missing key", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		ttl, ok := c.TTL("missing")
+		require.False(t, ok)
+		require.Zero(t, ttl)
+	})
+
+	t.Run("fresh entry", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		_, err = c.Get(ctx, "key", loader)
+		require.NoError(t, err)
+
+		ttl, ok := c.TTL("key")
+		require.True(t, ok)
+		require.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("partially aged entry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		_, err = c.Get(ctx, "key", loader)
+		require.NoError(t, err)
+
+		fakeClock.Advance(45 * time.Second)
+
+		ttl, ok := c.TTL("key")
+		require.True(t, ok)
+		require.Equal(t, 15*time.Second, ttl)
+	})
+
+	t.Run("entry set with its own ttl", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		c.SetWithTTL("key", 1, 10*time.Second)
+
+		ttl, ok := c.TTL("key")
+		require.True(t, ok)
+		require.Equal(t, 10*time.Second, ttl)
+	})
+
+	t.Run("expired entry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		_, err = c.Get(ctx, "key", loader)
+		require.NoError(t, err)
+
+		fakeClock.Advance(61 * time.Second)
+
+		ttl, ok := c.TTL("key")
+		require.False(t, ok)
+		require.Zero(t, ttl)
+	})
+}
+
+func TestOnEvict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type evicted struct {
+		id     string
+		user   service.User
+		reason cache.EvictionReason
+	}
+
+	user1 := service.User{ID: "1", Name: "John Doe"}
+	user2 := service.User{ID: "2", Name: "Jane Doe"}
+	user3 := service.User{ID: "3", Name: "Jim Doe"}
+
+	t.Run("invalid options", func(t *testing.T) {
+		mockService := mocks.NewMockUserService(ctrl)
+
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithOnEvict[string, service.User](nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "onEvict is nil")
+
+		c, err = cache.New(mockService, 5*time.Minute, cache.WithOnEvict(func(int, service.User, cache.EvictionReason) {}))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "onEvict key and value types don't match the cache")
+	})
+
+	t.Run("explicit delete", func(t *testing.T) {
+		var got []evicted
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithOnEvict(func(id string, user service.User, reason cache.EvictionReason) {
+			got = append(got, evicted{id, user, reason})
+		}))
+		require.NoError(t, err)
+
+		c.Set("1", user1)
+		c.Set("2", user2)
+		c.Delete("1")
+		c.Delete("1")
+		c.Clear()
+
+		require.Equal(t, []evicted{
+			{"1", user1, cache.EvictionDeleted},
+			{"2", user2, cache.EvictionDeleted},
+		}, got)
+	})
+
+	t.Run("lru eviction", func(t *testing.T) {
+		var got []evicted
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, 5*time.Minute, cache.WithMaxEntries(2), cache.WithOnEvict(func(id string, user service.User, reason cache.EvictionReason) {
+			got = append(got, evicted{id, user, reason})
+		}))
+		require.NoError(t, err)
+
+		c.Set("1", user1)
+		c.Set("2", user2)
+		c.Set("3", user3)
+
+		require.Equal(t, []evicted{{"1", user1, cache.EvictionCapacity}}, got)
+	})
+
+	t.Run("ttl expiry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		var lock sync.Mutex
+		var got []evicted
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithCleanupInterval(time.Minute),
+			cache.WithOnEvict(func(id string, user service.User, reason cache.EvictionReason) {
+				lock.Lock()
+				defer lock.Unlock()
+				got = append(got, evicted{id, user, reason})
+			}),
+		)
+		require.NoError(t, err)
+		defer c.Close()
+
+		c.Set("1", user1)
+		fakeClock.Advance(90 * time.Second)
+
+		require.Eventually(t, func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return len(got) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, evicted{"1", user1, cache.EvictionExpired}, got[0])
+	})
+
+	t.Run("reloading an expired entry evicts the old value", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		var got []evicted
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithClock(fakeClock), cache.WithOnEvict(func(id string, user service.User, reason cache.EvictionReason) {
+			got = append(got, evicted{id, user, reason})
+		}))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		updated := service.User{ID: "1", Name: "John Updated"}
+
+		mockService.EXPECT().
+			GetUser(ctx, "1").
+			Return(updated, nil).
+			Times(1)
+
+		c.Set("1", user1)
+		fakeClock.Advance(2 * time.Minute)
+
+		user, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, updated, user)
+		require.Equal(t, []evicted{{"1", user1, cache.EvictionExpired}}, got)
+	})
+
+	t.Run("callback can re-enter the cache", func(t *testing.T) {
+		var c *cache.Cache[string, int]
+		var lenAtEviction int
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithOnEvict(func(key string, value int, reason cache.EvictionReason) {
+			lenAtEviction = c.Len()
+			c.Set("replacement", value)
+		}))
+		require.NoError(t, err)
+
+		c.Set("key", 1)
+		c.Delete("key")
+
+		require.Equal(t, 0, lenAtEviction)
+		require.Equal(t, 1, c.Len())
+	})
+}
+
+func TestClose(t *testing.T) {
+	t.Run("close twice is a no-op", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, c.Close())
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("calls after close return the sentinel error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(gomock.Any(), "user-1").Return(service.User{ID: "user-1"}, nil)
+
+		_, err = c.GetUser(ctx, "user-1")
+		require.NoError(t, err)
+
+		require.NoError(t, c.Close())
+
+		// Cached and uncached keys alike are refused
+		for _, id := range []string{"user-1", "user-2"} {
+			user, err := c.GetUser(ctx, id)
+			require.ErrorIs(t, err, cache.ErrCacheClosed)
+			require.Empty(t, user)
+		}
+	})
+
+	t.Run("in-flight loads complete when closed concurrently", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithCleanupInterval(time.Millisecond))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		errChan := make(chan error)
+		go func() {
+			_, err := c.Get(ctx, "key", func(context.Context, string) (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+			errChan <- err
+		}()
+		<-started
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = c.Close()
+				_, err := c.Get(ctx, "other", func(context.Context, string) (int, error) { return 1, nil })
+				require.ErrorIs(t, err, cache.ErrCacheClosed)
+			}()
+		}
+		wg.Wait()
+
+		close(release)
+		require.NoError(t, <-errChan)
+	})
+}
+
+func TestGetWithTTL(t *testing.T) {
+	fakeClock := clockwork.NewFakeClock()
+	c, err := cache.NewCache[string, string](10*time.Minute, cache.WithClock(fakeClock))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	loads := map[string]int{}
+	loader := func(_ context.Context, key string) (string, time.Duration, error) {
+		loads[key]++
+		switch key {
+		case "volatile":
+			return "value", time.Minute, nil
+		case "invalid":
+			return "value", -time.Minute, nil
+		default:
+			return "value", 0, nil
+		}
+	}
+
+	for _, key := range []string{"volatile", "default", "invalid"} {
+		_, err := c.GetWithTTL(ctx, key, loader)
+		require.NoError(t, err)
+	}
+
+	ttl, ok := c.TTL("volatile")
+	require.True(t, ok)
+	require.Equal(t, time.Minute, ttl)
+
+	// Zero and negative TTLs fall back to the configured default
+	for _, key := range []string{"default", "invalid"} {
+		ttl, ok := c.TTL(key)
+		require.True(t, ok)
+		require.Equal(t, 10*time.Minute, ttl)
+	}
+
+	// Only the volatile entry has expired after 2 minutes
+	fakeClock.Advance(2 * time.Minute)
+	for _, key := range []string{"volatile", "default", "invalid"} {
+		_, err := c.GetWithTTL(ctx, key, loader)
+		require.NoError(t, err)
+	}
+	require.Equal(t, map[string]int{"volatile": 2, "default": 1, "invalid": 1}, loads)
+
+	// The default entries expire on their own schedule
+	fakeClock.Advance(9 * time.Minute)
+	_, err = c.GetWithTTL(ctx, "default", loader)
+	require.NoError(t, err)
+	require.Equal(t, 2, loads["default"])
+}
+
+func TestMaxConcurrentLoads(t *testing.T) {
+	t.Run("invalid options", func(t *testing.T) {
+		c, err := cache.NewCache[string, string](time.Minute, cache.WithMaxConcurrentLoads(0, cache.QueueLoads))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "max concurrent loads must be greater than 0")
+
+		c, err = cache.NewCache[string, string](time.Minute, cache.WithMaxConcurrentLoads(1, cache.LoadLimitPolicy(99)))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "unknown load limit policy")
+	})
+
+	t.Run("loader concurrency never exceeds the cap for distinct cold keys", func(t *testing.T) {
+		const maxLoads = 3
+
+		c, err := cache.NewCache[int, int](time.Minute, cache.WithMaxConcurrentLoads(maxLoads, cache.QueueLoads))
+		require.NoError(t, err)
+
+		var (
+			lock              sync.Mutex
+			inFlight, maxSeen int
+		)
+		release := make(chan struct{})
+		loader := func(_ context.Context, key int) (int, error) {
+			lock.Lock()
+			inFlight++
+			maxSeen = max(maxSeen, inFlight)
+			lock.Unlock()
+
+			<-release
+
+			lock.Lock()
+			inFlight--
+			lock.Unlock()
+			return key * 10, nil
+		}
+
+		ctx := context.Background()
+		const keys = 20
+		var wg sync.WaitGroup
+		errs := make([]error, keys)
+		values := make([]int, keys)
+		for i := range keys {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				values[i], errs[i] = c.Get(ctx, i, loader)
+			}()
+		}
+
+		// The cap is reached, and stays reached while the rest queue
+		require.Eventually(t, func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			return inFlight == maxLoads
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		for i := range keys {
+			require.NoError(t, errs[i])
+			require.Equal(t, i*10, values[i])
+		}
+		require.Equal(t, maxLoads, maxSeen)
+	})
+
+	t.Run("reject policy fails excess loads without caching the rejection", func(t *testing.T) {
+		c, err := cache.NewCache[string, string](time.Minute,
+			cache.WithMaxConcurrentLoads(1, cache.RejectLoads),
+			cache.WithNegativeTTL(time.Minute),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := c.Get(ctx, "slow", func(context.Context, string) (string, error) {
+				close(started)
+				<-release
+				return "slow", nil
+			})
+			done <- err
+		}()
+		<-started
+
+		calls := 0
+		loader := func(context.Context, string) (string, error) {
+			calls++
+			return "value", nil
+		}
+
+		_, err = c.Get(ctx, "other", loader)
+		require.ErrorIs(t, err, cache.ErrLoadLimitExceeded)
+		require.Zero(t, calls)
+
+		close(release)
+		require.NoError(t, <-done)
+
+		// The rejection wasn't negatively cached, so the key loads once a slot is free
+		value, err := c.Get(ctx, "other", loader)
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("context cancelled while queued", func(t *testing.T) {
+		c, err := cache.NewCache[string, string](time.Minute, cache.WithMaxConcurrentLoads(1, cache.QueueLoads))
+		require.NoError(t, err)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := c.Get(context.Background(), "slow", func(context.Context, string) (string, error) {
+				close(started)
+				<-release
+				return "slow", nil
+			})
+			done <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		queued := make(chan error)
+		called := false
+		go func() {
+			_, err := c.Get(ctx, "other", func(context.Context, string) (string, error) {
+				called = true
+				return "value", nil
+			})
+			queued <- err
+		}()
+
+		cancel()
+		require.ErrorIs(t, <-queued, context.Canceled)
+		require.False(t, called)
+
+		close(release)
+		require.NoError(t, <-done)
+	})
+}
+
+func TestServeStaleOnError(t *testing.T) {
+	user := service.User{ID: "1", Name: "Test User", Email: "test@example.com"}
+	errUnavailable := errors.New("service unavailable")
+
+	t.Run("expired value is served when the loader fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithClock(fakeClock), cache.WithServeStaleOnError())
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").Return(user, nil),
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errUnavailable).Times(2),
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{ID: "1", Name: "Renamed"}, nil),
+		)
+
+		// Warm the cache, then let the entry expire
+		got, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+		fakeClock.Advance(time.Hour)
+
+		got, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+
+		// The stale value isn't treated as fresh, so every lookup tries the loader again
+		_, ok := c.Peek("1")
+		require.False(t, ok)
+		got, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+
+		got, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, "Renamed", got.Name)
+	})
+
+	t.Run("expired value isn't served while the loader succeeds", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithClock(fakeClock), cache.WithServeStaleOnError())
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		renamed := service.User{ID: "1", Name: "Renamed"}
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").Return(user, nil),
+			mockService.EXPECT().GetUser(ctx, "1").Return(renamed, nil),
+		)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		fakeClock.Advance(time.Hour)
+
+		got, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, renamed, got)
+	})
+
+	t.Run("error is returned without a previous value", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithServeStaleOnError())
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errUnavailable)
+
+		_, err = c.GetUser(ctx, "1")
+		require.ErrorIs(t, err, errUnavailable)
+	})
+
+	t.Run("error is returned without the option", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").Return(user, nil),
+			mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errUnavailable),
+		)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		fakeClock.Advance(time.Hour)
+
+		_, err = c.GetUser(ctx, "1")
+		require.ErrorIs(t, err, errUnavailable)
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	// receive waits for the next event on ch
+	receive := func(t *testing.T, ch <-chan cache.ExpiryEvent[string]) cache.ExpiryEvent[string] {
+		t.Helper()
+		select {
+		case event, ok := <-ch:
+			require.True(t, ok, "channel closed")
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no expiry event delivered")
+			return cache.ExpiryEvent[string]{}
+		}
+	}
+
+	t.Run("every subscriber is notified when the janitor evicts an expired entry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithCleanupInterval(90*time.Second))
+		require.NoError(t, err)
+		defer c.Close()
+
+		first, second := c.Subscribe(), c.Subscribe()
+		c.Set("key", 1)
+
+		fakeClock.Advance(90 * time.Second)
+
+		want := cache.ExpiryEvent[string]{Key: "key", At: fakeClock.Now()}
+		require.Equal(t, want, receive(t, first))
+		require.Equal(t, want, receive(t, second))
+	})
+
+	t.Run("replacing an expired entry is an expiry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		events := c.Subscribe()
+		c.Set("key", 1)
+
+		// A fresh entry being replaced is an update, not an expiry
+		c.Set("key", 2)
+		require.Empty(t, events)
+
+		fakeClock.Advance(2 * time.Minute)
+		c.Set("key", 3)
+		require.Equal(t, "key", receive(t, events).Key)
+	})
+
+	t.Run("deleted entries aren't expiries", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute)
+		require.NoError(t, err)
+
+		events := c.Subscribe()
+		c.Set("key", 1)
+		c.Delete("key")
+		require.Empty(t, events)
+	})
+
+	t.Run("close closes every subscription", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute)
+		require.NoError(t, err)
+
+		first, second := c.Subscribe(), c.Subscribe()
+		require.NoError(t, c.Close())
+
+		_, ok := <-first
+		require.False(t, ok)
+		_, ok = <-second
+		require.False(t, ok)
+
+		// Closing again or subscribing after close doesn't panic
+		require.NoError(t, c.Close())
+		_, ok = <-c.Subscribe()
+		require.False(t, ok)
+	})
+}
+
+func TestLoaderTimeout(t *testing.T) {
+	user := service.User{ID: "1", Name: "Test User", Email: "test@example.com"}
+
+	// blockUntilDone is a loader that never answers before its context is done
+	blockUntilDone := func(ctx context.Context, _ string) (service.User, error) {
+		<-ctx.Done()
+		return service.User{}, ctx.Err()
+	}
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithLoaderTimeout(0))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "loader timeout must be greater than 0")
+	})
+
+	t.Run("slow service exceeds the timeout", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		userService, err := service.NewUserService(500*time.Millisecond, service.WithClock(fakeClock))
+		require.NoError(t, err)
+		c, err := cache.New(userService, time.Minute, cache.WithClock(fakeClock), cache.WithLoaderTimeout(200*time.Millisecond))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		errChan := make(chan error)
+		go func() {
+			_, err := c.GetUser(ctx, "1")
+			errChan <- err
+		}()
+
+		// Wait for both the loader's deadline and the service's delay to be scheduled
+		fakeClock.BlockUntilContext(ctx, 2)
+		fakeClock.Advance(200 * time.Millisecond)
+
+		err = <-errChan
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Contains(t, err.Error(), "loading 1 timed out after 200ms")
+	})
+
+	t.Run("fast loader is unaffected", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithLoaderTimeout(time.Second))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(gomock.Any(), "1").DoAndReturn(func(ctx context.Context, _ string) (service.User, error) {
+			_, ok := ctx.Deadline()
+			require.True(t, ok)
+			return user, nil
+		})
+
+		got, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+	})
+
+	t.Run("caller giving up isn't reported as a timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute, cache.WithClock(fakeClock), cache.WithLoaderTimeout(time.Second))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		mockService.EXPECT().GetUser(gomock.Any(), "1").DoAndReturn(func(ctx context.Context, id string) (service.User, error) {
+			close(started)
+			return blockUntilDone(ctx, id)
+		})
+
+		errChan := make(chan error)
+		go func() {
+			_, err := c.GetUser(ctx, "1")
+			errChan <- err
+		}()
+
+		<-started
+		cancel()
+		err = <-errChan
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("stale value is served when a reload times out", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithLoaderTimeout(200*time.Millisecond),
+			cache.WithServeStaleOnError(),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(gomock.Any(), "1").Return(user, nil),
+			mockService.EXPECT().GetUser(gomock.Any(), "1").DoAndReturn(blockUntilDone),
+		)
+
+		got, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+		fakeClock.Advance(time.Hour)
+
+		type result struct {
+			user service.User
+			err  error
+		}
+		resultChan := make(chan result)
+		go func() {
+			user, err := c.GetUser(ctx, "1")
+			resultChan <- result{user, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(200 * time.Millisecond)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, user, res.user)
+	})
+}
+
+// lateStore misses the first lookup, storing late as if another caller's load finished straight after it
+type lateStore struct {
+	*cache.MemoryStore[string, service.User]
+	late cache.Entry[service.User]
+	seen bool
+}
+
+func (s *lateStore) Get(key string) (cache.Entry[service.User], bool) {
+	if !s.seen {
+		s.seen = true
+		s.MemoryStore.Set(key, s.late)
+		return cache.Entry[service.User]{}, false
+	}
+	return s.MemoryStore.Get(key)
+}
+
+func TestObserver(t *testing.T) {
+	user := service.User{ID: "1", Name: "Test User", Email: "test@example.com"}
+
+	type observation struct {
+		id      string
+		outcome cache.Outcome
+		d       time.Duration
+	}
+
+	t.Run("nil observer", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithObserver(nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "observer is nil")
+	})
+
+	t.Run("every outcome is classified", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		var observed []observation
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithObserver(func(id string, outcome cache.Outcome, d time.Duration) {
+				observed = append(observed, observation{id, outcome, d})
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		// Loads take 500ms on the fake clock
+		slowly := func(user service.User, err error) func(context.Context, string) (service.User, error) {
+			return func(context.Context, string) (service.User, error) {
+				fakeClock.Advance(500 * time.Millisecond)
+				return user, err
+			}
+		}
+		gomock.InOrder(
+			mockService.EXPECT().GetUser(ctx, "1").DoAndReturn(slowly(user, nil)),
+			mockService.EXPECT().GetUser(ctx, "1").DoAndReturn(slowly(user, nil)),
+			mockService.EXPECT().GetUser(ctx, "2").DoAndReturn(slowly(service.User{}, errors.New("service unavailable"))),
+		)
+
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+
+		fakeClock.Advance(2 * time.Minute)
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+
+		_, err = c.GetUser(ctx, "2")
+		require.Error(t, err)
+
+		require.Equal(t, []observation{
+			{"1", cache.OutcomeMiss, 500 * time.Millisecond},
+			{"1", cache.OutcomeHit, 0},
+			{"1", cache.OutcomeExpired, 500 * time.Millisecond},
+			{"2", cache.OutcomeError, 500 * time.Millisecond},
+		}, observed)
+	})
+
+	t.Run("value loaded by another caller while waiting for the lock is a hit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		store := &lateStore{
+			MemoryStore: cache.NewMemoryStore[string, service.User](),
+			late:        cache.Entry[service.User]{Value: user, ExpiresAt: fakeClock.Now().Add(time.Minute)},
+		}
+		var observed []observation
+		c, err := cache.New(mocks.NewMockUserService(ctrl), time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithStore[string, service.User](store),
+			cache.WithObserver(func(id string, outcome cache.Outcome, d time.Duration) {
+				observed = append(observed, observation{id, outcome, d})
+			}),
+		)
+		require.NoError(t, err)
+
+		got, err := c.GetUser(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, user, got)
+		require.Equal(t, []observation{{"1", cache.OutcomeHit, 0}}, observed)
+	})
+
+	t.Run("error paths are observed once", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockUserService(ctrl)
+		var observed []observation
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(clockwork.NewFakeClock()),
+			cache.WithNegativeTTL(time.Minute),
+			cache.WithObserver(func(id string, outcome cache.Outcome, d time.Duration) {
+				observed = append(observed, observation{id, outcome, d})
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(ctx, "1").Return(service.User{}, errors.New("user not found"))
+
+		// The second lookup is answered by the negatively cached error
+		for range 2 {
+			_, err = c.GetUser(ctx, "1")
+			require.Error(t, err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = c.GetUser(cancelled, "2")
+		require.ErrorIs(t, err, context.Canceled)
+
+		require.NoError(t, c.Close())
+		_, err = c.GetUser(ctx, "3")
+		require.ErrorIs(t, err, cache.ErrCacheClosed)
+
+		require.Equal(t, []observation{
+			{"1", cache.OutcomeError, 0},
+			{"1", cache.OutcomeError, 0},
+			{"2", cache.OutcomeError, 0},
+			{"3", cache.OutcomeError, 0},
+		}, observed)
+	})
+
+	t.Run("outcome string", func(t *testing.T) {
+		require.Equal(t, "Hit", cache.OutcomeHit.String())
+		require.Equal(t, "Miss", cache.OutcomeMiss.String())
+		require.Equal(t, "Expired", cache.OutcomeExpired.String())
+		require.Equal(t, "Error", cache.OutcomeError.String())
+		require.Equal(t, "Unknown", cache.Outcome(99).String())
+	})
+}
+
+func TestWriteThrough(t *testing.T) {
+	user := service.User{ID: "1", Name: "Test User", Email: "test@example.com"}
+	updated := service.User{ID: "1", Name: "Renamed User", Email: "test@example.com"}
+
+	t.Run("nil writer", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithWriter(nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "writer is nil")
+	})
+
+	t.Run("writer not configured", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		c, err := cache.New(mocks.NewMockUserService(ctrl), time.Minute)
+		require.NoError(t, err)
+
+		err = c.WriteThrough(context.Background(), "1", updated)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "writer is not configured")
+	})
+
+	t.Run("successful write updates the store and the cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		store := map[string]service.User{}
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithWriter(func(_ context.Context, id string, user service.User) error {
+				store[id] = user
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(ctx, "1").Return(user, nil)
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		fakeClock.Advance(30 * time.Second)
+
+		require.NoError(t, c.WriteThrough(ctx, "1", updated))
+		require.Equal(t, updated, store["1"])
+
+		// Served from the cache without calling the service, with a fresh TTL
+		got, err := c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, updated, got)
+		ttl, ok := c.TTL("1")
+		require.True(t, ok)
+		require.Equal(t, time.Minute, ttl)
+	})
+
+	t.Run("failed write leaves the cache untouched", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockUserService(ctrl)
+		errWrite := errors.New("store unavailable")
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithClock(fakeClock),
+			cache.WithWriter(func(context.Context, string, service.User) error {
+				return errWrite
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		mockService.EXPECT().GetUser(ctx, "1").Return(user, nil)
+		_, err = c.GetUser(ctx, "1")
+		require.NoError(t, err)
+		fakeClock.Advance(30 * time.Second)
+
+		err = c.WriteThrough(ctx, "1", updated)
+		require.ErrorIs(t, err, errWrite)
+		require.Contains(t, err.Error(), "failed to write user")
+
+		got, ok := c.Peek("1")
+		require.True(t, ok)
+		require.Equal(t, user, got)
+		ttl, ok := c.TTL("1")
+		require.True(t, ok)
+		require.Equal(t, 30*time.Second, ttl)
+
+		// A key that wasn't cached isn't cached by a failed write either
+		require.Error(t, c.WriteThrough(ctx, "2", service.User{ID: "2"}))
+		_, ok = c.Peek("2")
+		require.False(t, ok)
+	})
+
+	t.Run("write during a load isn't overwritten by the loaded value", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockUserService(ctrl)
+		c, err := cache.New(mockService, time.Minute,
+			cache.WithWriter(func(context.Context, string, service.User) error { return nil }),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started, release := make(chan struct{}), make(chan struct{})
+		mockService.EXPECT().GetUser(ctx, "1").DoAndReturn(func(context.Context, string) (service.User, error) {
+			close(started)
+			<-release
+			return user, nil // Read before the write
+		})
+
+		loaded := make(chan service.User)
+		go func() {
+			got, _ := c.GetUser(ctx, "1")
+			loaded <- got
+		}()
+		<-started
+
+		require.NoError(t, c.WriteThrough(ctx, "1", updated))
+		close(release)
+
+		// The caller that started the load gets the written value too
+		require.Equal(t, updated, <-loaded)
+		got, ok := c.Peek("1")
+		require.True(t, ok)
+		require.Equal(t, updated, got)
+	})
+}
+
+// stubStore wraps a memory store, recording the keys read from and written to it
+type stubStore struct {
+	*cache.MemoryStore[string, int]
+	gets, sets, deletes []string
+}
+
+func (s *stubStore) Get(key string) (cache.Entry[int], bool) {
+	s.gets = append(s.gets, key)
+	return s.MemoryStore.Get(key)
+}
+
+func (s *stubStore) Set(key string, e cache.Entry[int]) {
+	s.sets = append(s.sets, key)
+	s.MemoryStore.Set(key, e)
+}
+
+func (s *stubStore) Delete(key string) {
+	s.deletes = append(s.deletes, key)
+	s.MemoryStore.Delete(key)
+}
+
+func TestStore(t *testing.T) {
+	t.Run("invalid options", func(t *testing.T) {
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithStore[string, int](nil))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "store is nil")
+
+		c, err = cache.NewCache[string, int](time.Minute, cache.WithStore(cache.NewMemoryStore[int, int]()))
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "store key and value types don't match the cache")
+	})
+
+	t.Run("reads and writes go through the store", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		store := &stubStore{MemoryStore: cache.NewMemoryStore[string, int]()}
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStore(store))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		loads := 0
+		loader := func(context.Context, string) (int, error) {
+			loads++
+			return 42, nil
+		}
+
+		v, err := c.Get(ctx, "a", loader)
+		require.NoError(t, err)
+		require.Equal(t, 42, v)
+		require.Equal(t, []string{"a"}, store.sets)
+
+		e, ok := store.MemoryStore.Get("a")
+		require.True(t, ok)
+		require.Equal(t, 42, e.Value)
+		require.Equal(t, fakeClock.Now().Add(time.Minute), e.ExpiresAt)
+
+		// Served from the store without loading again
+		store.gets = nil
+		v, err = c.Get(ctx, "a", loader)
+		require.NoError(t, err)
+		require.Equal(t, 42, v)
+		require.Equal(t, 1, loads)
+		require.Equal(t, []string{"a"}, store.gets)
+
+		c.Set("b", 7)
+		require.Equal(t, []string{"a", "b"}, store.sets)
+		require.Equal(t, 2, c.Len())
+
+		c.Delete("a")
+		require.Equal(t, []string{"a"}, store.deletes)
+		_, ok = store.MemoryStore.Get("a")
+		require.False(t, ok)
+
+		c.Clear()
+		require.Equal(t, []string{"a", "b"}, store.deletes)
+		require.Zero(t, c.Len())
+	})
+
+	t.Run("entries already in the store are served", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		store := cache.NewMemoryStore[string, int]()
+		store.Set("a", cache.Entry[int]{Value: 1, ExpiresAt: fakeClock.Now().Add(time.Minute)})
+
+		c, err := cache.NewCache[string, int](time.Minute, cache.WithClock(fakeClock), cache.WithStore(store))
+		require.NoError(t, err)
+
+		v, ok := c.Peek("a")
+		require.True(t, ok)
+		require.Equal(t, 1, v)
+
+		v, err = c.Get(context.Background(), "a", func(context.Context, string) (int, error) {
+			t.Fatal("loader called for an entry in the store")
+			return 0, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+	})
+
+	t.Run("entries removed from the store behind the cache are skipped by capacity eviction", func(t *testing.T) {
+		var evicted []string
+		store := cache.NewMemoryStore[string, int]()
+		c, err := cache.NewCache[string, int](time.Minute,
+			cache.WithStore(store),
+			cache.WithMaxEntries(2),
+			cache.WithOnEvict(func(key string, _ int, _ cache.EvictionReason) { evicted = append(evicted, key) }),
+		)
+		require.NoError(t, err)
+
+		c.Set("a", 1)
+		c.Set("b", 2)
+		store.Delete("a")
+		c.Set("c", 3)
+		require.Empty(t, evicted)
+
+		c.Set("d", 4)
+		require.Equal(t, []string{"b"}, evicted)
+		require.Equal(t, 2, c.Len())
+	})
 }
@@ -0,0 +1,50 @@
+package cache
+
+// Store holds the cache's entries, letting a backend such as Redis or a sharded map replace the default
+// in-memory map. Calls are made with the cache's lock held, so a store only used by one cache needs no locking
+// of its own. Expiry, recency and eviction stay with the cache: a store just keeps whatever it is given.
+type Store[K comparable, V any] interface {
+	// Get returns the entry for key and whether it is present
+	Get(key K) (Entry[V], bool)
+	// Set adds or replaces the entry for key
+	Set(key K, e Entry[V])
+	// Delete removes the entry for key, doing nothing if it isn't present
+	Delete(key K)
+	// Range calls fn for every entry until fn returns false
+	Range(fn func(key K, e Entry[V]) bool)
+}
+
+// MemoryStore is the default Store, keeping entries in a map
+type MemoryStore[K comparable, V any] struct {
+	entries map[K]Entry[V]
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{entries: make(map[K]Entry[V])}
+}
+
+// Get returns the entry for key and whether it is present
+func (s *MemoryStore[K, V]) Get(key K) (Entry[V], bool) {
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+// Set adds or replaces the entry for key
+func (s *MemoryStore[K, V]) Set(key K, e Entry[V]) {
+	s.entries[key] = e
+}
+
+// Delete removes the entry for key
+func (s *MemoryStore[K, V]) Delete(key K) {
+	delete(s.entries, key)
+}
+
+// Range calls fn for every entry until fn returns false
+func (s *MemoryStore[K, V]) Range(fn func(key K, e Entry[V]) bool) {
+	for key, e := range s.entries {
+		if !fn(key, e) {
+			return
+		}
+	}
+}
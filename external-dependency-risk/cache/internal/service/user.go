@@ -5,40 +5,85 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
+
+	"github.com/jonboulle/clockwork"
 )
 
 // User represents a user entity
 type User struct {
-	ID       string    `json:"id"`
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Created  time.Time `json:"created"`
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
 }
 
 // userService simulates a slow external user service
 type userService struct {
-	users map[string]User
-	delay time.Duration
+	users  map[string]User
+	delay  time.Duration
+	random func() float64 // Returns a number in [0.0,1.0) used to simulate failures
+	clock  clockwork.Clock
+}
+
+// Option is a functional option for configuring the user service
+type Option func(*userService) error
+
+// WithRand sets the random source used to simulate failures
+func WithRand(r *rand.Rand) Option {
+	return func(s *userService) error {
+		if r == nil {
+			return errors.New("rand is nil")
+		}
+		// rand.Rand isn't safe for concurrent use
+		var lock sync.Mutex
+		s.random = func() float64 {
+			lock.Lock()
+			defer lock.Unlock()
+			return r.Float64()
+		}
+		return nil
+	}
+}
+
+// WithClock sets a custom clock for the service
+func WithClock(clock clockwork.Clock) Option {
+	return func(s *userService) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		s.clock = clock
+		return nil
+	}
 }
 
 // NewUserService creates a new user service
-func NewUserService(delay time.Duration) (*userService, error) {
+func NewUserService(delay time.Duration, opts ...Option) (*userService, error) {
 	if delay < 0 {
 		return nil, errors.New("delay must be greater than 0")
 	}
 
-	users := map[string]User{
-		"1": {ID: "1", Name: "Alice Johnson", Email: "alice@example.com", Created: time.Now().Add(-24 * time.Hour)},
-		"2": {ID: "2", Name: "Bob Smith", Email: "bob@example.com", Created: time.Now().Add(-12 * time.Hour)},
-		"3": {ID: "3", Name: "Charlie Brown", Email: "charlie@example.com", Created: time.Now().Add(-6 * time.Hour)},
-		"4": {ID: "4", Name: "Diana Prince", Email: "diana@example.com", Created: time.Now().Add(-3 * time.Hour)},
-		"5": {ID: "5", Name: "Eve Wilson", Email: "eve@example.com", Created: time.Now().Add(-1 * time.Hour)},
-	}
-	
 	s := &userService{
-		users: users,
-		delay: delay,
+		delay:  delay,
+		random: rand.Float64,             // Default to the global random source
+		clock:  clockwork.NewRealClock(), // Default to real clock
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.clock.Now()
+	s.users = map[string]User{
+		"1": {ID: "1", Name: "Alice Johnson", Email: "alice@example.com", Created: now.Add(-24 * time.Hour)},
+		"2": {ID: "2", Name: "Bob Smith", Email: "bob@example.com", Created: now.Add(-12 * time.Hour)},
+		"3": {ID: "3", Name: "Charlie Brown", Email: "charlie@example.com", Created: now.Add(-6 * time.Hour)},
+		"4": {ID: "4", Name: "Diana Prince", Email: "diana@example.com", Created: now.Add(-3 * time.Hour)},
+		"5": {ID: "5", Name: "Eve Wilson", Email: "eve@example.com", Created: now.Add(-1 * time.Hour)},
 	}
 
 	return s, nil
@@ -48,20 +93,20 @@ func NewUserService(delay time.Duration) (*userService, error) {
 func (s *userService) GetUser(ctx context.Context, id string) (User, error) {
 	// Simulate network delay
 	select {
-	case <-time.After(s.delay):
+	case <-s.clock.After(s.delay):
 	case <-ctx.Done():
 		return User{}, ctx.Err()
 	}
-	
+
 	// Simulate occasional failures
-	if rand.Float32() < 0.1 { // 10% failure rate
+	if s.random() < 0.1 { // 10% failure rate
 		return User{}, errors.New("service temporarily unavailable")
 	}
-	
+
 	user, exists := s.users[id]
 	if !exists {
 		return User{}, fmt.Errorf("user with id %s not found", id)
 	}
-	
+
 	return user, nil
-}
\ No newline at end of file
+}
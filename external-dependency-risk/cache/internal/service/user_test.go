@@ -0,0 +1,118 @@
+package service_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/cache/internal/service"
+)
+
+func TestNewUserService(t *testing.T) {
+	t.Run("invalid delay", func(t *testing.T) {
+		s, err := service.NewUserService(-1)
+		require.Error(t, err)
+		require.Nil(t, s)
+	})
+
+	t.Run("nil rand", func(t *testing.T) {
+		s, err := service.NewUserService(0, service.WithRand(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "rand is nil")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		s, err := service.NewUserService(0, service.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		s, err := service.NewUserService(0)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+	})
+}
+
+func TestGetUser(t *testing.T) {
+	t.Run("seeded rand gives a deterministic failure sequence", func(t *testing.T) {
+		s, err := service.NewUserService(0, service.WithRand(rand.New(rand.NewSource(1))))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		// Seed 1 yields 0.60, 0.94, 0.66, 0.44, 0.42, 0.69, 0.07 and the failure rate is 10%
+		expected := []bool{true, true, true, true, true, true, false}
+		for i, succeeds := range expected {
+			user, err := s.GetUser(ctx, "1")
+			if succeeds {
+				require.NoError(t, err, "call %d", i)
+				require.Equal(t, "Alice Johnson", user.Name)
+			} else {
+				require.Error(t, err, "call %d", i)
+				require.Contains(t, err.Error(), "service temporarily unavailable")
+			}
+		}
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		s, err := service.NewUserService(0, service.WithRand(rand.New(rand.NewSource(1))))
+		require.NoError(t, err)
+
+		_, err = s.GetUser(context.Background(), "unknown")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "user with id unknown not found")
+	})
+
+	t.Run("delay is measured on the injected clock", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		s, err := service.NewUserService(time.Second,
+			service.WithClock(fakeClock),
+			service.WithRand(rand.New(rand.NewSource(1))),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		type result struct {
+			user service.User
+			err  error
+		}
+		resultChan := make(chan result)
+		go func() {
+			user, err := s.GetUser(ctx, "5")
+			resultChan <- result{user, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(time.Second)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, "Eve Wilson", res.user.Name)
+		require.Equal(t, fakeClock.Now().Add(-1*time.Hour-time.Second), res.user.Created)
+	})
+
+	t.Run("context cancelled during delay", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		s, err := service.NewUserService(time.Second, service.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			_, err := s.GetUser(ctx, "1")
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		require.ErrorIs(t, <-errChan, context.Canceled)
+	})
+}
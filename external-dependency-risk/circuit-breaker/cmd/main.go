@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -85,6 +86,10 @@ func demonstrateCircuitOpening() {
 		2*time.Second, // timeout
 		2,             // max requests in half-open
 		2,             // success threshold
+		circuitbreaker.WithName("payments"),
+		circuitbreaker.WithOnStateChange(func(name string, from, to circuitbreaker.State) {
+			log.Printf("🔀 Circuit %q changed state: %s → %s\n", name, from, to)
+		}),
 	)
 	if err != nil {
 		log.Fatalf("Failed to create circuit breaker: %v", err)
@@ -111,7 +116,7 @@ func demonstrateCircuitOpening() {
 		_, err := cb.ProcessPayment(ctx, request)
 
 		if err != nil {
-			if err == circuitbreaker.ErrCircuitOpen {
+			if errors.Is(err, circuitbreaker.ErrCircuitOpen) {
 				log.Printf("🔴 Circuit is OPEN - Request blocked immediately\n")
 			} else {
 				log.Printf("❌ Payment failed: %v\n", err)
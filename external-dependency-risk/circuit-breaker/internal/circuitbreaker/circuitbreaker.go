@@ -2,11 +2,19 @@ package circuitbreaker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/jonboulle/clockwork"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
 )
@@ -33,42 +41,152 @@ func (s State) String() string {
 	}
 }
 
+// MarshalJSON encodes the state as its name, e.g. "HalfOpen"
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a state from its name, rejecting unknown names
+func (s *State) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for _, state := range []State{Closed, Open, HalfOpen} {
+		if name == state.String() {
+			*s = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown circuit breaker state %q", name)
+}
+
+// ErrRejected is the type of error the breaker returns when it refuses a call without reaching the dependency.
+// Rejections never count towards the failures that open the circuit. Check for one with IsRejection,
+// or for a specific reason with errors.Is.
+type ErrRejected struct {
+	Err error // Why the call was rejected
+}
+
+// Error returns the reason the call was rejected
+func (e *ErrRejected) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the reason the call was rejected
+func (e *ErrRejected) Unwrap() error {
+	return e.Err
+}
+
 var (
-	ErrCircuitOpen     = errors.New("circuit is open – skipping call")
-	ErrCircuitHalfOpen = errors.New("circuit is half-open – too many requests")
+	ErrCircuitOpen     error = &ErrRejected{Err: errors.New("circuit is open – skipping call")}
+	ErrCircuitHalfOpen error = &ErrRejected{Err: errors.New("circuit is half-open – too many requests")}
+	ErrDraining        error = &ErrRejected{Err: errors.New("circuit breaker is draining – not accepting calls")}
 )
 
+// IsRejection reports whether err means a breaker refused the call rather than the call failing,
+// e.g. to keep rejections out of a dependency's error rate
+func IsRejection(err error) bool {
+	var rejected *ErrRejected
+	return errors.As(err, &rejected)
+}
+
 // PaymentProcessor defines the interface for payment processing operations
 type PaymentProcessor interface {
 	ProcessPayment(ctx context.Context, request service.PaymentRequest) (service.PaymentResponse, error)
 }
 
-// circuitBreaker wraps a payment service with circuit breaker functionality
-type circuitBreaker struct {
-	service PaymentProcessor
-	lock    sync.RWMutex
-	clock   clockwork.Clock
+// breaker implements the circuit breaker state machine shared by every wrapper
+type breaker struct {
+	lock   sync.RWMutex
+	clock  clockwork.Clock
+	logger Logger
+	tracer trace.Tracer // Starts a span around every call, nil disables tracing
 
 	// Configuration
-	failureThreshold int           // Number of failures to trigger opening
-	successThreshold int           // Number of consecutive successful requests before closing the circuit
-	cooldown         time.Duration // Time to wait before allowing retry
-	maxRequests      int           // Max requests in half-open state
+	name             string         // Identifies the breaker in errors and callbacks
+	failureThreshold int            // Number of failures to trigger opening
+	successThreshold int            // Number of consecutive successful requests before closing the circuit
+	cooldown         time.Duration  // Time to wait before allowing retry
+	maxRequests      int            // Max requests in half-open state
+	callTimeout      time.Duration  // Deadline applied to each call, zero means no deadline
+	probeSuccesses   int            // Successful probes required to close from half-open, zero uses successThreshold
+	probeTotal       int            // Probes judged in half-open before the success ratio must be met
+	probeBackoff     time.Duration  // Shorter cooldown after a failed half-open probe, zero always uses cooldown
+	halfOpenSlots    chan struct{}  // Semaphore limiting concurrent half-open probes, nil caps probes per half-open period
+	probePolicy      func() bool    // Decides whether a half-open call becomes a probe, nil admits the first maxRequests
+	failureDecay     time.Duration  // Closed-state failures age out one per interval without a new failure, zero disables decay
+	cooldownJitter   time.Duration  // Open periods are randomized by up to this much either way, zero disables jitter
+	minCooldown      time.Duration  // Floor jitter can't shorten an open period below
+	random           func() float64 // Returns a random number in [0.0, 1.0) used for jitter
+	escalateBase     time.Duration  // Cooldown of the first of consecutive trips when escalating
+	escalateMax      time.Duration  // Longest escalated cooldown, and how long the circuit must stay closed to reset it
+	escalateFactor   float64        // Multiplies the cooldown on every consecutive trip, zero disables escalation
+	strictRecovery   bool           // Closed-state failures are only reset by successThreshold consecutive successes
 
 	// State
-	state     State
-	failures  int
-	lastFail  time.Time
-	requests  int // Current request count in half-open state
-	successes int // Current consecutive successful requests
+	state      State
+	failures   int
+	lastFail   time.Time
+	openFor    time.Duration // Cooldown of the current open period
+	reprobing  bool          // Whether the current open period is a shortened re-probe window
+	requests   int           // Current request count in half-open state
+	successes  int           // Current consecutive successful requests
+	generation uint64        // Incremented on every state change to discard results of calls admitted before it
+	probes     int           // Probes admitted in the current half-open period when a success ratio is configured
+	probeOK    int           // Successful probes in the current half-open period
+	probeFail  int           // Failed probes in the current half-open period
+	draining   bool          // Rejects every new call regardless of state, set by Drain
+	closed     chan struct{} // Closed while the state is Closed, replaced when the circuit leaves Closed
+	closedAt   time.Time     // When the circuit last closed
+	trips      int           // Consecutive trips without the circuit staying closed, used to escalate the cooldown
+
+	// Lifetime counters
+	totalSuccesses uint64 // Calls that succeeded
+	totalFailures  uint64 // Calls that failed and counted towards opening the circuit
+	shortCircuits  uint64 // Calls rejected without reaching the dependency
+
+	// Hooks
+	isFailure     func(error) bool // Decides whether an error counts as a failure, nil counts every error
+	onStateChange func(name string, from, to State)
+	fallback      any // func(context.Context, Req) (Res, error), checked against the Breaker's types
+}
+
+// Stats is a point-in-time snapshot of a circuit breaker's state and counters
+type Stats struct {
+	Name          string // Name set with WithName, empty if none was set
+	State         State
+	Failures      int    // Current failure count towards opening the circuit
+	Successes     uint64 // Calls that succeeded since the breaker was created
+	TotalFailures uint64 // Calls that failed since the breaker was created, excluding ignored errors
+	ShortCircuits uint64 // Calls rejected without reaching the dependency since the breaker was created
+}
+
+// Config is the configuration a circuit breaker was created with
+type Config struct {
+	FailureThreshold int           // Failures that open the circuit
+	SuccessThreshold int           // Consecutive successes that close the circuit from half-open
+	Cooldown         time.Duration // How long the circuit stays open before probing
+	MaxRequests      int           // Requests admitted in half-open
+}
+
+// BreakerSnapshot is the persistable state of a circuit breaker, exported by Export and restored with
+// WithSnapshot so a breaker keeps what it knew about its dependency across restarts
+type BreakerSnapshot struct {
+	State    State         `json:"state"`
+	Failures int           `json:"failures"`           // Current failure count towards opening the circuit
+	LastFail time.Time     `json:"last_fail,omitzero"` // When the last failure happened, or the circuit was tripped
+	OpenFor  time.Duration `json:"open_for,omitempty"` // Cooldown of the current open period
 }
 
 // Option is a functional option for configuring the circuit breaker
-type Option func(*circuitBreaker) error
+type Option func(*breaker) error
 
 // WithClock sets a custom clock for the circuit breaker
 func WithClock(clock clockwork.Clock) Option {
-	return func(cb *circuitBreaker) error {
+	return func(cb *breaker) error {
 		if clock == nil {
 			return errors.New("clock is nil")
 		}
@@ -77,11 +195,270 @@ func WithClock(clock clockwork.Clock) Option {
 	}
 }
 
-// New creates a new circuit breaker
-func New(service PaymentProcessor, failureThreshold int, cooldown time.Duration, maxRequests, successThreshold int, opts ...Option) (*circuitBreaker, error) {
+// WithName sets a name identifying the circuit breaker, used to tell breakers apart when running one per dependency
+func WithName(name string) Option {
+	return func(cb *breaker) error {
+		if name == "" {
+			return errors.New("name is empty")
+		}
+		cb.name = name
+		return nil
+	}
+}
+
+// WithLogger sets the logger the circuit breaker reports state changes and rejected calls to
+func WithLogger(logger Logger) Option {
+	return func(cb *breaker) error {
+		if logger == nil {
+			return errors.New("logger is nil")
+		}
+		cb.logger = logger
+		return nil
+	}
+}
+
+// WithTracer sets the tracer used to start a span around every call
+func WithTracer(tracer trace.Tracer) Option {
+	return func(cb *breaker) error {
+		if tracer == nil {
+			return errors.New("tracer is nil")
+		}
+		cb.tracer = tracer
+		return nil
+	}
+}
+
+// WithOnStateChange sets a callback invoked with the breaker's name whenever the circuit breaker changes state.
+// The callback runs synchronously while the breaker's lock is held, so it must not call back into the breaker.
+func WithOnStateChange(fn func(name string, from, to State)) Option {
+	return func(cb *breaker) error {
+		if fn == nil {
+			return errors.New("onStateChange is nil")
+		}
+		cb.onStateChange = fn
+		return nil
+	}
+}
+
+// WithCallTimeout sets a deadline for each call made through the circuit breaker.
+// Calls exceeding it return context.DeadlineExceeded, which counts as a failure subject to the failure predicate.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(cb *breaker) error {
+		if timeout <= 0 {
+			return errors.New("call timeout must be greater than 0")
+		}
+		cb.callTimeout = timeout
+		return nil
+	}
+}
+
+// WithHalfOpenSuccessRatio requires successes out of total probes to pass before closing from half-open,
+// replacing successThreshold. The circuit reopens as soon as too many probes fail for the ratio to be met.
+// At most total probes are admitted per half-open period, no more than maxRequests of them at once.
+func WithHalfOpenSuccessRatio(successes, total int) Option {
+	return func(cb *breaker) error {
+		switch {
+		case successes <= 0:
+			return errors.New("half-open successes must be greater than 0")
+		case total < successes:
+			return errors.New("half-open total must be greater than or equal to successes")
+		}
+		cb.probeSuccesses = successes
+		cb.probeTotal = total
+		return nil
+	}
+}
+
+// WithProbeBackoff waits the shorter backoff rather than the full cooldown before probing again
+// when a half-open probe fails. If the re-probe also fails, the circuit waits the full cooldown.
+func WithProbeBackoff(backoff time.Duration) Option {
+	return func(cb *breaker) error {
+		switch {
+		case backoff <= 0:
+			return errors.New("probe backoff must be greater than 0")
+		case backoff >= cb.cooldown:
+			return errors.New("probe backoff must be less than cooldown")
+		}
+		cb.probeBackoff = backoff
+		return nil
+	}
+}
+
+// WithMaxConcurrentHalfOpen makes maxRequests a limit on concurrent half-open probes rather than on the
+// probes admitted per half-open period. A probe's slot is freed as soon as it completes, whether it
+// succeeded or failed, and probes beyond the limit are rejected with ErrCircuitHalfOpen without blocking.
+func WithMaxConcurrentHalfOpen() Option {
+	return func(cb *breaker) error {
+		cb.halfOpenSlots = make(chan struct{}, cb.maxRequests)
+		return nil
+	}
+}
+
+// WithProbePolicy consults policy for every half-open call, admitting it as a probe if policy returns true
+// and rejecting it with ErrCircuitHalfOpen otherwise, e.g. to sample 1 in N calls rather than the first N.
+// It replaces the maxRequests cap on probes per half-open period, but WithMaxConcurrentHalfOpen and
+// WithHalfOpenSuccessRatio still limit the calls it admits. policy is called with the breaker's lock held,
+// so it must not call back into the breaker.
+func WithProbePolicy(policy func() bool) Option {
+	return func(cb *breaker) error {
+		if policy == nil {
+			return errors.New("probe policy is nil")
+		}
+		cb.probePolicy = policy
+		return nil
+	}
+}
+
+// WithStrictClosedRecovery applies successThreshold in Closed state too, so failures are only forgotten after
+// that many consecutive successes rather than any single one. This stops a downstream that briefly recovers
+// between failures from keeping the circuit closed.
+func WithStrictClosedRecovery() Option {
+	return func(cb *breaker) error {
+		cb.strictRecovery = true
+		return nil
+	}
+}
+
+// WithFailureDecay ages out Closed-state failures, forgetting one for every interval that passes without
+// a new failure. This stops failures spread thinly over a long period from eventually tripping the circuit.
+func WithFailureDecay(interval time.Duration) Option {
+	return func(cb *breaker) error {
+		if interval <= 0 {
+			return errors.New("failure decay interval must be greater than 0")
+		}
+		cb.failureDecay = interval
+		return nil
+	}
+}
+
+// WithEscalatingCooldown replaces the fixed cooldown with one that starts at base and is multiplied by factor
+// every time the circuit trips again, up to maxCooldown, so a flapping dependency is left alone for longer each time.
+// The cooldown drops back to base once the circuit has stayed closed for maxCooldown.
+func WithEscalatingCooldown(base, maxCooldown time.Duration, factor float64) Option {
+	return func(cb *breaker) error {
+		switch {
+		case base <= 0:
+			return errors.New("base cooldown must be greater than 0")
+		case maxCooldown < base:
+			return errors.New("max cooldown must not be less than base cooldown")
+		case factor < 1:
+			return errors.New("cooldown factor must be at least 1")
+		}
+		cb.escalateBase = base
+		cb.escalateMax = maxCooldown
+		cb.escalateFactor = factor
+		return nil
+	}
+}
+
+// WithCooldownJitter randomizes every open period by up to maxJitter either way, so instances that opened
+// together don't all probe the dependency at the same moment. It never shortens an open period by more than half;
+// use WithMinCooldown to set a different floor.
+func WithCooldownJitter(maxJitter time.Duration) Option {
+	return func(cb *breaker) error {
+		if maxJitter <= 0 {
+			return errors.New("cooldown jitter must be greater than 0")
+		}
+		cb.cooldownJitter = maxJitter
+		return nil
+	}
+}
+
+// WithMinCooldown sets the floor cooldown jitter can't shorten an open period below
+func WithMinCooldown(floor time.Duration) Option {
+	return func(cb *breaker) error {
+		if floor <= 0 {
+			return errors.New("min cooldown must be greater than 0")
+		}
+		cb.minCooldown = floor
+		return nil
+	}
+}
+
+// WithRand sets a custom random source used for cooldown jitter
+func WithRand(rnd *rand.Rand) Option {
+	return func(cb *breaker) error {
+		if rnd == nil {
+			return errors.New("rand is nil")
+		}
+		// rand.Rand isn't safe for concurrent use
+		var lock sync.Mutex
+		cb.random = func() float64 {
+			lock.Lock()
+			defer lock.Unlock()
+			return rnd.Float64()
+		}
+		return nil
+	}
+}
+
+// WithInitialState starts the circuit breaker in the given state, e.g. Open after a restart during an
+// ongoing incident to avoid a burst of calls to a dependency known to be failing. An Open breaker starts
+// its cooldown from the moment it's created.
+func WithInitialState(state State) Option {
+	return func(cb *breaker) error {
+		switch state {
+		case Closed, Open, HalfOpen:
+			cb.state = state
+			return nil
+		default:
+			return fmt.Errorf("unknown initial state %d", state)
+		}
+	}
+}
+
+// WithSnapshot restores the state exported from another circuit breaker, e.g. one that ran before a restart.
+// An Open breaker keeps the cooldown it had left when it was exported.
+func WithSnapshot(snapshot BreakerSnapshot) Option {
+	return func(cb *breaker) error {
+		switch {
+		case snapshot.State != Closed && snapshot.State != Open && snapshot.State != HalfOpen:
+			return fmt.Errorf("unknown snapshot state %d", snapshot.State)
+		case snapshot.Failures < 0:
+			return errors.New("snapshot failures cannot be negative")
+		case snapshot.OpenFor < 0:
+			return errors.New("snapshot open duration cannot be negative")
+		case snapshot.State == Open && snapshot.LastFail.IsZero():
+			return errors.New("open snapshot has no last failure time")
+		}
+
+		cb.state = snapshot.State
+		cb.failures = snapshot.Failures
+		cb.lastFail = snapshot.LastFail
+		if snapshot.OpenFor > 0 {
+			cb.openFor = snapshot.OpenFor
+		}
+		return nil
+	}
+}
+
+// WithFailurePredicate sets a predicate deciding which errors count towards the failure threshold.
+// Errors for which the predicate returns false are returned to the caller without affecting the breaker.
+func WithFailurePredicate(fn func(error) bool) Option {
+	return func(cb *breaker) error {
+		if fn == nil {
+			return errors.New("failure predicate is nil")
+		}
+		cb.isFailure = fn
+		return nil
+	}
+}
+
+// WithFallback sets a function to serve requests while the circuit is open or half-open,
+// and when a failed call trips the circuit. Its types must match those of the breaker.
+func WithFallback[Req, Res any](fn func(ctx context.Context, req Req) (Res, error)) Option {
+	return func(cb *breaker) error {
+		if fn == nil {
+			return errors.New("fallback is nil")
+		}
+		cb.fallback = fn
+		return nil
+	}
+}
+
+// newBreaker validates the configuration and creates the underlying state machine
+func newBreaker(failureThreshold int, cooldown time.Duration, maxRequests, successThreshold int, opts ...Option) (*breaker, error) {
 	switch {
-	case service == nil:
-		return nil, errors.New("service is nil")
 	case failureThreshold <= 0:
 		return nil, errors.New("failureThreshold must be greater than 0")
 	case cooldown <= 0:
@@ -92,14 +469,16 @@ func New(service PaymentProcessor, failureThreshold int, cooldown time.Duration,
 		return nil, errors.New("successThreshold must be greater than 0")
 	}
 
-	cb := &circuitBreaker{
-		service:          service,
+	cb := &breaker{
 		failureThreshold: failureThreshold,
 		cooldown:         cooldown,
 		maxRequests:      maxRequests,
 		state:            Closed,
+		openFor:          cooldown,
 		successThreshold: successThreshold,
 		clock:            clockwork.NewRealClock(), // Default to real clock
+		logger:           noopLogger{},
+		random:           rand.Float64,
 	}
 
 	// Apply options
@@ -109,78 +488,622 @@ func New(service PaymentProcessor, failureThreshold int, cooldown time.Duration,
 		}
 	}
 
+	if cb.state == Open && cb.lastFail.IsZero() {
+		// Start the cooldown once every option has run, so it's measured on the configured clock
+		cb.lastFail = cb.clock.Now()
+		cb.openFor = cb.jittered(cb.openFor)
+	}
+
+	cb.closed = make(chan struct{})
+	if cb.state == Closed {
+		close(cb.closed)
+	}
+
 	return cb, nil
 }
 
-// Call executes a function through the circuit breaker
-func (cb *circuitBreaker) call(fn func() error) error {
+// Call executes a function through the circuit breaker, reporting whether its result tripped the circuit.
+// The lock is only held while deciding whether to admit the call and while recording its result,
+// so admitted calls run concurrently.
+func (cb *breaker) call(fn func() error) (bool, error) {
+	generation, slot, err := cb.allow()
+	if err != nil {
+		return false, err
+	}
+	if slot {
+		defer func() { <-cb.halfOpenSlots }()
+	}
+
+	err = fn() // call the function
+	return cb.record(generation, err), err
+}
+
+// allow decides whether a call may proceed, returning the generation it was admitted in
+// and whether it holds a half-open slot that must be released once the call completes
+func (cb *breaker) allow() (uint64, bool, error) {
 	cb.lock.Lock()
 	defer cb.lock.Unlock()
 
-	now := cb.clock.Now()
+	if cb.draining {
+		return 0, false, cb.rejection(ErrDraining)
+	}
 
 	if cb.state == Open {
-		if now.Sub(cb.lastFail) > cb.cooldown {
+		if cb.clock.Since(cb.lastFail) > cb.openFor {
 			// If cooldown period has passed, transition to HalfOpen
-			cb.state = HalfOpen
+			cb.setState(HalfOpen)
 			cb.requests = 0
 		} else {
-			return ErrCircuitOpen
+			return 0, false, cb.rejection(ErrCircuitOpen)
+		}
+	}
+
+	if cb.state == HalfOpen && cb.probePolicy != nil && !cb.probePolicy() {
+		return 0, false, cb.rejection(ErrCircuitHalfOpen)
+	}
+
+	if cb.state == HalfOpen && cb.halfOpenSlots != nil {
+		if cb.probeTotal > 0 && cb.probes >= cb.probeTotal {
+			return 0, false, cb.rejection(ErrCircuitHalfOpen)
+		}
+
+		select {
+		case cb.halfOpenSlots <- struct{}{}:
+		default:
+			return 0, false, cb.rejection(ErrCircuitHalfOpen)
+		}
+		if cb.probeTotal > 0 {
+			cb.probes++
+		}
+		return cb.generation, true, nil
+	}
+
+	if cb.state == HalfOpen && cb.probeTotal > 0 {
+		inFlight := cb.probes - cb.probeOK - cb.probeFail
+		if cb.probes >= cb.probeTotal || inFlight >= cb.maxRequests {
+			return 0, false, cb.rejection(ErrCircuitHalfOpen)
 		}
+		cb.probes++
+		return cb.generation, false, nil
 	}
 
-	if cb.state == HalfOpen && cb.requests >= cb.maxRequests {
-		return ErrCircuitHalfOpen
+	if cb.state == HalfOpen && cb.probePolicy == nil && cb.requests >= cb.maxRequests {
+		return 0, false, cb.rejection(ErrCircuitHalfOpen)
 	}
 
 	cb.requests++
-	err := fn() // call the function
+	return cb.generation, false, nil
+}
+
+// rejection wraps err with the breaker's name, if it has one. Rejected calls never reach record,
+// so they don't count towards opening the circuit.
+func (cb *breaker) rejection(err error) error {
+	cb.shortCircuits++
+	cb.logger.Debugf("circuit breaker %q rejected call: %v", cb.name, err)
+	if cb.name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", cb.name, err)
+}
+
+// record updates the counters with the result of a call admitted in the given generation,
+// reporting whether the result tripped the circuit
+func (cb *breaker) record(generation uint64, err error) bool {
+	ignored := cb.ignored(err)
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	wasOpen := cb.state == Open
+	cb.recordLocked(generation, err, ignored)
+	return !wasOpen && cb.state == Open
+}
+
+// ignored reports whether err isn't the dependency's fault, so leaves the counters untouched
+func (cb *breaker) ignored(err error) bool {
+	return err != nil && cb.isFailure != nil && !cb.isFailure(err)
+}
+
+// recordLocked updates the counters like record, it must be called with the lock held
+func (cb *breaker) recordLocked(generation uint64, err error, ignored bool) {
+	cb.countLocked(err, ignored)
+
+	if generation != cb.generation {
+		// The state changed while the call was in flight, so its result is stale
+		return
+	}
+
+	if cb.state == HalfOpen && cb.probeTotal > 0 {
+		cb.recordProbe(err, ignored)
+		return
+	}
+
+	if ignored {
+		return
+	}
+
 	if err != nil {
 		cb.successes = 0
-		cb.failures++
-		cb.lastFail = now
+		cb.failures = cb.currentFailures() + 1
+		cb.lastFail = cb.clock.Now()
 		if cb.failures >= cb.failureThreshold {
-			cb.state = Open
+			cb.open()
 		}
-		return err
+		return
 	}
 
-	// Success → reset
+	// Success → reset, once enough have accumulated if recovery is strict
 	cb.successes++
-	cb.failures = 0
+	if !cb.strictRecovery || cb.state != Closed || cb.successes >= cb.successThreshold {
+		cb.failures = 0
+	}
 	if cb.successes >= cb.successThreshold {
-		cb.state = Closed
+		cb.setState(Closed)
 	}
 	cb.requests = 0
-	return nil
 }
 
-// ProcessPayment processes a payment request through the circuit breaker
-func (cb *circuitBreaker) ProcessPayment(ctx context.Context, request service.PaymentRequest) (service.PaymentResponse, error) {
-	var response service.PaymentResponse
+// countLocked adds a result to the lifetime totals. Must be called with the lock held.
+func (cb *breaker) countLocked(err error, ignored bool) {
+	switch {
+	case ignored:
+	case err != nil:
+		cb.totalFailures++
+	default:
+		cb.totalSuccesses++
+	}
+}
+
+// currentFailures returns the failure count, less any Closed-state failures that have decayed since the last one.
+// Must be called with the lock held.
+func (cb *breaker) currentFailures() int {
+	if cb.failureDecay == 0 || cb.state != Closed {
+		return cb.failures
+	}
+	decayed := int(cb.clock.Since(cb.lastFail) / cb.failureDecay)
+	return max(0, cb.failures-decayed)
+}
+
+// recordProbe judges the result of a half-open probe against the success ratio.
+// Must be called with the lock held.
+func (cb *breaker) recordProbe(err error, ignored bool) {
+	if ignored {
+		// Free the probe so another can take its place
+		cb.probes--
+		return
+	}
 
-	err := cb.call(func() error {
-		var err error
-		response, err = cb.service.ProcessPayment(ctx, request)
-		return err
-	})
 	if err != nil {
-		return service.PaymentResponse{}, err
+		cb.probeFail++
+		cb.failures++
+		cb.lastFail = cb.clock.Now()
+		if cb.probeFail > cb.probeTotal-cb.probeSuccesses {
+			// Too many failures for the ratio to be met
+			cb.open()
+		}
+		return
+	}
+
+	cb.probeOK++
+	if cb.probeOK >= cb.probeSuccesses {
+		cb.failures = 0
+		cb.successes = 0
+		cb.setState(Closed)
+	}
+}
+
+// open trips the circuit after a failure. A failed half-open probe waits the probe backoff
+// if one is configured, unless it was itself a re-probe. Must be called with the lock held.
+func (cb *breaker) open() {
+	cb.openFor = cb.nextCooldown()
+	if cb.state == HalfOpen && cb.probeBackoff > 0 && !cb.reprobing {
+		cb.openFor = cb.probeBackoff
+		cb.reprobing = true
+	} else {
+		cb.reprobing = false
+	}
+	cb.openFor = cb.jittered(cb.openFor)
+	cb.setState(Open)
+}
+
+// nextCooldown returns the cooldown for a new open period, escalating it if the circuit keeps tripping.
+// Must be called with the lock held.
+func (cb *breaker) nextCooldown() time.Duration {
+	if cb.escalateFactor == 0 {
+		return cb.cooldown
+	}
+
+	// Staying closed long enough means the dependency recovered rather than flapped
+	if cb.state == Closed && cb.clock.Since(cb.closedAt) >= cb.escalateMax {
+		cb.trips = 0
+	}
+
+	cooldown := cb.escalated(cb.trips)
+	cb.trips++
+	return cooldown
+}
+
+// currentCooldown returns the cooldown of the latest open period, before any jitter, without escalating it.
+// Must be called with the lock held.
+func (cb *breaker) currentCooldown() time.Duration {
+	if cb.escalateFactor == 0 {
+		return cb.cooldown
+	}
+	return cb.escalated(max(0, cb.trips-1))
+}
+
+// escalated returns the cooldown after the given number of consecutive trips, capped at the max cooldown
+func (cb *breaker) escalated(trips int) time.Duration {
+	cooldown := float64(cb.escalateBase) * math.Pow(cb.escalateFactor, float64(trips))
+	if cooldown >= float64(cb.escalateMax) {
+		return cb.escalateMax
+	}
+	return time.Duration(cooldown)
+}
+
+// jittered randomizes an open period by up to the configured jitter either way, never going below the minimum cooldown,
+// or half the period if no minimum is set
+func (cb *breaker) jittered(d time.Duration) time.Duration {
+	if cb.cooldownJitter == 0 {
+		return d
+	}
+	floor := cb.minCooldown
+	if floor == 0 {
+		// Jitter as large as the period would otherwise let the circuit probe again straight away
+		floor = d / 2
+	}
+	d += time.Duration((2*cb.random() - 1) * float64(cb.cooldownJitter))
+	return max(d, floor)
+}
+
+// setState transitions the circuit breaker to the given state, notifying the state change callback.
+// Must be called with the lock held.
+func (cb *breaker) setState(to State) {
+	from := cb.state
+	if from == to {
+		return
+	}
+
+	cb.state = to
+	cb.generation++
+	cb.probes, cb.probeOK, cb.probeFail = 0, 0, 0
+
+	// Wake anything waiting for the circuit to close, or give new waiters something to wait on
+	switch {
+	case to == Closed:
+		close(cb.closed)
+		cb.closedAt = cb.clock.Now()
+	case from == Closed:
+		cb.closed = make(chan struct{})
+	}
+
+	if to == Open {
+		cb.logger.Warnf("circuit breaker %q changed state from %s to %s", cb.name, from, to)
+	} else {
+		cb.logger.Infof("circuit breaker %q changed state from %s to %s", cb.name, from, to)
 	}
+	if cb.onStateChange != nil {
+		cb.onStateChange(cb.name, from, to)
+	}
+}
 
-	return response, nil
+// Name returns the name of the circuit breaker, empty if none was set
+func (cb *breaker) Name() string {
+	return cb.name
 }
 
 // State returns the current state of the circuit breaker
-func (cb *circuitBreaker) State() State {
+func (cb *breaker) State() State {
 	cb.lock.RLock()
 	defer cb.lock.RUnlock()
 	return cb.state
 }
 
 // Failures returns the current failure count
-func (cb *circuitBreaker) Failures() int {
+func (cb *breaker) Failures() int {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return cb.currentFailures()
+}
+
+// Requests returns the number of requests admitted since the last success, which half-open admission limits to MaxRequests
+func (cb *breaker) Requests() int {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return cb.requests
+}
+
+// Successes returns the current consecutive success count
+func (cb *breaker) Successes() int {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return cb.successes
+}
+
+// Config returns the configuration the circuit breaker was created with
+func (cb *breaker) Config() Config {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return Config{
+		FailureThreshold: cb.failureThreshold,
+		SuccessThreshold: cb.successThreshold,
+		Cooldown:         cb.cooldown,
+		MaxRequests:      cb.maxRequests,
+	}
+}
+
+// Stats returns a snapshot of the circuit breaker's state and counters
+func (cb *breaker) Stats() Stats {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return Stats{
+		Name:          cb.name,
+		State:         cb.state,
+		Failures:      cb.currentFailures(),
+		Successes:     cb.totalSuccesses,
+		TotalFailures: cb.totalFailures,
+		ShortCircuits: cb.shortCircuits,
+	}
+}
+
+// Export returns a snapshot of the circuit breaker's state that can be persisted and restored with WithSnapshot
+func (cb *breaker) Export() BreakerSnapshot {
 	cb.lock.RLock()
 	defer cb.lock.RUnlock()
-	return cb.failures
+	return BreakerSnapshot{
+		State:    cb.state,
+		Failures: cb.currentFailures(),
+		LastFail: cb.lastFail,
+		OpenFor:  cb.openFor,
+	}
+}
+
+// WaitUntilClosed blocks until the circuit breaker is Closed, returning straight away if it already is,
+// or until ctx is done. An open circuit only moves on once calls probe the dependency, so something
+// else must keep making calls for it to close.
+func (cb *breaker) WaitUntilClosed(ctx context.Context) error {
+	cb.lock.RLock()
+	closed := cb.closed
+	cb.lock.RUnlock()
+
+	// Prefer reporting a closed circuit over a context that is also done
+	select {
+	case <-closed:
+		return nil
+	default:
+	}
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops the circuit breaker admitting new calls, which fail with ErrDraining, while calls already
+// in flight finish and are recorded as usual. It takes precedence over the state, which is left unchanged.
+func (cb *breaker) Drain() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.draining = true
+}
+
+// Undrain resumes admitting calls according to the circuit breaker's state
+func (cb *breaker) Undrain() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.draining = false
+}
+
+// Draining reports whether the circuit breaker is draining
+func (cb *breaker) Draining() bool {
+	cb.lock.RLock()
+	defer cb.lock.RUnlock()
+	return cb.draining
+}
+
+// Reset forces the circuit breaker closed and clears all counters
+func (cb *breaker) Reset() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.failures = 0
+	cb.successes = 0
+	cb.requests = 0
+	cb.setState(Closed)
+}
+
+// Trip forces the circuit breaker open and starts a new cooldown period.
+// Trip is honored from any state, including HalfOpen while probes are in progress. Tripping a circuit that is
+// already open and still cooling down restarts its cooldown without escalating it.
+func (cb *breaker) Trip() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cooldown := cb.currentCooldown()
+	if cb.state != Open || cb.clock.Since(cb.lastFail) > cb.openFor {
+		cooldown = cb.nextCooldown()
+	}
+
+	cb.lastFail = cb.clock.Now()
+	cb.openFor = cb.jittered(cooldown)
+	cb.reprobing = false
+	cb.setState(Open)
+}
+
+// Breaker is a circuit breaker that can protect any operation taking a Req and returning a Res
+type Breaker[Req, Res any] struct {
+	*breaker
+	fallback func(context.Context, Req) (Res, error)
+}
+
+// NewBreaker creates a new generic circuit breaker
+func NewBreaker[Req, Res any](failureThreshold int, cooldown time.Duration, maxRequests, successThreshold int, opts ...Option) (*Breaker[Req, Res], error) {
+	b, err := newBreaker(failureThreshold, cooldown, maxRequests, successThreshold, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cb := &Breaker[Req, Res]{breaker: b}
+
+	if b.fallback != nil {
+		fallback, ok := b.fallback.(func(context.Context, Req) (Res, error))
+		if !ok {
+			return nil, errors.New("fallback does not match breaker request/response types")
+		}
+		cb.fallback = fallback
+	}
+
+	return cb, nil
+}
+
+// Do executes fn with the given request through the circuit breaker
+func (b *Breaker[Req, Res]) Do(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, error) {
+	res, _, err := b.do(ctx, req, fn)
+	return res, err
+}
+
+// do executes fn like Do, also reporting whether the circuit rejected this call or this call tripped it
+func (b *Breaker[Req, Res]) do(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, bool, error) {
+	ctx, span := b.startSpan(ctx)
+	defer span.End()
+
+	var res Res
+
+	tripped, err := b.call(func() error {
+		var err error
+		res, err = b.invoke(ctx, req, fn)
+		return err
+	})
+	recordOutcome(span, err)
+	if err != nil {
+		open := IsRejection(err) || tripped
+		if b.shouldFallback(err, tripped) {
+			res, err := b.fallback(ctx, req)
+			return res, open, err
+		}
+		var zero Res
+		return zero, open, err
+	}
+
+	return res, false, nil
+}
+
+// invoke calls fn, enforcing the call timeout if one is configured
+func (b *Breaker[Req, Res]) invoke(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, error) {
+	if b.callTimeout <= 0 {
+		return fn(ctx, req)
+	}
+
+	ctx, cancel := clockwork.WithTimeout(ctx, b.clock, b.callTimeout)
+	defer cancel()
+
+	type result struct {
+		res Res
+		err error
+	}
+
+	// Run the call in the background so a slow fn that ignores its context can't outlive the deadline
+	done := make(chan result, 1)
+	go func() {
+		res, err := fn(ctx, req)
+		done <- result{res: res, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		var zero Res
+		return zero, ctx.Err()
+	}
+}
+
+// startSpan starts a span for a call, or returns a no-op span and ctx unchanged when no tracer is set
+func (cb *breaker) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	if cb.tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	return cb.tracer.Start(ctx, "circuit_breaker.call", trace.WithAttributes(
+		attribute.String("circuit_breaker.name", cb.name),
+		attribute.String("circuit_breaker.state", cb.State().String()),
+	))
+}
+
+// recordOutcome records the result of a call on its span, flagging calls the breaker rejected
+func recordOutcome(span trace.Span, err error) {
+	if err == nil {
+		span.SetStatus(codes.Ok, "")
+		return
+	}
+
+	span.SetAttributes(attribute.Bool("circuit_breaker.rejected", IsRejection(err)))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// shouldFallback reports whether a failed call should be served by the fallback. It is decided by the call's own
+// outcome rather than the breaker's state, which other calls may have changed since.
+func (b *Breaker[Req, Res]) shouldFallback(err error, tripped bool) bool {
+	if b.fallback == nil || errors.Is(err, ErrDraining) {
+		return false
+	}
+	if IsRejection(err) {
+		return true
+	}
+	// The call itself failed, fall back if it tripped the circuit
+	return tripped
+}
+
+// circuitBreaker wraps a payment service with circuit breaker functionality
+type circuitBreaker struct {
+	*Breaker[service.PaymentRequest, service.PaymentResponse]
+	service PaymentProcessor
+}
+
+// New creates a new circuit breaker
+func New(svc PaymentProcessor, failureThreshold int, cooldown time.Duration, maxRequests, successThreshold int, opts ...Option) (*circuitBreaker, error) {
+	if svc == nil {
+		return nil, errors.New("service is nil")
+	}
+
+	b, err := NewBreaker[service.PaymentRequest, service.PaymentResponse](failureThreshold, cooldown, maxRequests, successThreshold, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &circuitBreaker{
+		Breaker: b,
+		service: svc,
+	}, nil
+}
+
+// ProcessPayment processes a payment request through the circuit breaker
+func (cb *circuitBreaker) ProcessPayment(ctx context.Context, request service.PaymentRequest) (service.PaymentResponse, error) {
+	return cb.Do(ctx, request, cb.service.ProcessPayment)
+}
+
+// ProcessPayments processes a batch of payments one at a time, with the circuit breaker governing the batch as a unit.
+// A failed payment that doesn't trip the circuit leaves a zero response at its index and the rest of the batch carries on.
+// Once a payment is rejected or trips the circuit the batch stops, returning the responses for the payments before it
+// and every error so far, including the one that stopped it. Each payment goes through Do, so it gets its own span,
+// and with a fallback set, payments the circuit rejects or that trip it are served by the fallback instead.
+func (cb *circuitBreaker) ProcessPayments(ctx context.Context, requests []service.PaymentRequest) ([]service.PaymentResponse, error) {
+	responses := make([]service.PaymentResponse, 0, len(requests))
+	var errs []error
+
+	for i, request := range requests {
+		// Stop on this payment's own outcome rather than the breaker's state, which other calls share
+		response, open, err := cb.do(ctx, request, cb.service.ProcessPayment)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", i, err))
+			if open {
+				return responses, errors.Join(errs...)
+			}
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, errors.Join(errs...)
 }
@@ -2,17 +2,27 @@ package circuitbreaker_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/mock/gomock"
 
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/mocks"
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/internal/testutil"
 )
 
 func TestNew(t *testing.T) {
@@ -459,3 +469,2490 @@ func TestStateTransitions(t *testing.T) {
 		require.Equal(t, circuitbreaker.Open, cb.State())
 	})
 }
+
+func TestBreaker(t *testing.T) {
+	t.Run("invalid configuration", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, int](0, 1*time.Second, 1, 1)
+		require.Error(t, err)
+		require.Nil(t, b)
+	})
+
+	t.Run("successful call returns result", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, int](1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		res, err := b.Do(context.Background(), "hello", func(ctx context.Context, req string) (int, error) {
+			return len(req), nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 5, res)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("failures open circuit and cooldown recovers", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, int](2, 1*time.Second, 1, 1, circuitbreaker.WithClock(clock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		calls := 0
+		fail := func(ctx context.Context, req string) (int, error) {
+			calls++
+			return 42, errors.New("query failed")
+		}
+
+		for i := 0; i < 2; i++ {
+			res, err := b.Do(ctx, "select", fail)
+			require.Error(t, err)
+			require.Zero(t, res)
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		_, err = b.Do(ctx, "select", fail)
+		require.Equal(t, circuitbreaker.ErrCircuitOpen, err)
+		require.Equal(t, 2, calls)
+
+		clock.Advance(2 * time.Second)
+
+		res, err := b.Do(ctx, "select", func(ctx context.Context, req string) (int, error) {
+			return 1, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, res)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+		require.Equal(t, 0, b.Failures())
+	})
+}
+
+type transition struct {
+	from, to circuitbreaker.State
+}
+
+func TestOnStateChange(t *testing.T) {
+	t.Run("nil callback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithOnStateChange(nil))
+		require.Error(t, err)
+		require.Nil(t, cb)
+	})
+
+	t.Run("not called when state is unchanged", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var transitions []transition
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 2, 1*time.Second, 1, 1, circuitbreaker.WithOnStateChange(func(_ string, from, to circuitbreaker.State) {
+			transitions = append(transitions, transition{from, to})
+		}))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, nil)
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+
+		require.Empty(t, transitions)
+	})
+
+	t.Run("fires for every transition", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var transitions []transition
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 2, 2,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithOnStateChange(func(_ string, from, to circuitbreaker.State) {
+				transitions = append(transitions, transition{from, to})
+			}),
+		)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed")).Times(2)
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, nil).Times(2)
+
+		// Closed → Open
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+
+		// Open → HalfOpen → Open
+		clock.Advance(2 * time.Second)
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+
+		// Open → HalfOpen → Closed
+		clock.Advance(2 * time.Second)
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+
+		require.Equal(t, []transition{
+			{circuitbreaker.Closed, circuitbreaker.Open},
+			{circuitbreaker.Open, circuitbreaker.HalfOpen},
+			{circuitbreaker.HalfOpen, circuitbreaker.Open},
+			{circuitbreaker.Open, circuitbreaker.HalfOpen},
+			{circuitbreaker.HalfOpen, circuitbreaker.Closed},
+		}, transitions)
+	})
+}
+
+func TestName(t *testing.T) {
+	t.Run("empty name", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithName(""))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "name is empty")
+	})
+
+	t.Run("unnamed by default", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		require.Empty(t, cb.Name())
+	})
+
+	t.Run("breakers report distinct names", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		payments, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+		require.NoError(t, err)
+
+		refunds, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithName("refunds"))
+		require.NoError(t, err)
+
+		require.Equal(t, "payments", payments.Name())
+		require.Equal(t, "refunds", refunds.Name())
+	})
+
+	t.Run("open breaker error mentions its name", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Contains(t, err.Error(), "payments")
+	})
+
+	t.Run("state change callback receives the name", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var names []string
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithName("payments"),
+			circuitbreaker.WithOnStateChange(func(name string, _, _ circuitbreaker.State) {
+				names = append(names, name)
+			}),
+		)
+		require.NoError(t, err)
+
+		cb.Trip()
+		cb.Reset()
+
+		require.Equal(t, []string{"payments", "payments"}, names)
+	})
+}
+
+func TestResetAndTrip(t *testing.T) {
+	t.Run("trip opens a closed circuit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 3, 1*time.Second, 1, 1, circuitbreaker.WithClock(clock))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		cb.Trip()
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Equal(t, circuitbreaker.ErrCircuitOpen, err)
+
+		// Cooldown starts from the trip
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, nil)
+
+		clock.Advance(2 * time.Second)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("trip is honored in half-open", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 2, 2, circuitbreaker.WithClock(clock))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, nil)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+
+		clock.Advance(2 * time.Second)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		cb.Trip()
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Equal(t, circuitbreaker.ErrCircuitOpen, err)
+	})
+
+	t.Run("reset closes an open circuit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Minute, 1, 1)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, nil)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+		require.Equal(t, 1, cb.Failures())
+
+		cb.Reset()
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+		require.Equal(t, 0, cb.Failures())
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+	})
+
+	t.Run("concurrent reset and trip", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				cb.Trip()
+			}()
+			go func() {
+				defer wg.Done()
+				cb.Reset()
+			}()
+		}
+		wg.Wait()
+
+		require.Contains(t, []circuitbreaker.State{circuitbreaker.Closed, circuitbreaker.Open}, cb.State())
+	})
+}
+
+func TestHalfOpenSuccessRatio(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+	fail := func(context.Context, string) (string, error) { return "", errors.New("payment failed") }
+
+	// newHalfOpenBreaker returns a breaker whose next call is admitted in half-open
+	newHalfOpenBreaker := func(t *testing.T, maxRequests, successes, total int) *circuitbreaker.Breaker[string, string] {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, maxRequests, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithHalfOpenSuccessRatio(successes, total),
+		)
+		require.NoError(t, err)
+
+		cb.Trip()
+		clock.Advance(2 * time.Second)
+		return cb
+	}
+
+	t.Run("invalid successes", func(t *testing.T) {
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithHalfOpenSuccessRatio(0, 4))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "half-open successes must be greater than 0")
+	})
+
+	t.Run("total less than successes", func(t *testing.T) {
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithHalfOpenSuccessRatio(3, 2))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "half-open total must be greater than or equal to successes")
+	})
+
+	t.Run("closes once the ratio is met", func(t *testing.T) {
+		cb := newHalfOpenBreaker(t, 1, 3, 4)
+		ctx := context.Background()
+
+		_, err := cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+		require.Equal(t, 0, cb.Failures())
+	})
+
+	t.Run("reopens once the ratio can't be met", func(t *testing.T) {
+		cb := newHalfOpenBreaker(t, 1, 3, 4)
+		ctx := context.Background()
+
+		_, err := cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+
+	t.Run("max requests caps concurrent probes", func(t *testing.T) {
+		cb := newHalfOpenBreaker(t, 1, 2, 2)
+		ctx := context.Background()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		errChan := make(chan error)
+		go func() {
+			_, err := cb.Do(ctx, "req", func(context.Context, string) (string, error) {
+				close(started)
+				<-release
+				return "ok", nil
+			})
+			errChan <- err
+		}()
+		<-started
+
+		_, err := cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitHalfOpen)
+
+		close(release)
+		require.NoError(t, <-errChan)
+
+		// The slot is free again once the probe completes
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("ignored errors free the probe", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithHalfOpenSuccessRatio(1, 1),
+			circuitbreaker.WithFailurePredicate(func(err error) bool {
+				return !errors.Is(err, context.Canceled)
+			}),
+		)
+		require.NoError(t, err)
+
+		cb.Trip()
+		clock.Advance(2 * time.Second)
+		ctx := context.Background()
+
+		_, err = cb.Do(ctx, "req", func(context.Context, string) (string, error) { return "", context.Canceled })
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("ratio applies again after reopening", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 2, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithHalfOpenSuccessRatio(2, 2),
+		)
+		require.NoError(t, err)
+
+		cb.Trip()
+		clock.Advance(2 * time.Second)
+		ctx := context.Background()
+
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		clock.Advance(2 * time.Second)
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.HalfOpen, cb.State())
+
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+}
+
+func TestProbeBackoff(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+	fail := func(context.Context, string) (string, error) { return "", errors.New("payment failed") }
+
+	t.Run("invalid backoff", func(t *testing.T) {
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithProbeBackoff(0))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "probe backoff must be greater than 0")
+
+		cb, err = circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithProbeBackoff(10*time.Second))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "probe backoff must be less than cooldown")
+	})
+
+	t.Run("failed probe waits the full cooldown by default", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithClock(clock))
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		// Probe fails
+		clock.Advance(11 * time.Second)
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		clock.Advance(9 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("failed probe is retried after the shorter backoff", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithProbeBackoff(time.Second),
+		)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+
+		// Tripping from closed still waits the full cooldown
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		// Probe fails
+		clock.Advance(9 * time.Second)
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("failed re-probe waits the full cooldown", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithProbeBackoff(time.Second),
+		)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		cb.Trip()
+
+		// Probe fails
+		clock.Advance(11 * time.Second)
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+
+		// Re-probe fails
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		clock.Advance(9 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("trip always waits the full cooldown", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		cb, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithProbeBackoff(time.Second),
+		)
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		cb.Trip()
+
+		// Probe fails, then the circuit is tripped during the re-probe window
+		clock.Advance(11 * time.Second)
+		_, err = cb.Do(ctx, "req", fail)
+		require.Error(t, err)
+		cb.Trip()
+
+		clock.Advance(2 * time.Second)
+		_, err = cb.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+}
+
+func TestFailurePredicate(t *testing.T) {
+	ignoreCanceled := func(err error) bool {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	t.Run("nil predicate", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithFailurePredicate(nil))
+		require.Error(t, err)
+		require.Nil(t, cb)
+	})
+
+	t.Run("context cancellation is not counted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1, circuitbreaker.WithFailurePredicate(ignoreCanceled))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, context.Canceled).Times(3)
+
+		for i := 0; i < 3; i++ {
+			_, err = cb.ProcessPayment(ctx, request)
+			require.ErrorIs(t, err, context.Canceled)
+			require.Equal(t, circuitbreaker.Closed, cb.State())
+			require.Equal(t, 0, cb.Failures())
+		}
+	})
+
+	t.Run("other errors are counted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1, circuitbreaker.WithFailurePredicate(ignoreCanceled))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+		require.Equal(t, 1, cb.Failures())
+	})
+
+	t.Run("default counts every error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, context.Canceled)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+	})
+
+	t.Run("invalid payment requests are not counted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		ignoreInvalid := func(err error) bool {
+			return !errors.Is(err, service.ErrInvalidRequest)
+		}
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1, circuitbreaker.WithFailurePredicate(ignoreInvalid))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{ID: "payment-1", Amount: 100}
+		ctx := context.Background()
+
+		gomock.InOrder(
+			mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, fmt.Errorf("payment processing failed: %w", service.ErrInvalidRequest)),
+			mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, fmt.Errorf("payment processing failed: %w", service.ErrServiceUnavailable)),
+		)
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, service.ErrInvalidRequest)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+		require.Equal(t, 0, cb.Failures())
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, service.ErrServiceUnavailable)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+	})
+}
+
+func TestFallback(t *testing.T) {
+	fallbackResponse := service.PaymentResponse{ID: "fallback", Status: "queued"}
+	fallback := func(ctx context.Context, req service.PaymentRequest) (service.PaymentResponse, error) {
+		return fallbackResponse, nil
+	}
+
+	t.Run("nil fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithFallback[service.PaymentRequest, service.PaymentResponse](nil))
+		require.Error(t, err)
+		require.Nil(t, cb)
+	})
+
+	t.Run("mismatched fallback types", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, int](1, 1*time.Second, 1, 1, circuitbreaker.WithFallback(fallback))
+		require.Error(t, err)
+		require.Nil(t, b)
+	})
+
+	t.Run("failure below threshold is returned", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 2, 1*time.Second, 1, 1, circuitbreaker.WithFallback(fallback))
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+
+		_, err = cb.ProcessPayment(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("fallback served while open and real service used after recovery", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithFallback(fallback),
+		)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		expectedResponse := service.PaymentResponse{ID: "123", Status: "success"}
+		ctx := context.Background()
+
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, errors.New("payment failed"))
+		mockService.EXPECT().ProcessPayment(ctx, request).Return(expectedResponse, nil)
+
+		// The failure trips the circuit, so the fallback is served
+		response, err := cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, fallbackResponse, response)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		// Open circuit serves the fallback without calling the service
+		response, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, fallbackResponse, response)
+
+		clock.Advance(2 * time.Second)
+
+		response, err = cb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, expectedResponse, response)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("failure is returned when another call tripped the circuit", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](2, 1*time.Second, 1, 1,
+			circuitbreaker.WithFallback(func(context.Context, string) (string, error) { return "fallback", nil }),
+		)
+		require.NoError(t, err)
+
+		// The circuit opens while the call is in flight, but its own failure didn't open it
+		callErr := errors.New("payment failed")
+		res, err := b.Do(context.Background(), "request", func(context.Context, string) (string, error) {
+			b.Trip()
+			return "", callErr
+		})
+		require.ErrorIs(t, err, callErr)
+		require.Empty(t, res)
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+}
+
+func TestCallTimeout(t *testing.T) {
+	t.Run("invalid timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithCallTimeout(0))
+		require.Error(t, err)
+		require.Nil(t, cb)
+	})
+
+	t.Run("call within timeout succeeds", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithCallTimeout(500*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		expectedResponse := service.PaymentResponse{ID: "123", Status: "success"}
+
+		mockService.EXPECT().
+			ProcessPayment(gomock.Any(), request).
+			DoAndReturn(func(ctx context.Context, req service.PaymentRequest) (service.PaymentResponse, error) {
+				deadline, ok := ctx.Deadline()
+				require.True(t, ok)
+				require.Equal(t, clock.Now().Add(500*time.Millisecond), deadline)
+				return expectedResponse, nil
+			})
+
+		response, err := cb.ProcessPayment(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, expectedResponse, response)
+	})
+
+	t.Run("call exceeding timeout counts as failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clock := clockwork.NewFakeClock()
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 2, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clock),
+			circuitbreaker.WithCallTimeout(500*time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		request := service.PaymentRequest{Amount: 100}
+		ctx := context.Background()
+
+		// The service ignores its context and hangs until the test ends
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		mockService.EXPECT().
+			ProcessPayment(gomock.Any(), request).
+			DoAndReturn(func(ctx context.Context, req service.PaymentRequest) (service.PaymentResponse, error) {
+				close(started)
+				<-release
+				return service.PaymentResponse{}, nil
+			})
+
+		errChan := make(chan error)
+		go func() {
+			_, err := cb.ProcessPayment(ctx, request)
+			errChan <- err
+		}()
+
+		<-started
+		clock.BlockUntilContext(ctx, 1)
+		clock.Advance(500 * time.Millisecond)
+
+		err = <-errChan
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, cb.Failures())
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+}
+
+// awaitAll returns a call that blocks until n calls are in flight at the same time,
+// failing if that doesn't happen because the breaker serializes them
+func awaitAll(n int) func(context.Context, int) (int, error) {
+	var (
+		lock     sync.Mutex
+		inFlight int
+		all      = make(chan struct{})
+	)
+
+	return func(ctx context.Context, req int) (int, error) {
+		lock.Lock()
+		inFlight++
+		if inFlight == n {
+			close(all)
+		}
+		lock.Unlock()
+
+		select {
+		case <-all:
+			return req, nil
+		case <-time.After(5 * time.Second):
+			return 0, errors.New("calls were serialized")
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	t.Run("closed circuit runs calls concurrently", func(t *testing.T) {
+		const calls = 50
+
+		b, err := circuitbreaker.NewBreaker[int, int](1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		fn := awaitAll(calls)
+		errs := make(chan error, calls)
+
+		var wg sync.WaitGroup
+		for i := 0; i < calls; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := b.Do(context.Background(), i, fn)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("half-open allows max requests concurrent probes", func(t *testing.T) {
+		const maxRequests = 3
+
+		clock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[int, int](1, 1*time.Second, maxRequests, maxRequests, circuitbreaker.WithClock(clock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = b.Do(ctx, 0, func(ctx context.Context, req int) (int, error) {
+			return 0, errors.New("failed")
+		})
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		clock.Advance(2 * time.Second)
+
+		fn := awaitAll(maxRequests)
+		errs := make(chan error, maxRequests)
+
+		var wg sync.WaitGroup
+		for i := 0; i < maxRequests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := b.Do(ctx, i, fn)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("stale result does not override a newer state", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[int, int](1, 1*time.Minute, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+
+		go func() {
+			_, err := b.Do(ctx, 0, func(ctx context.Context, req int) (int, error) {
+				close(started)
+				<-release
+				return 0, nil
+			})
+			done <- err
+		}()
+
+		<-started
+		_, err = b.Do(ctx, 0, func(ctx context.Context, req int) (int, error) {
+			return 0, errors.New("failed")
+		})
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		close(release)
+		require.NoError(t, <-done)
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+}
+
+// capturingLogger records every formatted message logged to it
+type capturingLogger struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...any) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...any) { l.log("DEBUG", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...any)  { l.log("INFO", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...any)  { l.log("WARN", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...any) { l.log("ERROR", format, args...) }
+
+func TestLogger(t *testing.T) {
+	t.Run("nil logger", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1, circuitbreaker.WithLogger(nil))
+		require.Error(t, err)
+		require.Nil(t, cb)
+		require.Contains(t, err.Error(), "logger is nil")
+	})
+
+	t.Run("state transitions are logged", func(t *testing.T) {
+		logger := &capturingLogger{}
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1,
+			circuitbreaker.WithName("payments"),
+			circuitbreaker.WithLogger(logger),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		b.Reset()
+
+		require.Equal(t, []string{
+			`WARN: circuit breaker "payments" changed state from Closed to Open`,
+			`INFO: circuit breaker "payments" changed state from Open to Closed`,
+		}, logger.messages)
+	})
+
+	t.Run("rejected calls are logged", func(t *testing.T) {
+		logger := &capturingLogger{}
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithLogger(logger))
+		require.NoError(t, err)
+
+		b.Trip()
+		_, err = b.Do(context.Background(), "request", func(context.Context, string) (string, error) {
+			return "", nil
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Len(t, logger.messages, 2)
+		require.True(t, strings.HasPrefix(logger.messages[1], "DEBUG: "))
+		require.Contains(t, logger.messages[1], "rejected call")
+	})
+}
+
+func TestStats(t *testing.T) {
+	ignoreCanceled := func(err error) bool {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	b, err := circuitbreaker.NewBreaker[string, string](2, 1*time.Second, 1, 1,
+		circuitbreaker.WithName("payments"),
+		circuitbreaker.WithFailurePredicate(ignoreCanceled),
+	)
+	require.NoError(t, err)
+	require.Equal(t, circuitbreaker.Stats{Name: "payments", State: circuitbreaker.Closed}, b.Stats())
+
+	ctx := context.Background()
+	results := []error{nil, context.Canceled, errors.New("unavailable"), errors.New("unavailable"), nil}
+	for _, result := range results {
+		_, _ = b.Do(ctx, "request", func(context.Context, string) (string, error) {
+			return "", result
+		})
+	}
+
+	require.Equal(t, circuitbreaker.Stats{
+		Name:          "payments",
+		State:         circuitbreaker.Open,
+		Failures:      2,
+		Successes:     1,
+		TotalFailures: 2,
+		ShortCircuits: 1,
+	}, b.Stats())
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("nil tracer", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithTracer(nil))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "tracer is nil")
+	})
+
+	t.Run("starts a span for every call", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1,
+			circuitbreaker.WithName("payments"),
+			circuitbreaker.WithTracer(provider.Tracer("circuitbreaker_test")),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		_, err = b.Do(ctx, "request", func(context.Context, string) (string, error) {
+			return "ok", nil
+		})
+		require.NoError(t, err)
+		_, err = b.Do(ctx, "request", func(context.Context, string) (string, error) {
+			return "", errors.New("unavailable")
+		})
+		require.Error(t, err)
+		_, err = b.Do(ctx, "request", func(context.Context, string) (string, error) {
+			return "ok", nil
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 3)
+		for _, span := range spans {
+			require.Equal(t, "circuit_breaker.call", span.Name())
+		}
+
+		require.Equal(t, codes.Ok, spans[0].Status().Code)
+		require.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("circuit_breaker.name", "payments"),
+			attribute.String("circuit_breaker.state", "Closed"),
+		}, spans[0].Attributes())
+
+		require.Equal(t, codes.Error, spans[1].Status().Code)
+		require.Len(t, spans[1].Events(), 1)
+		require.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("circuit_breaker.name", "payments"),
+			attribute.String("circuit_breaker.state", "Closed"),
+			attribute.Bool("circuit_breaker.rejected", false),
+		}, spans[1].Attributes())
+
+		require.Equal(t, codes.Error, spans[2].Status().Code)
+		require.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("circuit_breaker.name", "payments"),
+			attribute.String("circuit_breaker.state", "Open"),
+			attribute.Bool("circuit_breaker.rejected", true),
+		}, spans[2].Attributes())
+	})
+}
+
+func TestMaxConcurrentHalfOpen(t *testing.T) {
+	const maxRequests = 3
+
+	// probe fires n concurrent calls that block until release is closed, returning once the surplus
+	// has been rejected and every admitted call is in flight
+	probe := func(t *testing.T, b *circuitbreaker.Breaker[string, string], n int, result error, release chan struct{}) (rejected int, done chan error) {
+		started := make(chan struct{}, n)
+		errs := make(chan error, n)
+		for range n {
+			go func() {
+				_, err := b.Do(context.Background(), "req", func(context.Context, string) (string, error) {
+					started <- struct{}{}
+					<-release
+					return "", result
+				})
+				errs <- err
+			}()
+		}
+
+		done = make(chan error, maxRequests)
+		admitted := 0
+		for admitted+rejected < n {
+			select {
+			case <-started:
+				admitted++
+			case err := <-errs:
+				require.ErrorIs(t, err, circuitbreaker.ErrCircuitHalfOpen)
+				rejected++
+			}
+		}
+		go func() {
+			for range admitted {
+				done <- <-errs
+			}
+			close(done)
+		}()
+
+		return rejected, done
+	}
+
+	t.Run("surplus probes are rejected and slots are released after failures", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, maxRequests, 5,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithMaxConcurrentHalfOpen(),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		release := make(chan struct{})
+		rejected, done := probe(t, b, 10, errors.New("still failing"), release)
+		require.Equal(t, 10-maxRequests, rejected)
+
+		close(release)
+		for err := range done {
+			require.Error(t, err)
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		// Every slot was freed by the failed probes, so the next half-open period admits maxRequests again
+		fakeClock.Advance(2 * time.Second)
+		release = make(chan struct{})
+		rejected, done = probe(t, b, 10, nil, release)
+		require.Equal(t, 10-maxRequests, rejected)
+
+		close(release)
+		for err := range done {
+			require.NoError(t, err)
+		}
+		require.Equal(t, circuitbreaker.HalfOpen, b.State())
+	})
+
+	t.Run("slots are released after successes", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, maxRequests, 2*maxRequests,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithMaxConcurrentHalfOpen(),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		for range 2 {
+			release := make(chan struct{})
+			rejected, done := probe(t, b, maxRequests+1, nil, release)
+			require.Equal(t, 1, rejected)
+
+			close(release)
+			for err := range done {
+				require.NoError(t, err)
+			}
+		}
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+}
+
+func TestStateJSON(t *testing.T) {
+	t.Run("marshals each state as its name", func(t *testing.T) {
+		for state, want := range map[circuitbreaker.State]string{
+			circuitbreaker.Closed:    `"Closed"`,
+			circuitbreaker.Open:      `"Open"`,
+			circuitbreaker.HalfOpen:  `"HalfOpen"`,
+			circuitbreaker.State(42): `"Unknown"`,
+		} {
+			data, err := json.Marshal(state)
+			require.NoError(t, err)
+			require.JSONEq(t, want, string(data))
+		}
+	})
+
+	t.Run("round trips within a struct", func(t *testing.T) {
+		type status struct {
+			Name  string               `json:"name"`
+			State circuitbreaker.State `json:"state"`
+		}
+
+		data, err := json.Marshal(status{Name: "payments", State: circuitbreaker.HalfOpen})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"name":"payments","state":"HalfOpen"}`, string(data))
+
+		var got status
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, circuitbreaker.HalfOpen, got.State)
+	})
+
+	t.Run("rejects unknown states", func(t *testing.T) {
+		for _, data := range []string{`"Unknown"`, `"closed"`, `1`} {
+			var state circuitbreaker.State
+			require.Error(t, json.Unmarshal([]byte(data), &state), data)
+		}
+	})
+}
+
+func TestInitialState(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	t.Run("unknown state", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithInitialState(circuitbreaker.State(42)))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "unknown initial state")
+	})
+
+	t.Run("closed by default", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("open breaker short-circuits until the cooldown elapses", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		var transitions []circuitbreaker.State
+		b, err := circuitbreaker.NewBreaker[string, string](1, 30*time.Second, 1, 1,
+			circuitbreaker.WithInitialState(circuitbreaker.Open),
+			circuitbreaker.WithClock(fakeClock), // Applied after the initial state, the cooldown still uses it
+			circuitbreaker.WithOnStateChange(func(_ string, _, to circuitbreaker.State) {
+				transitions = append(transitions, to)
+			}),
+		)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		ctx := context.Background()
+		_, err = b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		fakeClock.Advance(29 * time.Second)
+		_, err = b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		fakeClock.Advance(2 * time.Second)
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+		require.Equal(t, []circuitbreaker.State{circuitbreaker.HalfOpen, circuitbreaker.Closed}, transitions)
+	})
+
+	t.Run("half-open breaker admits probes immediately", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 30*time.Second, 1, 1, circuitbreaker.WithInitialState(circuitbreaker.HalfOpen))
+		require.NoError(t, err)
+
+		_, err = b.Do(context.Background(), "req", func(context.Context, string) (string, error) {
+			return "", errors.New("still failing")
+		})
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+}
+
+func TestDrain(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	t.Run("new calls are rejected while draining and resume after undrain", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+		require.NoError(t, err)
+		require.False(t, b.Draining())
+
+		ctx := context.Background()
+		b.Drain()
+		require.True(t, b.Draining())
+
+		_, err = b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrDraining)
+		require.Contains(t, err.Error(), "payments")
+		require.Equal(t, circuitbreaker.Closed, b.State())
+
+		b.Undrain()
+		require.False(t, b.Draining())
+
+		res, err := b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, "ok", res)
+	})
+
+	t.Run("in-flight calls finish and are recorded", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		errChan := make(chan error)
+		go func() {
+			_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) {
+				close(started)
+				<-release
+				return "", errors.New("unavailable")
+			})
+			errChan <- err
+		}()
+		<-started
+
+		b.Drain()
+		_, err = b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrDraining)
+
+		close(release)
+		require.EqualError(t, <-errChan, "unavailable")
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+
+	t.Run("draining takes precedence over the state", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithFallback(func(context.Context, string) (string, error) {
+				return "fallback", nil
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		b.Trip()
+		b.Drain()
+
+		// Not even a half-open probe is admitted once the cooldown has passed, and the fallback isn't used
+		fakeClock.Advance(2 * time.Second)
+		_, err = b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrDraining)
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		b.Undrain()
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+}
+
+func TestHarness(t *testing.T) {
+	h := testutil.NewHarness()
+	script := testutil.Sequence(testutil.ErrScripted, testutil.ErrScripted, nil)
+
+	b, err := circuitbreaker.NewBreaker[string, string](2, 30*time.Second, 1, 1, circuitbreaker.WithClock(h.Clock))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	call := testutil.Func[string](script, "ok")
+
+	// Two scripted failures open the circuit, after which the service isn't called
+	for range 2 {
+		_, err = b.Do(ctx, "req", call)
+		require.ErrorIs(t, err, testutil.ErrScripted)
+	}
+	_, err = b.Do(ctx, "req", call)
+	require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	require.Equal(t, 2, script.Calls())
+
+	// Once the cooldown passes on the shared clock, the recovered service closes the circuit
+	h.Advance(31 * time.Second)
+	res, err := b.Do(ctx, "req", call)
+	require.NoError(t, err)
+	require.Equal(t, "ok", res)
+	require.Equal(t, circuitbreaker.Closed, b.State())
+}
+
+func TestFailureDecay(t *testing.T) {
+	fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+
+	t.Run("invalid interval", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 1, circuitbreaker.WithFailureDecay(0))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "failure decay interval must be greater than 0")
+	})
+
+	t.Run("failures decay one per interval without new failures", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](5, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithFailureDecay(time.Minute),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for range 3 {
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+		}
+		require.Equal(t, 3, b.Failures())
+
+		fakeClock.Advance(59 * time.Second)
+		require.Equal(t, 3, b.Failures())
+
+		fakeClock.Advance(time.Second)
+		require.Equal(t, 2, b.Failures())
+		require.Equal(t, 2, b.Stats().Failures)
+
+		fakeClock.Advance(10 * time.Minute)
+		require.Zero(t, b.Failures())
+	})
+
+	t.Run("a new failure restarts the decay interval", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](5, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithFailureDecay(time.Minute),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for range 2 {
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+		}
+
+		// One failure decays before the next, which then counts on top of what's left
+		fakeClock.Advance(90 * time.Second)
+		_, err = b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, 2, b.Failures())
+
+		fakeClock.Advance(59 * time.Second)
+		require.Equal(t, 2, b.Failures())
+	})
+
+	t.Run("slow-burn failures never trip the circuit", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithFailureDecay(time.Minute),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for range 10 {
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+			fakeClock.Advance(time.Hour)
+		}
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("failures in quick succession still trip the circuit", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithFailureDecay(time.Minute),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for range 3 {
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+			fakeClock.Advance(time.Second)
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+		require.Equal(t, 3, b.Failures())
+	})
+}
+
+func TestProcessPayments(t *testing.T) {
+	requests := []service.PaymentRequest{{ID: "1", Amount: 100}, {ID: "2", Amount: 200}, {ID: "3", Amount: 300}}
+
+	t.Run("all payments succeed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 2, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for _, req := range requests {
+			mockService.EXPECT().ProcessPayment(ctx, req).Return(service.PaymentResponse{ID: req.ID, Status: "success"}, nil)
+		}
+
+		responses, err := cb.ProcessPayments(ctx, requests)
+		require.NoError(t, err)
+		require.Len(t, responses, 3)
+		for i, resp := range responses {
+			require.Equal(t, requests[i].ID, resp.ID)
+		}
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("open circuit short-circuits the whole batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// The mock has no expectations, so processing any payment would fail the test
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 2, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		cb.Trip()
+
+		responses, err := cb.ProcessPayments(context.Background(), requests)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Empty(t, responses)
+	})
+
+	t.Run("batch stops once the circuit trips mid-batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().ProcessPayment(ctx, requests[0]).Return(service.PaymentResponse{ID: "1", Status: "success"}, nil),
+			mockService.EXPECT().ProcessPayment(ctx, requests[1]).Return(service.PaymentResponse{}, service.ErrServiceUnavailable),
+		)
+
+		responses, err := cb.ProcessPayments(ctx, requests)
+		require.ErrorIs(t, err, service.ErrServiceUnavailable)
+		require.Contains(t, err.Error(), "payment 1")
+		require.Equal(t, []service.PaymentResponse{{ID: "1", Status: "success"}}, responses)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+	})
+
+	t.Run("a payment failing as another caller trips the circuit doesn't stop the batch itself", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 3, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		// The second payment fails below the threshold, so the batch carries on and the third is rejected
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().ProcessPayment(ctx, requests[0]).Return(service.PaymentResponse{ID: "1"}, nil),
+			mockService.EXPECT().ProcessPayment(ctx, requests[1]).DoAndReturn(func(context.Context, service.PaymentRequest) (service.PaymentResponse, error) {
+				cb.Trip()
+				return service.PaymentResponse{}, service.ErrServiceUnavailable
+			}),
+		)
+
+		responses, err := cb.ProcessPayments(ctx, requests)
+		require.ErrorIs(t, err, service.ErrServiceUnavailable)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Contains(t, err.Error(), "payment 2")
+		require.Equal(t, []service.PaymentResponse{{ID: "1"}, {}}, responses)
+	})
+
+	t.Run("failures below the threshold don't stop the batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 3, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().ProcessPayment(ctx, requests[0]).Return(service.PaymentResponse{ID: "1"}, nil),
+			mockService.EXPECT().ProcessPayment(ctx, requests[1]).Return(service.PaymentResponse{}, service.ErrServiceUnavailable),
+			mockService.EXPECT().ProcessPayment(ctx, requests[2]).Return(service.PaymentResponse{ID: "3"}, nil),
+		)
+
+		responses, err := cb.ProcessPayments(ctx, requests)
+		require.ErrorIs(t, err, service.ErrServiceUnavailable)
+		require.Equal(t, []service.PaymentResponse{{ID: "1"}, {}, {ID: "3"}}, responses)
+		require.Equal(t, circuitbreaker.Closed, cb.State())
+	})
+
+	t.Run("each payment gets its own span", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 3, 1*time.Second, 1, 1,
+			circuitbreaker.WithTracer(provider.Tracer("circuitbreaker_test")),
+		)
+		require.NoError(t, err)
+
+		for _, req := range requests {
+			mockService.EXPECT().ProcessPayment(gomock.Any(), req).Return(service.PaymentResponse{ID: req.ID}, nil)
+		}
+
+		_, err = cb.ProcessPayments(context.Background(), requests)
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, len(requests))
+		for _, span := range spans {
+			require.Equal(t, "circuit_breaker.call", span.Name())
+		}
+	})
+
+	t.Run("fallback serves payments once the circuit opens", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockPaymentProcessor(ctrl)
+		cb, err := circuitbreaker.New(mockService, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithFallback(func(_ context.Context, req service.PaymentRequest) (service.PaymentResponse, error) {
+				return service.PaymentResponse{ID: req.ID, Status: "queued"}, nil
+			}),
+		)
+		require.NoError(t, err)
+
+		// The second payment trips the circuit, so it and the rest of the batch are served by the fallback
+		ctx := context.Background()
+		gomock.InOrder(
+			mockService.EXPECT().ProcessPayment(ctx, requests[0]).Return(service.PaymentResponse{ID: "1", Status: "success"}, nil),
+			mockService.EXPECT().ProcessPayment(ctx, requests[1]).Return(service.PaymentResponse{}, service.ErrServiceUnavailable),
+		)
+
+		responses, err := cb.ProcessPayments(ctx, requests)
+		require.NoError(t, err)
+		require.Equal(t, []service.PaymentResponse{
+			{ID: "1", Status: "success"},
+			{ID: "2", Status: "queued"},
+			{ID: "3", Status: "queued"},
+		}, responses)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		responses, err := cb.ProcessPayments(context.Background(), nil)
+		require.NoError(t, err)
+		require.Empty(t, responses)
+	})
+}
+
+func TestWaitUntilClosed(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	t.Run("already closed", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		require.NoError(t, b.WaitUntilClosed(context.Background()))
+	})
+
+	t.Run("already closed with a cancelled context", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.NoError(t, b.WaitUntilClosed(ctx))
+	})
+
+	t.Run("becomes closed after a successful probe", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+		b.Trip()
+
+		ctx := context.Background()
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- b.WaitUntilClosed(ctx)
+		}()
+
+		// Waiting spans the whole open period
+		fakeClock.Advance(2 * time.Second)
+		select {
+		case err := <-errChan:
+			t.Fatalf("returned before the circuit closed: %v", err)
+		default:
+		}
+
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+
+		select {
+		case err := <-errChan:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("WaitUntilClosed did not return once the circuit closed")
+		}
+	})
+
+	t.Run("waits again after the circuit reopens", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		b.Trip()
+		b.Reset()
+		require.NoError(t, b.WaitUntilClosed(context.Background()))
+
+		b.Trip()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, b.WaitUntilClosed(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("context cancelled while open", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+		b.Trip()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- b.WaitUntilClosed(ctx)
+		}()
+
+		fakeClock.Advance(2 * time.Second)
+		cancel()
+
+		select {
+		case err := <-errChan:
+			require.ErrorIs(t, err, context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("WaitUntilClosed did not return once the context was cancelled")
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	t.Run("invalid snapshots", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			snapshot circuitbreaker.BreakerSnapshot
+			err      string
+		}{
+			{name: "unknown state", snapshot: circuitbreaker.BreakerSnapshot{State: circuitbreaker.State(99)}, err: "unknown snapshot state 99"},
+			{name: "negative failures", snapshot: circuitbreaker.BreakerSnapshot{Failures: -1}, err: "snapshot failures cannot be negative"},
+			{name: "negative open duration", snapshot: circuitbreaker.BreakerSnapshot{OpenFor: -time.Second}, err: "snapshot open duration cannot be negative"},
+			{name: "open without last failure", snapshot: circuitbreaker.BreakerSnapshot{State: circuitbreaker.Open}, err: "open snapshot has no last failure time"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1, circuitbreaker.WithSnapshot(tt.snapshot))
+				require.Error(t, err)
+				require.Nil(t, b)
+				require.Contains(t, err.Error(), tt.err)
+			})
+		}
+	})
+
+	t.Run("open breaker round-trips with the same remaining cooldown", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(3 * time.Second)
+
+		// Persist and reload the snapshot as it would be across a restart
+		data, err := json.Marshal(b.Export())
+		require.NoError(t, err)
+		var snapshot circuitbreaker.BreakerSnapshot
+		require.NoError(t, json.Unmarshal(data, &snapshot))
+
+		restored, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithSnapshot(snapshot),
+		)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Open, restored.State())
+
+		// 7s of the cooldown were left when it was exported
+		ctx := context.Background()
+		fakeClock.Advance(6 * time.Second)
+		_, err = restored.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		fakeClock.Advance(2 * time.Second)
+		_, err = restored.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, restored.State())
+	})
+
+	t.Run("failure count is restored", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+		for range 2 {
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+		}
+
+		snapshot := b.Export()
+		require.Equal(t, circuitbreaker.Closed, snapshot.State)
+		require.Equal(t, 2, snapshot.Failures)
+
+		restored, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithSnapshot(snapshot),
+		)
+		require.NoError(t, err)
+		require.Equal(t, 2, restored.Failures())
+
+		// One more failure reaches the threshold the original breaker was approaching
+		_, err = restored.Do(ctx, "req", fail)
+		require.Error(t, err)
+		require.Equal(t, circuitbreaker.Open, restored.State())
+	})
+
+	t.Run("closed snapshot serializes state by name", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 10*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		data, err := json.Marshal(b.Export())
+		require.NoError(t, err)
+		require.JSONEq(t, `{"state":"Closed","failures":0,"open_for":10000000000}`, string(data))
+	})
+}
+
+// fixedSource is a rand.Source always returning the same value, pinning Float64 to v/(1<<63)
+type fixedSource int64
+
+func (s fixedSource) Int63() int64 { return int64(s) }
+func (fixedSource) Seed(int64)     {}
+
+func TestCooldownJitter(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	// pinned returns a random source always producing f
+	pinned := func(f float64) *rand.Rand {
+		return rand.New(fixedSource(int64(f * (1 << 63))))
+	}
+
+	// requireHalfOpenAfter asserts b stays open until exactly after d has passed since it opened
+	requireHalfOpenAfter := func(t *testing.T, b *circuitbreaker.Breaker[string, string], fakeClock *clockwork.FakeClock, d time.Duration) {
+		t.Helper()
+		ctx := context.Background()
+
+		fakeClock.Advance(d)
+		_, err := b.Do(ctx, "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+		fakeClock.Advance(time.Millisecond)
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithCooldownJitter(0))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "cooldown jitter must be greater than 0")
+
+		b, err = circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithMinCooldown(0))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "min cooldown must be greater than 0")
+
+		b, err = circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, circuitbreaker.WithRand(nil))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "rand is nil")
+	})
+
+	t.Run("half-open transition happens at the jittered time", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithCooldownJitter(4*time.Second),
+			circuitbreaker.WithRand(pinned(0.75)),
+		)
+		require.NoError(t, err)
+
+		// 0.75 lands halfway between no jitter and the maximum, adding 2s
+		_, err = b.Do(context.Background(), "req", func(context.Context, string) (string, error) {
+			return "", errors.New("unavailable")
+		})
+		require.Error(t, err)
+		requireHalfOpenAfter(t, b, fakeClock, 12*time.Second)
+	})
+
+	t.Run("jitter can shorten the cooldown", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithCooldownJitter(4*time.Second),
+			circuitbreaker.WithRand(pinned(0)),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		requireHalfOpenAfter(t, b, fakeClock, 6*time.Second)
+	})
+
+	t.Run("jitter never shortens the cooldown below the floor", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithCooldownJitter(4*time.Second),
+			circuitbreaker.WithMinCooldown(8*time.Second),
+			circuitbreaker.WithRand(pinned(0)),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		requireHalfOpenAfter(t, b, fakeClock, 8*time.Second)
+	})
+
+	t.Run("jitter never shortens the cooldown by more than half by default", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 4*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithCooldownJitter(10*time.Second),
+			circuitbreaker.WithRand(pinned(0)),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		requireHalfOpenAfter(t, b, fakeClock, 2*time.Second)
+	})
+
+	t.Run("initial open state is jittered", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithInitialState(circuitbreaker.Open),
+			circuitbreaker.WithCooldownJitter(4*time.Second),
+			circuitbreaker.WithRand(pinned(0.25)),
+		)
+		require.NoError(t, err)
+
+		requireHalfOpenAfter(t, b, fakeClock, 8*time.Second)
+	})
+}
+
+func TestEscalatingCooldown(t *testing.T) {
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+	fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+
+	t.Run("invalid options", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			option circuitbreaker.Option
+			err    string
+		}{
+			{name: "invalid base", option: circuitbreaker.WithEscalatingCooldown(0, time.Minute, 2), err: "base cooldown must be greater than 0"},
+			{name: "max below base", option: circuitbreaker.WithEscalatingCooldown(time.Minute, time.Second, 2), err: "max cooldown must not be less than base cooldown"},
+			{name: "factor below one", option: circuitbreaker.WithEscalatingCooldown(time.Second, time.Minute, 0.5), err: "cooldown factor must be at least 1"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1, tt.option)
+				require.Error(t, err)
+				require.Nil(t, b)
+				require.Contains(t, err.Error(), tt.err)
+			})
+		}
+	})
+
+	newBreaker := func(t *testing.T) (*circuitbreaker.Breaker[string, string], *clockwork.FakeClock) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 10*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithEscalatingCooldown(5*time.Second, 20*time.Second, 2),
+		)
+		require.NoError(t, err)
+		return b, fakeClock
+	}
+
+	// waitOut asserts b stays open for exactly d, leaving the clock just past it so the next call probes
+	waitOut := func(t *testing.T, b *circuitbreaker.Breaker[string, string], fakeClock *clockwork.FakeClock, d time.Duration) {
+		t.Helper()
+		fakeClock.Advance(d)
+		_, err := b.Do(context.Background(), "req", succeed)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		fakeClock.Advance(time.Millisecond)
+	}
+
+	t.Run("cooldown grows across consecutive trips up to the max", func(t *testing.T) {
+		b, fakeClock := newBreaker(t)
+		ctx := context.Background()
+
+		_, err := b.Do(ctx, "req", fail)
+		require.Error(t, err)
+
+		for _, cooldown := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 20 * time.Second} {
+			waitOut(t, b, fakeClock, cooldown)
+			// The probe fails, tripping the circuit again
+			_, err = b.Do(ctx, "req", fail)
+			require.Error(t, err)
+			require.Equal(t, circuitbreaker.Open, b.State())
+		}
+	})
+
+	t.Run("closing briefly doesn't reset the cooldown", func(t *testing.T) {
+		b, fakeClock := newBreaker(t)
+		ctx := context.Background()
+
+		_, err := b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		waitOut(t, b, fakeClock, 5*time.Second)
+
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+
+		// Flapping straight back open escalates
+		fakeClock.Advance(time.Second)
+		_, err = b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		waitOut(t, b, fakeClock, 10*time.Second)
+	})
+
+	t.Run("cooldown resets after staying closed for the stable window", func(t *testing.T) {
+		b, fakeClock := newBreaker(t)
+		ctx := context.Background()
+
+		_, err := b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		waitOut(t, b, fakeClock, 5*time.Second)
+		_, err = b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		waitOut(t, b, fakeClock, 10*time.Second)
+
+		_, err = b.Do(ctx, "req", succeed)
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+
+		fakeClock.Advance(20 * time.Second)
+		_, err = b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		waitOut(t, b, fakeClock, 5*time.Second)
+	})
+
+	t.Run("manual trips escalate too", func(t *testing.T) {
+		b, fakeClock := newBreaker(t)
+
+		b.Trip()
+		waitOut(t, b, fakeClock, 5*time.Second)
+		b.Trip()
+		waitOut(t, b, fakeClock, 10*time.Second)
+	})
+
+	t.Run("tripping a circuit that is still open doesn't escalate", func(t *testing.T) {
+		b, fakeClock := newBreaker(t)
+
+		b.Trip()
+		fakeClock.Advance(time.Second)
+		b.Trip()
+		b.Trip()
+
+		// The open period restarts at the same cooldown, and the next trip is only the second
+		waitOut(t, b, fakeClock, 5*time.Second)
+		_, err := b.Do(context.Background(), "req", succeed)
+		require.NoError(t, err)
+
+		b.Trip()
+		waitOut(t, b, fakeClock, 10*time.Second)
+		_, err = b.Do(context.Background(), "req", succeed)
+		require.NoError(t, err)
+	})
+}
+
+func TestAccessors(t *testing.T) {
+	t.Run("config reflects the constructor arguments", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 5*time.Second, 2, 4)
+		require.NoError(t, err)
+
+		require.Equal(t, circuitbreaker.Config{
+			FailureThreshold: 3,
+			SuccessThreshold: 4,
+			Cooldown:         5 * time.Second,
+			MaxRequests:      2,
+		}, b.Config())
+	})
+
+	t.Run("counters during a half-open probe sequence", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 2, 2, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		ctx := context.Background()
+		release := []chan struct{}{make(chan struct{}), make(chan struct{})}
+		done := make(chan error, 2)
+		for i := range release {
+			go func() {
+				_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) {
+					<-release[i]
+					return "ok", nil
+				})
+				done <- err
+			}()
+			// Both probes are in flight, neither has succeeded yet
+			require.Eventually(t, func() bool {
+				return b.Requests() == i+1
+			}, time.Second, time.Millisecond)
+		}
+		require.Equal(t, circuitbreaker.HalfOpen, b.State())
+		require.Zero(t, b.Successes())
+
+		_, err = b.Do(ctx, "req", func(context.Context, string) (string, error) { return "ok", nil })
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitHalfOpen)
+
+		// The first success is counted and frees admission for another probe
+		close(release[0])
+		require.NoError(t, <-done)
+		require.Equal(t, 1, b.Successes())
+		require.Zero(t, b.Requests())
+		require.Equal(t, circuitbreaker.HalfOpen, b.State())
+
+		close(release[1])
+		require.NoError(t, <-done)
+		require.Equal(t, 2, b.Successes())
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+}
+
+func TestProbePolicy(t *testing.T) {
+	t.Run("nil policy", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 1, circuitbreaker.WithProbePolicy(nil))
+		require.Error(t, err)
+		require.Nil(t, b)
+		require.Contains(t, err.Error(), "probe policy is nil")
+	})
+
+	t.Run("policy decides which half-open calls are probes", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		consulted := 0
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 3,
+			circuitbreaker.WithClock(fakeClock),
+			// Admit every third call
+			circuitbreaker.WithProbePolicy(func() bool {
+				consulted++
+				return consulted%3 == 0
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+		// The policy is not consulted while the circuit is closed
+		_, err = b.Do(ctx, "req", func(context.Context, string) (string, error) { return "", errors.New("boom") })
+		require.Error(t, err)
+		require.Zero(t, consulted)
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		fakeClock.Advance(2 * time.Second)
+
+		var admitted []int
+		for i := 1; i <= 9; i++ {
+			_, err := b.Do(ctx, "req", succeed)
+			if errors.Is(err, circuitbreaker.ErrCircuitHalfOpen) {
+				continue
+			}
+			require.NoError(t, err)
+			admitted = append(admitted, i)
+		}
+		require.Equal(t, []int{3, 6, 9}, admitted)
+		require.Equal(t, 9, consulted)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("policy replaces the max requests cap", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 3,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithProbePolicy(func() bool { return true }),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		// Three probes in flight at once, although maxRequests is 1
+		ctx := context.Background()
+		release := make(chan struct{})
+		done := make(chan error, 3)
+		for range 3 {
+			go func() {
+				_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) {
+					<-release
+					return "ok", nil
+				})
+				done <- err
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return b.Requests() == 3
+		}, time.Second, time.Millisecond)
+
+		close(release)
+		for range 3 {
+			require.NoError(t, <-done)
+		}
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("rejected calls reopen nothing", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithProbePolicy(func() bool { return false }),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		for range 5 {
+			_, err := b.Do(context.Background(), "req", func(context.Context, string) (string, error) {
+				t.Fatal("rejected call reached the dependency")
+				return "", nil
+			})
+			require.ErrorIs(t, err, circuitbreaker.ErrCircuitHalfOpen)
+		}
+		require.Equal(t, circuitbreaker.HalfOpen, b.State())
+	})
+}
+
+func TestStrictClosedRecovery(t *testing.T) {
+	ctx := context.Background()
+	fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+
+	call := func(t *testing.T, b *circuitbreaker.Breaker[string, string], fns ...func(context.Context, string) (string, error)) {
+		t.Helper()
+		for _, fn := range fns {
+			_, _ = b.Do(ctx, "req", fn)
+		}
+	}
+
+	t.Run("without it a single success resets failures", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 3)
+		require.NoError(t, err)
+
+		call(t, b, fail, fail, succeed)
+		require.Zero(t, b.Failures())
+
+		call(t, b, fail)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("failures are kept until enough consecutive successes", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 3, circuitbreaker.WithStrictClosedRecovery())
+		require.NoError(t, err)
+
+		call(t, b, fail, fail, succeed, succeed)
+		require.Equal(t, 2, b.Failures())
+		require.Equal(t, 2, b.Successes())
+
+		call(t, b, succeed)
+		require.Zero(t, b.Failures())
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("briefly recovering downstream trips the circuit", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, 1*time.Second, 1, 3, circuitbreaker.WithStrictClosedRecovery())
+		require.NoError(t, err)
+
+		call(t, b, fail, succeed, succeed, fail)
+		require.Equal(t, 2, b.Failures())
+		// The failure restarted the run of successes
+		require.Zero(t, b.Successes())
+
+		call(t, b, succeed, fail)
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+
+	t.Run("half-open recovery is unchanged", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 2,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithStrictClosedRecovery(),
+		)
+		require.NoError(t, err)
+
+		call(t, b, fail)
+		require.Equal(t, circuitbreaker.Open, b.State())
+		fakeClock.Advance(2 * time.Second)
+
+		call(t, b, succeed, succeed)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+		require.Zero(t, b.Failures())
+	})
+}
+
+func TestRejections(t *testing.T) {
+	ctx := context.Background()
+	fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+
+	t.Run("open rejections are classifiable and don't count as failures", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](2, time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithName("payments"),
+		)
+		require.NoError(t, err)
+
+		for range 2 {
+			_, err = b.Do(ctx, "req", fail)
+			require.False(t, circuitbreaker.IsRejection(err))
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+		before := b.Stats()
+
+		for range 3 {
+			_, err = b.Do(ctx, "req", fail)
+			require.True(t, circuitbreaker.IsRejection(err))
+			require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+			var rejected *circuitbreaker.ErrRejected
+			require.ErrorAs(t, err, &rejected)
+			require.Equal(t, "circuit is open – skipping call", rejected.Error())
+			require.Equal(t, "payments: circuit is open – skipping call", err.Error())
+		}
+
+		after := b.Stats()
+		require.Equal(t, before.Failures, after.Failures)
+		require.Equal(t, before.TotalFailures, after.TotalFailures)
+		require.Equal(t, before.ShortCircuits+3, after.ShortCircuits)
+	})
+
+	t.Run("half-open rejections are classifiable and don't count as failures", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		_, err = b.Do(ctx, "req", fail)
+		require.Error(t, err)
+		fakeClock.Advance(2 * time.Second)
+
+		// Hold the only probe slot
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) {
+				close(started)
+				<-release
+				return "ok", nil
+			})
+			done <- err
+		}()
+		<-started
+		before := b.Stats()
+
+		_, err = b.Do(ctx, "req", fail)
+		require.True(t, circuitbreaker.IsRejection(err))
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitHalfOpen)
+		require.Equal(t, circuitbreaker.ErrCircuitHalfOpen, err)
+
+		after := b.Stats()
+		require.Equal(t, before.Failures, after.Failures)
+		require.Equal(t, before.TotalFailures, after.TotalFailures)
+		require.Equal(t, circuitbreaker.HalfOpen, b.State())
+
+		close(release)
+		require.NoError(t, <-done)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("draining rejections are classifiable", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 1)
+		require.NoError(t, err)
+
+		b.Drain()
+		_, err = b.Do(ctx, "req", fail)
+		require.True(t, circuitbreaker.IsRejection(err))
+		require.ErrorIs(t, err, circuitbreaker.ErrDraining)
+		require.Zero(t, b.Failures())
+	})
+
+	t.Run("call failures aren't rejections", func(t *testing.T) {
+		require.False(t, circuitbreaker.IsRejection(nil))
+		require.False(t, circuitbreaker.IsRejection(errors.New("unavailable")))
+		require.True(t, circuitbreaker.IsRejection(fmt.Errorf("charging card: %w", circuitbreaker.ErrCircuitOpen)))
+	})
+}
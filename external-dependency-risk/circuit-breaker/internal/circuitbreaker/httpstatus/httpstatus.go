@@ -0,0 +1,101 @@
+package httpstatus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+)
+
+// StateReader is implemented by every circuit breaker in the circuitbreaker package
+type StateReader interface {
+	Name() string
+	State() circuitbreaker.State
+}
+
+// Policy decides from the states of the registered breakers, keyed by name, whether the service is healthy
+type Policy func(states map[string]circuitbreaker.State) bool
+
+// NoneOpen is healthy unless any breaker is open
+func NoneOpen(states map[string]circuitbreaker.State) bool {
+	for _, state := range states {
+		if state == circuitbreaker.Open {
+			return false
+		}
+	}
+	return true
+}
+
+// response is the JSON body served by the handler
+type response struct {
+	Healthy  bool                            `json:"healthy"`
+	Breakers map[string]circuitbreaker.State `json:"breakers"`
+}
+
+// handler reports the state of a set of named circuit breakers
+type handler struct {
+	policy   Policy
+	breakers []StateReader
+}
+
+// NewHandler creates an http.Handler that reports the state of the given breakers as JSON.
+// It responds 200 when the policy considers the states healthy and 503 otherwise.
+// Breakers are reported by name, so names must be unique.
+func NewHandler(policy Policy, breakers ...StateReader) (http.Handler, error) {
+	switch {
+	case policy == nil:
+		return nil, errors.New("policy is nil")
+	case len(breakers) == 0:
+		return nil, errors.New("at least one breaker is required")
+	}
+
+	names := make(map[string]struct{}, len(breakers))
+	for _, b := range breakers {
+		if isNil(b) {
+			return nil, errors.New("breaker is nil")
+		}
+		if _, ok := names[b.Name()]; ok {
+			return nil, fmt.Errorf("duplicate breaker name %q", b.Name())
+		}
+		names[b.Name()] = struct{}{}
+	}
+
+	return &handler{
+		policy:   policy,
+		breakers: breakers,
+	}, nil
+}
+
+// ServeHTTP writes the current state of every breaker
+func (h *handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	states := make(map[string]circuitbreaker.State, len(h.breakers))
+	for _, b := range h.breakers {
+		states[b.Name()] = b.State()
+	}
+
+	res := response{
+		Healthy:  h.policy(states),
+		Breakers: states,
+	}
+
+	status := http.StatusOK
+	if !res.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// isNil reports whether b is nil, including a nil breaker pointer held in the interface
+func isNil(b StateReader) bool {
+	if b == nil {
+		return true
+	}
+	v := reflect.ValueOf(b)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,108 @@
+package httpstatus_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker/httpstatus"
+)
+
+func newBreaker(t *testing.T, name string) *circuitbreaker.Breaker[string, string] {
+	t.Helper()
+
+	b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithName(name))
+	require.NoError(t, err)
+	return b
+}
+
+func serve(t *testing.T, h http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	return rec
+}
+
+func TestNewHandler(t *testing.T) {
+	t.Run("nil policy", func(t *testing.T) {
+		h, err := httpstatus.NewHandler(nil, newBreaker(t, "payments"))
+		require.Error(t, err)
+		require.Nil(t, h)
+	})
+
+	t.Run("no breakers", func(t *testing.T) {
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen)
+		require.Error(t, err)
+		require.Nil(t, h)
+	})
+
+	t.Run("nil breaker", func(t *testing.T) {
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen, nil)
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "breaker is nil")
+	})
+
+	t.Run("typed nil breaker", func(t *testing.T) {
+		var b *circuitbreaker.Breaker[string, string]
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen, newBreaker(t, "payments"), b)
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "breaker is nil")
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen, newBreaker(t, "payments"), newBreaker(t, "payments"))
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "duplicate breaker name")
+	})
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Run("200 when all breakers are closed", func(t *testing.T) {
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen, newBreaker(t, "payments"), newBreaker(t, "refunds"))
+		require.NoError(t, err)
+
+		rec := serve(t, h)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"healthy":true,"breakers":{"payments":"Closed","refunds":"Closed"}}`, rec.Body.String())
+	})
+
+	t.Run("503 when a breaker is open", func(t *testing.T) {
+		refunds := newBreaker(t, "refunds")
+		h, err := httpstatus.NewHandler(httpstatus.NoneOpen, newBreaker(t, "payments"), refunds)
+		require.NoError(t, err)
+
+		refunds.Trip()
+
+		rec := serve(t, h)
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.JSONEq(t, `{"healthy":false,"breakers":{"payments":"Closed","refunds":"Open"}}`, rec.Body.String())
+
+		refunds.Reset()
+		require.Equal(t, http.StatusOK, serve(t, h).Code)
+	})
+
+	t.Run("custom policy only fails on critical breakers", func(t *testing.T) {
+		criticalClosed := func(states map[string]circuitbreaker.State) bool {
+			return states["payments"] != circuitbreaker.Open
+		}
+
+		payments, refunds := newBreaker(t, "payments"), newBreaker(t, "refunds")
+		h, err := httpstatus.NewHandler(criticalClosed, payments, refunds)
+		require.NoError(t, err)
+
+		refunds.Trip()
+		require.Equal(t, http.StatusOK, serve(t, h).Code)
+
+		payments.Trip()
+		require.Equal(t, http.StatusServiceUnavailable, serve(t, h).Code)
+	})
+}
@@ -0,0 +1,134 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// KeyedBreaker keeps an independent circuit breaker per key derived from each request, e.g. per merchant,
+// so one failing key can't open the circuit for the others
+type KeyedBreaker[K comparable, Req, Res any] struct {
+	lock      sync.Mutex
+	clock     clockwork.Clock
+	key       func(Req) K
+	idleTTL   time.Duration // Breakers unused for longer than this are evicted
+	lastSweep time.Time
+	breakers  map[K]*keyedEntry[Req, Res]
+	newEntry  func(K) (*Breaker[Req, Res], error)
+}
+
+// keyedEntry is a key's breaker and when it was last used
+type keyedEntry[Req, Res any] struct {
+	breaker  *Breaker[Req, Res]
+	lastUsed time.Time
+}
+
+// NewKeyedBreaker creates a KeyedBreaker that lazily creates a breaker with the given configuration for every
+// key it sees, and evicts breakers idle for longer than idleTTL. The idle TTL should be longer than the cooldown,
+// so an open circuit isn't forgotten before it probes the dependency again.
+func NewKeyedBreaker[K comparable, Req, Res any](key func(Req) K, idleTTL time.Duration, failureThreshold int, cooldown time.Duration, maxRequests, successThreshold int, opts ...Option) (*KeyedBreaker[K, Req, Res], error) {
+	switch {
+	case key == nil:
+		return nil, errors.New("key func is nil")
+	case idleTTL <= 0:
+		return nil, errors.New("idleTTL must be greater than 0")
+	}
+
+	// Validate the shared configuration up front rather than on the first call for each key
+	b, err := NewBreaker[Req, Res](failureThreshold, cooldown, maxRequests, successThreshold, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyedBreaker[K, Req, Res]{
+		clock:     b.clock,
+		key:       key,
+		idleTTL:   idleTTL,
+		lastSweep: b.clock.Now(),
+		breakers:  make(map[K]*keyedEntry[Req, Res]),
+		newEntry: func(k K) (*Breaker[Req, Res], error) {
+			b, err := NewBreaker[Req, Res](failureThreshold, cooldown, maxRequests, successThreshold, opts...)
+			if err != nil {
+				return nil, err
+			}
+			// Name each breaker after its key so errors and callbacks say which circuit it was
+			if b.name == "" {
+				b.name = fmt.Sprint(k)
+			} else {
+				b.name = fmt.Sprintf("%s/%v", b.name, k)
+			}
+			return b, nil
+		},
+	}, nil
+}
+
+// Do executes fn with the given request through the breaker for the request's key
+func (kb *KeyedBreaker[K, Req, Res]) Do(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, error) {
+	b, err := kb.breaker(kb.key(req))
+	if err != nil {
+		var zero Res
+		return zero, err
+	}
+	return b.Do(ctx, req, fn)
+}
+
+// breaker returns the breaker for key, creating it if needed, and evicts idle breakers
+func (kb *KeyedBreaker[K, Req, Res]) breaker(key K) (*Breaker[Req, Res], error) {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	now := kb.clock.Now()
+	kb.evictIdle(now)
+
+	entry, ok := kb.breakers[key]
+	if !ok {
+		b, err := kb.newEntry(key)
+		if err != nil {
+			return nil, err
+		}
+		entry = &keyedEntry[Req, Res]{breaker: b}
+		kb.breakers[key] = entry
+	}
+	entry.lastUsed = now
+
+	return entry.breaker, nil
+}
+
+// evictIdle removes breakers unused for longer than the idle TTL. It sweeps at most once per idle TTL,
+// so calls don't pay for scanning every key. Must be called with the lock held.
+func (kb *KeyedBreaker[K, Req, Res]) evictIdle(now time.Time) {
+	if now.Sub(kb.lastSweep) < kb.idleTTL {
+		return
+	}
+	kb.lastSweep = now
+
+	for key, entry := range kb.breakers {
+		if now.Sub(entry.lastUsed) > kb.idleTTL {
+			delete(kb.breakers, key)
+		}
+	}
+}
+
+// State returns the state of the breaker for key, Closed if there is none
+func (kb *KeyedBreaker[K, Req, Res]) State(key K) State {
+	kb.lock.Lock()
+	entry, ok := kb.breakers[key]
+	kb.lock.Unlock()
+
+	if !ok {
+		return Closed
+	}
+	return entry.breaker.State()
+}
+
+// Len returns the number of keys with a breaker
+func (kb *KeyedBreaker[K, Req, Res]) Len() int {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+	return len(kb.breakers)
+}
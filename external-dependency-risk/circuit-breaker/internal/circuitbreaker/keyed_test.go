@@ -0,0 +1,155 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+)
+
+func merchantID(req service.PaymentRequest) string {
+	return req.MerchantID
+}
+
+func TestNewKeyedBreaker(t *testing.T) {
+	t.Run("nil key func", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](nil, time.Minute, 1, 1*time.Second, 1, 1)
+		require.Error(t, err)
+		require.Nil(t, kb)
+		require.Contains(t, err.Error(), "key func is nil")
+	})
+
+	t.Run("invalid idle TTL", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](merchantID, 0, 1, 1*time.Second, 1, 1)
+		require.Error(t, err)
+		require.Nil(t, kb)
+		require.Contains(t, err.Error(), "idleTTL must be greater than 0")
+	})
+
+	t.Run("invalid breaker configuration", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](merchantID, time.Minute, 0, 1*time.Second, 1, 1)
+		require.Error(t, err)
+		require.Nil(t, kb)
+		require.Contains(t, err.Error(), "failureThreshold must be greater than 0")
+	})
+}
+
+func TestKeyedBreaker(t *testing.T) {
+	merchantA := service.PaymentRequest{ID: "1", Amount: 100, MerchantID: "merchant-a"}
+	merchantB := service.PaymentRequest{ID: "2", Amount: 200, MerchantID: "merchant-b"}
+
+	// process fails every payment for merchant A
+	process := func(_ context.Context, req service.PaymentRequest) (service.PaymentResponse, error) {
+		if req.MerchantID == "merchant-a" {
+			return service.PaymentResponse{}, service.ErrServiceUnavailable
+		}
+		return service.PaymentResponse{ID: req.ID, Status: "success"}, nil
+	}
+
+	t.Run("failures for one merchant only open that merchant's circuit", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](
+			merchantID, time.Minute, 2, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clockwork.NewFakeClock()),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		for range 2 {
+			_, err = kb.Do(ctx, merchantA, process)
+			require.ErrorIs(t, err, service.ErrServiceUnavailable)
+		}
+		require.Equal(t, circuitbreaker.Open, kb.State("merchant-a"))
+
+		_, err = kb.Do(ctx, merchantA, process)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Contains(t, err.Error(), "merchant-a")
+
+		resp, err := kb.Do(ctx, merchantB, process)
+		require.NoError(t, err)
+		require.Equal(t, "success", resp.Status)
+		require.Equal(t, circuitbreaker.Closed, kb.State("merchant-b"))
+	})
+
+	t.Run("breakers are named after the shared name and their key", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](
+			merchantID, time.Minute, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(clockwork.NewFakeClock()),
+			circuitbreaker.WithName("payments"),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		_, err = kb.Do(ctx, merchantA, process)
+		require.Error(t, err)
+
+		_, err = kb.Do(ctx, merchantA, process)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Contains(t, err.Error(), "payments/merchant-a")
+	})
+
+	t.Run("unknown key is closed", func(t *testing.T) {
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](merchantID, time.Minute, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		require.Equal(t, circuitbreaker.Closed, kb.State("unknown"))
+		require.Zero(t, kb.Len())
+	})
+
+	t.Run("idle keys are evicted", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](
+			merchantID, time.Minute, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		_, err = kb.Do(ctx, merchantA, process)
+		require.Error(t, err)
+		_, err = kb.Do(ctx, merchantB, process)
+		require.NoError(t, err)
+		require.Equal(t, 2, kb.Len())
+		require.Equal(t, circuitbreaker.Open, kb.State("merchant-a"))
+
+		// Merchant B stays active while merchant A goes idle
+		fakeClock.Advance(45 * time.Second)
+		_, err = kb.Do(ctx, merchantB, process)
+		require.NoError(t, err)
+		fakeClock.Advance(45 * time.Second)
+		_, err = kb.Do(ctx, merchantB, process)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, kb.Len())
+		require.Equal(t, circuitbreaker.Closed, kb.State("merchant-a"))
+		require.Equal(t, circuitbreaker.Closed, kb.State("merchant-b"))
+	})
+
+	t.Run("evicted key starts with a fresh breaker", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		kb, err := circuitbreaker.NewKeyedBreaker[string, service.PaymentRequest, service.PaymentResponse](
+			merchantID, time.Minute, 1, 1*time.Second, 1, 1,
+			circuitbreaker.WithClock(fakeClock),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		_, err = kb.Do(ctx, merchantA, process)
+		require.Error(t, err)
+
+		fakeClock.Advance(2 * time.Minute)
+
+		calls := 0
+		_, err = kb.Do(ctx, merchantA, func(context.Context, service.PaymentRequest) (service.PaymentResponse, error) {
+			calls++
+			return service.PaymentResponse{}, errors.New("still failing")
+		})
+		require.EqualError(t, err, "still failing")
+		require.Equal(t, 1, calls)
+	})
+}
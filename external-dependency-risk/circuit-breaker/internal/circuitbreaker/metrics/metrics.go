@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+)
+
+// StatsReader is implemented by every circuit breaker in the circuitbreaker package
+type StatsReader interface {
+	Name() string
+	Stats() circuitbreaker.Stats
+}
+
+// collector reports the state and counters of a set of named circuit breakers
+type collector struct {
+	breakers []StatsReader
+
+	state         *prometheus.Desc
+	successes     *prometheus.Desc
+	failures      *prometheus.Desc
+	shortCircuits *prometheus.Desc
+}
+
+// NewCollector creates a Prometheus collector that reads the given breakers on every scrape.
+// Each breaker is labelled with its name, so names must be unique.
+func NewCollector(breakers ...StatsReader) (prometheus.Collector, error) {
+	if len(breakers) == 0 {
+		return nil, errors.New("at least one breaker is required")
+	}
+
+	names := make(map[string]struct{}, len(breakers))
+	for _, b := range breakers {
+		if isNil(b) {
+			return nil, errors.New("breaker is nil")
+		}
+		if _, ok := names[b.Name()]; ok {
+			return nil, fmt.Errorf("duplicate breaker name %q", b.Name())
+		}
+		names[b.Name()] = struct{}{}
+	}
+
+	labels := []string{"name"}
+	return &collector{
+		breakers: breakers,
+		state: prometheus.NewDesc(
+			"circuit_breaker_state",
+			"Current state of the circuit breaker (0 = closed, 1 = open, 2 = half-open).",
+			labels, nil,
+		),
+		successes: prometheus.NewDesc(
+			"circuit_breaker_successes_total",
+			"Calls that succeeded.",
+			labels, nil,
+		),
+		failures: prometheus.NewDesc(
+			"circuit_breaker_failures_total",
+			"Calls that failed and counted towards opening the circuit.",
+			labels, nil,
+		),
+		shortCircuits: prometheus.NewDesc(
+			"circuit_breaker_short_circuits_total",
+			"Calls rejected without reaching the dependency.",
+			labels, nil,
+		),
+	}, nil
+}
+
+// Describe sends the descriptors of every metric the collector reports
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	ch <- c.successes
+	ch <- c.failures
+	ch <- c.shortCircuits
+}
+
+// Collect reads a stats snapshot from each breaker and sends its metrics
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	for _, b := range c.breakers {
+		stats := b.Stats()
+
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, float64(stats.State), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.successes, prometheus.CounterValue, float64(stats.Successes), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(stats.TotalFailures), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.shortCircuits, prometheus.CounterValue, float64(stats.ShortCircuits), stats.Name)
+	}
+}
+
+// isNil reports whether b is nil, including a nil breaker pointer held in the interface
+func isNil(b StatsReader) bool {
+	if b == nil {
+		return true
+	}
+	v := reflect.ValueOf(b)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
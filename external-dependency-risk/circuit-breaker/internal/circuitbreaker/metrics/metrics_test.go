@@ -0,0 +1,97 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker/metrics"
+)
+
+func TestNewCollector(t *testing.T) {
+	t.Run("no breakers", func(t *testing.T) {
+		c, err := metrics.NewCollector()
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("nil breaker", func(t *testing.T) {
+		c, err := metrics.NewCollector(nil)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("typed nil breaker", func(t *testing.T) {
+		var b *circuitbreaker.Breaker[string, string]
+		c, err := metrics.NewCollector(b)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "breaker is nil")
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		first, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+		require.NoError(t, err)
+		second, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+		require.NoError(t, err)
+
+		c, err := metrics.NewCollector(first, second)
+		require.Error(t, err)
+		require.Nil(t, c)
+		require.Contains(t, err.Error(), "duplicate breaker name")
+	})
+}
+
+func TestCollect(t *testing.T) {
+	payments, err := circuitbreaker.NewBreaker[string, string](2, 1*time.Second, 1, 1, circuitbreaker.WithName("payments"))
+	require.NoError(t, err)
+
+	refunds, err := circuitbreaker.NewBreaker[string, string](2, 1*time.Second, 1, 1, circuitbreaker.WithName("refunds"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	succeed := func(context.Context, string) (string, error) { return "ok", nil }
+	fail := func(context.Context, string) (string, error) { return "", errors.New("unavailable") }
+
+	// One success, then two failures open the payments circuit and the next call is short-circuited
+	_, err = payments.Do(ctx, "request", succeed)
+	require.NoError(t, err)
+	_, err = payments.Do(ctx, "request", fail)
+	require.Error(t, err)
+	_, err = payments.Do(ctx, "request", fail)
+	require.Error(t, err)
+	_, err = payments.Do(ctx, "request", succeed)
+	require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+
+	_, err = refunds.Do(ctx, "request", succeed)
+	require.NoError(t, err)
+
+	c, err := metrics.NewCollector(payments, refunds)
+	require.NoError(t, err)
+
+	expected := `
+# HELP circuit_breaker_failures_total Calls that failed and counted towards opening the circuit.
+# TYPE circuit_breaker_failures_total counter
+circuit_breaker_failures_total{name="payments"} 2
+circuit_breaker_failures_total{name="refunds"} 0
+# HELP circuit_breaker_short_circuits_total Calls rejected without reaching the dependency.
+# TYPE circuit_breaker_short_circuits_total counter
+circuit_breaker_short_circuits_total{name="payments"} 1
+circuit_breaker_short_circuits_total{name="refunds"} 0
+# HELP circuit_breaker_state Current state of the circuit breaker (0 = closed, 1 = open, 2 = half-open).
+# TYPE circuit_breaker_state gauge
+circuit_breaker_state{name="payments"} 1
+circuit_breaker_state{name="refunds"} 0
+# HELP circuit_breaker_successes_total Calls that succeeded.
+# TYPE circuit_breaker_successes_total counter
+circuit_breaker_successes_total{name="payments"} 1
+circuit_breaker_successes_total{name="refunds"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
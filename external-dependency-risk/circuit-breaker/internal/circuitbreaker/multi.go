@@ -0,0 +1,58 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+)
+
+// MultiBreaker fails over between payment backends, each protected by its own circuit breaker
+type MultiBreaker struct {
+	backends []*circuitBreaker
+}
+
+// NewMultiBreaker creates a MultiBreaker trying backends in the given order, so the primary comes first
+func NewMultiBreaker(backends ...*circuitBreaker) (*MultiBreaker, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("no backends given")
+	}
+	for _, b := range backends {
+		if b == nil {
+			return nil, errors.New("backend is nil")
+		}
+	}
+
+	return &MultiBreaker{backends: backends}, nil
+}
+
+// ProcessPayment tries each backend in order, moving on only when a breaker rejects the call or the backend is
+// unavailable, and returns the first success or the last error. Any other error, such as an invalid request or
+// a timeout, would fail the same way on every backend, so it's returned straight away.
+func (m *MultiBreaker) ProcessPayment(ctx context.Context, request service.PaymentRequest) (service.PaymentResponse, error) {
+	var err error
+	for _, backend := range m.backends {
+		// Don't try the next backend if the caller has given up
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return service.PaymentResponse{}, ctxErr
+		}
+
+		// An open breaker rejects without calling its backend, so it's skipped straight away.
+		// Going through Do rather than checking State lets it move to HalfOpen once its cooldown passes.
+		var resp service.PaymentResponse
+		resp, err = backend.ProcessPayment(ctx, request)
+		if err == nil {
+			return resp, nil
+		}
+		if !failover(err) {
+			return service.PaymentResponse{}, err
+		}
+	}
+
+	return service.PaymentResponse{}, err
+}
+
+// failover reports whether err means the request may still succeed on another backend
+func failover(err error) bool {
+	return IsRejection(err) || errors.Is(err, service.ErrServiceUnavailable)
+}
@@ -0,0 +1,235 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/mocks"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+)
+
+func TestNewMultiBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("no backends", func(t *testing.T) {
+		mb, err := circuitbreaker.NewMultiBreaker()
+		require.Error(t, err)
+		require.Nil(t, mb)
+		require.Contains(t, err.Error(), "no backends given")
+	})
+
+	t.Run("nil backend", func(t *testing.T) {
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(cb, nil)
+		require.Error(t, err)
+		require.Nil(t, mb)
+		require.Contains(t, err.Error(), "backend is nil")
+	})
+}
+
+func TestMultiBreaker(t *testing.T) {
+	request := service.PaymentRequest{Amount: 100}
+
+	t.Run("primary serves the request while its circuit is closed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+		primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		primary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{ID: "primary"}, nil)
+
+		resp, err := mb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, "primary", resp.ID)
+	})
+
+	t.Run("open primary is skipped and the secondary serves the request", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+		primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		primaryCB.Trip()
+
+		// The primary mock has no expectations, so calling it would fail the test
+		ctx := context.Background()
+		secondary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{ID: "secondary"}, nil)
+
+		resp, err := mb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, "secondary", resp.ID)
+	})
+
+	t.Run("failing primary falls over to the secondary", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+		primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		primary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, fmt.Errorf("primary: %w", service.ErrServiceUnavailable))
+		secondary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{ID: "secondary"}, nil).Times(2)
+
+		resp, err := mb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, "secondary", resp.ID)
+		require.Equal(t, circuitbreaker.Open, primaryCB.State())
+
+		// The primary's circuit is now open, so the next request goes straight to the secondary
+		resp, err = mb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, "secondary", resp.ID)
+	})
+
+	t.Run("every backend failing returns the last error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+		primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		secondaryErr := fmt.Errorf("secondary: %w", service.ErrServiceUnavailable)
+		primary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, fmt.Errorf("primary: %w", service.ErrServiceUnavailable))
+		secondary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, secondaryErr)
+
+		_, err = mb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, secondaryErr)
+	})
+
+	t.Run("errors that aren't retryable are returned without failing over", func(t *testing.T) {
+		tests := []struct {
+			name string
+			err  error
+		}{
+			{name: "invalid request", err: fmt.Errorf("payment processing failed: %w", service.ErrInvalidRequest)},
+			{name: "timeout", err: context.DeadlineExceeded},
+			{name: "unknown error", err: errors.New("unexpected response")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				// The secondary mock has no expectations, so calling it would fail the test
+				primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+				primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1)
+				require.NoError(t, err)
+				secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1)
+				require.NoError(t, err)
+
+				mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+				require.NoError(t, err)
+
+				ctx := context.Background()
+				primary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{}, tt.err)
+
+				_, err = mb.ProcessPayment(ctx, request)
+				require.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+
+	t.Run("every circuit open returns circuit open", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primaryCB, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		primaryCB.Trip()
+		secondaryCB.Trip()
+
+		_, err = mb.ProcessPayment(context.Background(), request)
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+
+	t.Run("primary is probed again once its cooldown passes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clockwork.NewFakeClock()
+		primary, secondary := mocks.NewMockPaymentProcessor(ctrl), mocks.NewMockPaymentProcessor(ctrl)
+		primaryCB, err := circuitbreaker.New(primary, 1, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(secondary, 1, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		primaryCB.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		ctx := context.Background()
+		primary.EXPECT().ProcessPayment(ctx, request).Return(service.PaymentResponse{ID: "primary"}, nil)
+
+		resp, err := mb.ProcessPayment(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, "primary", resp.ID)
+		require.Equal(t, circuitbreaker.Closed, primaryCB.State())
+	})
+
+	t.Run("cancelled context stops before the next backend", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		primaryCB, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		secondaryCB, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		mb, err := circuitbreaker.NewMultiBreaker(primaryCB, secondaryCB)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = mb.ProcessPayment(ctx, request)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,32 @@
+package circuitbreaker
+
+import (
+	"context"
+)
+
+// protectable is implemented by every circuit breaker in this package, whatever it wraps
+type protectable interface {
+	core() *breaker
+}
+
+// core returns the state machine shared by every wrapper
+func (cb *breaker) core() *breaker {
+	return cb
+}
+
+// Protect runs fn through the circuit breaker, sharing its state with every other call made through it.
+// The breaker's fallback is not used, as it's typed to the requests the breaker was created for.
+func Protect(ctx context.Context, cb protectable, fn func(context.Context) error) error {
+	_, err := ProtectValue(ctx, cb, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// ProtectValue runs fn through the circuit breaker like Protect and returns its result
+func ProtectValue[T any](ctx context.Context, cb protectable, fn func(context.Context) (T, error)) (T, error) {
+	b := &Breaker[struct{}, T]{breaker: cb.core()}
+	return b.Do(ctx, struct{}{}, func(ctx context.Context, _ struct{}) (T, error) {
+		return fn(ctx)
+	})
+}
@@ -0,0 +1,89 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/mocks"
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+)
+
+func TestProtect(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	t.Run("closures trip and recover the breaker", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](2, 1*time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		calls := 0
+		failing := func(context.Context) error {
+			calls++
+			return errUnavailable
+		}
+
+		for range 2 {
+			require.ErrorIs(t, circuitbreaker.Protect(ctx, b, failing), errUnavailable)
+		}
+		require.Equal(t, circuitbreaker.Open, b.State())
+
+		require.ErrorIs(t, circuitbreaker.Protect(ctx, b, failing), circuitbreaker.ErrCircuitOpen)
+		require.Equal(t, 2, calls)
+
+		fakeClock.Advance(2 * time.Second)
+		value, err := circuitbreaker.ProtectValue(ctx, b, func(context.Context) (int, error) {
+			return 42, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 42, value)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("closures share state with the payment breaker", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// The mock has no expectations, so processing a payment would fail the test
+		cb, err := circuitbreaker.New(mocks.NewMockPaymentProcessor(ctrl), 1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.ErrorIs(t, circuitbreaker.Protect(ctx, cb, func(context.Context) error { return errUnavailable }), errUnavailable)
+		require.Equal(t, circuitbreaker.Open, cb.State())
+
+		_, err = cb.ProcessPayment(ctx, service.PaymentRequest{Amount: 100})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+
+	t.Run("value is zero when rejected", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1)
+		require.NoError(t, err)
+		b.Trip()
+
+		value, err := circuitbreaker.ProtectValue(context.Background(), b, func(context.Context) (string, error) {
+			return "called", nil
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Empty(t, value)
+	})
+
+	t.Run("call timeout applies", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, 1*time.Second, 1, 1, circuitbreaker.WithCallTimeout(10*time.Millisecond))
+		require.NoError(t, err)
+
+		err = circuitbreaker.Protect(context.Background(), b, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+}
@@ -0,0 +1,111 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CallStream runs a long-lived call, such as a stream, through the circuit breaker. Rather than only judging the
+// call when fn returns, fn reports the outcome of each message or health check as it goes with report, which feeds
+// the breaker's counters live, and fn's returned error is recorded as a final report. A stream admitted as a
+// half-open probe with WithHalfOpenSuccessRatio counts as one probe, decided by its first result that isn't ignored.
+//
+// If a report finds the circuit open, whether tripped by the stream or by other calls, the stream's context is
+// cancelled with ErrCircuitOpen as its cause, later reports are ignored and CallStream returns an error wrapping
+// ErrCircuitOpen. The call timeout set with WithCallTimeout doesn't apply to streams.
+func (cb *breaker) CallStream(ctx context.Context, fn func(ctx context.Context, report func(error)) error) error {
+	ctx, span := cb.startSpan(ctx)
+	defer span.End()
+
+	generation, slot, err := cb.allow()
+	if err != nil {
+		recordOutcome(span, err)
+		return err
+	}
+	if slot {
+		defer func() { <-cb.halfOpenSlots }()
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	s := &stream{breaker: cb, generation: generation, cancel: cancel}
+	err = fn(ctx, s.report)
+	if !s.finish(err) {
+		err = fmt.Errorf("stream cancelled: %w", ErrCircuitOpen)
+	}
+	recordOutcome(span, err)
+	return err
+}
+
+// stream tracks a call made with CallStream while it reports its results
+type stream struct {
+	breaker *breaker
+	cancel  context.CancelCauseFunc
+
+	lock       sync.Mutex
+	generation uint64 // State generation the stream's results count towards
+	tripped    bool   // Set once the circuit is found open, after which results are ignored
+	probed     bool   // Set once a result has decided the stream's half-open probe
+	done       bool   // Set once fn has returned, after which results are ignored
+}
+
+// report records an intermediate result of the stream
+func (s *stream) report(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.tripped || s.done {
+		return
+	}
+	s.record(err, false)
+}
+
+// finish records the stream's final result, reporting false if the circuit opened while it ran
+func (s *stream) finish(err error) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.done = true
+	if s.tripped {
+		return false
+	}
+	s.record(err, true)
+	return true
+}
+
+// record feeds a result to the breaker, cancelling the stream if the circuit is open. final is set for the
+// result fn returned. It must be called with the stream's lock held.
+func (s *stream) record(err error, final bool) {
+	cb := s.breaker
+	ignored := cb.ignored(err)
+
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch {
+	case s.generation == cb.generation && cb.state == HalfOpen && cb.probeTotal > 0:
+		// The stream was admitted as a single probe, so only one result decides it: the first that isn't
+		// ignored, or the final one. The rest only count towards the totals.
+		if s.probed || (ignored && !final) {
+			cb.countLocked(err, ignored)
+			break
+		}
+		s.probed = true
+		cb.recordLocked(s.generation, err, ignored)
+	default:
+		// A result from a generation that has since changed is stale and only counts towards the totals
+		cb.recordLocked(s.generation, err, ignored)
+	}
+
+	switch cb.state {
+	case Open:
+		s.tripped = true
+		s.cancel(ErrCircuitOpen)
+	case Closed:
+		// Whether this result closed the circuit or another call changed the state since the stream's last
+		// result, later results count towards the closed state
+		s.generation = cb.generation
+	}
+}
@@ -0,0 +1,176 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/circuitbreaker"
+)
+
+func TestCallStream(t *testing.T) {
+	errMessage := errors.New("message failed")
+
+	t.Run("reported failures open the circuit mid-stream", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, time.Second, 1, 1)
+		require.NoError(t, err)
+
+		var stateWhileStreaming []circuitbreaker.State
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			for range 3 {
+				report(errMessage)
+				stateWhileStreaming = append(stateWhileStreaming, b.State())
+			}
+
+			// The stream is cancelled as soon as the circuit opens
+			require.ErrorIs(t, ctx.Err(), context.Canceled)
+			require.ErrorIs(t, context.Cause(ctx), circuitbreaker.ErrCircuitOpen)
+
+			// Reports after the trip are ignored
+			report(errMessage)
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Equal(t, []circuitbreaker.State{circuitbreaker.Closed, circuitbreaker.Closed, circuitbreaker.Open}, stateWhileStreaming)
+		require.Equal(t, uint64(3), b.Stats().TotalFailures)
+
+		// Calls are rejected while the circuit is open
+		err = b.CallStream(context.Background(), func(context.Context, func(error)) error {
+			t.Fatal("stream started while the circuit is open")
+			return nil
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.True(t, circuitbreaker.IsRejection(err))
+	})
+
+	t.Run("reported successes reset failures", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](3, time.Second, 1, 1)
+		require.NoError(t, err)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			report(errMessage)
+			report(errMessage)
+			require.Equal(t, 2, b.Failures())
+
+			report(nil)
+			require.Zero(t, b.Failures())
+
+			report(errMessage)
+			return nil
+		})
+		require.NoError(t, err)
+		// The final result is recorded like a report
+		require.Zero(t, b.Failures())
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("returned error is recorded", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](2, time.Second, 1, 1)
+		require.NoError(t, err)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			report(errMessage)
+			return errMessage
+		})
+		require.ErrorIs(t, err, errMessage)
+		require.False(t, circuitbreaker.IsRejection(err))
+		require.Equal(t, circuitbreaker.Open, b.State())
+	})
+
+	t.Run("half-open stream closes the circuit and keeps counting", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](2, time.Second, 1, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			require.Equal(t, circuitbreaker.HalfOpen, b.State())
+			report(nil)
+			require.Equal(t, circuitbreaker.Closed, b.State())
+
+			// Results after the circuit closed count towards the closed state
+			report(errMessage)
+			require.Equal(t, 1, b.Failures())
+			report(errMessage)
+			require.Equal(t, circuitbreaker.Open, b.State())
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+
+	t.Run("stream keeps counting after other calls close the circuit", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](2, time.Second, 2, 1, circuitbreaker.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			// Another half-open call closes the circuit, so the stream's generation is out of date
+			_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) { return "ok", nil })
+			require.NoError(t, err)
+			require.Equal(t, circuitbreaker.Closed, b.State())
+
+			// The stale result isn't counted against the closed state, but later ones are
+			report(errMessage)
+			require.Zero(t, b.Failures())
+			report(errMessage)
+			require.Equal(t, 1, b.Failures())
+			report(errMessage)
+			require.Equal(t, circuitbreaker.Open, b.State())
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+	})
+
+	t.Run("half-open stream counts as a single probe", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 2, 1,
+			circuitbreaker.WithClock(fakeClock),
+			circuitbreaker.WithHalfOpenSuccessRatio(2, 3),
+		)
+		require.NoError(t, err)
+
+		b.Trip()
+		fakeClock.Advance(2 * time.Second)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			// However many results the stream reports, its probe only counts one success
+			for range 3 {
+				report(nil)
+			}
+			require.Equal(t, circuitbreaker.HalfOpen, b.State())
+
+			// A second probe is still admitted alongside the stream and meets the ratio
+			_, err := b.Do(ctx, "req", func(context.Context, string) (string, error) { return "ok", nil })
+			require.NoError(t, err)
+			require.Equal(t, circuitbreaker.Closed, b.State())
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, circuitbreaker.Closed, b.State())
+	})
+
+	t.Run("circuit opened by other calls cancels the stream on its next report", func(t *testing.T) {
+		b, err := circuitbreaker.NewBreaker[string, string](1, time.Second, 1, 1)
+		require.NoError(t, err)
+
+		err = b.CallStream(context.Background(), func(ctx context.Context, report func(error)) error {
+			b.Trip()
+			require.NoError(t, ctx.Err())
+
+			report(nil)
+			require.ErrorIs(t, context.Cause(ctx), circuitbreaker.ErrCircuitOpen)
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, circuitbreaker.ErrCircuitOpen)
+		require.Zero(t, b.Stats().TotalFailures)
+	})
+}
@@ -5,9 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+)
+
+var (
+	// ErrServiceUnavailable is returned when the payment service is unhealthy or a call fails transiently
+	ErrServiceUnavailable = errors.New("payment service unavailable")
+	// ErrInvalidRequest marks a payment request that fails validation and should not be retried
+	ErrInvalidRequest = errors.New("invalid payment request")
 )
 
 // PaymentRequest represents a payment processing request
@@ -31,43 +40,120 @@ type PaymentResponse struct {
 
 // paymentService simulates an external payment processing service
 type paymentService struct {
+	newID  func() string
+	random func() float64 // Returns a number in [0.0,1.0) used to simulate failures
+	clock  clockwork.Clock
+
+	lock        sync.RWMutex // Guards failureRate and isHealthy, which can change while payments are processed
 	failureRate float64
 	isHealthy   bool
 }
 
+// Option is a functional option for configuring the payment service
+type Option func(*paymentService) error
+
+// WithIDGenerator sets a custom generator for transaction IDs
+func WithIDGenerator(fn func() string) Option {
+	return func(s *paymentService) error {
+		if fn == nil {
+			return errors.New("id generator is nil")
+		}
+		s.newID = fn
+		return nil
+	}
+}
+
+// WithRand sets the random source used to simulate failures
+func WithRand(r *rand.Rand) Option {
+	return func(s *paymentService) error {
+		if r == nil {
+			return errors.New("rand is nil")
+		}
+		// rand.Rand isn't safe for concurrent use
+		var lock sync.Mutex
+		s.random = func() float64 {
+			lock.Lock()
+			defer lock.Unlock()
+			return r.Float64()
+		}
+		return nil
+	}
+}
+
+// WithClock sets a custom clock for the service
+func WithClock(clock clockwork.Clock) Option {
+	return func(s *paymentService) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		s.clock = clock
+		return nil
+	}
+}
+
 // NewPaymentService creates a new payment service
-func NewPaymentService(failureRate float64) (*paymentService, error) {
+func NewPaymentService(failureRate float64, opts ...Option) (*paymentService, error) {
 	if failureRate < 0 || failureRate > 1 {
 		return nil, errors.New("failure rate must be between 0 and 1")
 	}
 
-	return &paymentService{
+	s := &paymentService{
 		failureRate: failureRate,
 		isHealthy:   true,
-	}, nil
+		newID:       func() string { return uuid.New().String() }, // Default to random UUIDs
+		random:      rand.Float64,                                 // Default to the global random source
+		clock:       clockwork.NewRealClock(),                     // Default to real clock
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
 }
 
 // ProcessPayment processes a payment request
 func (s *paymentService) ProcessPayment(ctx context.Context, request PaymentRequest) (PaymentResponse, error) {
 	// Check health and simulate failures
-	if !s.isHealthy || rand.Float64() < s.failureRate {
-		return PaymentResponse{}, fmt.Errorf("payment processing failed: payment service unavailable for request %s", request.ID)
+	healthy, failureRate := s.health()
+	if !healthy || s.random() < failureRate {
+		return PaymentResponse{}, fmt.Errorf("payment processing failed: %w for request %s", ErrServiceUnavailable, request.ID)
 	}
 
 	// Create successful response
 	response := PaymentResponse{
 		ID:            request.ID,
-		TransactionID: uuid.New().String(),
+		TransactionID: s.newID(),
 		Status:        "completed",
 		Amount:        request.Amount,
 		Currency:      request.Currency,
-		ProcessedAt:   time.Now(),
+		ProcessedAt:   s.clock.Now(),
 	}
 
 	return response, nil
 }
 
+// health returns whether the service is healthy and the rate at which it fails
+func (s *paymentService) health() (bool, float64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.isHealthy, s.failureRate
+}
+
 // SetHealthy sets the health status of the service
 func (s *paymentService) SetHealthy(healthy bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	s.isHealthy = healthy
 }
+
+// Reset makes the service healthy again with a failure rate of 0, e.g. between demos or tests
+func (s *paymentService) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.isHealthy = true
+	s.failureRate = 0
+}
@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/circuit-breaker/internal/service"
+)
+
+func TestNewPaymentService(t *testing.T) {
+	t.Run("invalid failure rate", func(t *testing.T) {
+		s, err := service.NewPaymentService(1.5)
+		require.Error(t, err)
+		require.Nil(t, s)
+	})
+
+	t.Run("nil id generator", func(t *testing.T) {
+		s, err := service.NewPaymentService(0, service.WithIDGenerator(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "id generator is nil")
+	})
+
+	t.Run("nil rand", func(t *testing.T) {
+		s, err := service.NewPaymentService(0, service.WithRand(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "rand is nil")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		s, err := service.NewPaymentService(0, service.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		s, err := service.NewPaymentService(0)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+	})
+}
+
+func TestProcessPayment(t *testing.T) {
+	t.Run("default id generator", func(t *testing.T) {
+		s, err := service.NewPaymentService(0)
+		require.NoError(t, err)
+
+		response, err := s.ProcessPayment(context.Background(), service.PaymentRequest{ID: "payment-1"})
+		require.NoError(t, err)
+		require.NotEmpty(t, response.TransactionID)
+	})
+
+	t.Run("custom id generator", func(t *testing.T) {
+		var count int
+		s, err := service.NewPaymentService(0, service.WithIDGenerator(func() string {
+			count++
+			return fmt.Sprintf("txn-%d", count)
+		}))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		response, err := s.ProcessPayment(ctx, service.PaymentRequest{ID: "payment-1", Amount: 10, Currency: "USD"})
+		require.NoError(t, err)
+		require.Equal(t, "txn-1", response.TransactionID)
+		require.Equal(t, "payment-1", response.ID)
+
+		response, err = s.ProcessPayment(ctx, service.PaymentRequest{ID: "payment-2", Amount: 20, Currency: "USD"})
+		require.NoError(t, err)
+		require.Equal(t, "txn-2", response.TransactionID)
+		require.Equal(t, "payment-2", response.ID)
+	})
+}
+
+func TestProcessPaymentErrors(t *testing.T) {
+	t.Run("service unavailable", func(t *testing.T) {
+		s, err := service.NewPaymentService(0)
+		require.NoError(t, err)
+		s.SetHealthy(false)
+
+		_, err = s.ProcessPayment(context.Background(), service.PaymentRequest{ID: "payment-1", Amount: 10})
+		require.Error(t, err)
+		require.True(t, errors.Is(err, service.ErrServiceUnavailable))
+		require.False(t, errors.Is(err, service.ErrInvalidRequest))
+		require.Contains(t, err.Error(), "payment service unavailable for request payment-1")
+	})
+
+	t.Run("failure injection", func(t *testing.T) {
+		s, err := service.NewPaymentService(1)
+		require.NoError(t, err)
+
+		_, err = s.ProcessPayment(context.Background(), service.PaymentRequest{ID: "payment-1", Amount: 10})
+		require.ErrorIs(t, err, service.ErrServiceUnavailable)
+	})
+}
+
+func TestProcessPaymentDeterminism(t *testing.T) {
+	t.Run("seeded rand gives a deterministic failure sequence", func(t *testing.T) {
+		s, err := service.NewPaymentService(0.5, service.WithRand(rand.New(rand.NewSource(1))))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		// Seed 1 yields 0.60, 0.94, 0.66, 0.44, 0.42, 0.69
+		expected := []bool{true, true, true, false, false, true}
+		for i, succeeds := range expected {
+			_, err := s.ProcessPayment(ctx, service.PaymentRequest{ID: fmt.Sprintf("payment-%d", i)})
+			if succeeds {
+				require.NoError(t, err, "call %d", i)
+			} else {
+				require.ErrorIs(t, err, service.ErrServiceUnavailable, "call %d", i)
+			}
+		}
+	})
+
+	t.Run("injected random source is safe for concurrent use", func(t *testing.T) {
+		s, err := service.NewPaymentService(0.5, service.WithRand(rand.New(rand.NewSource(1))))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = s.ProcessPayment(ctx, service.PaymentRequest{ID: fmt.Sprintf("payment-%d", i)})
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("processed time comes from the injected clock", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		s, err := service.NewPaymentService(0, service.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		response, err := s.ProcessPayment(context.Background(), service.PaymentRequest{ID: "payment-1"})
+		require.NoError(t, err)
+		require.Equal(t, fakeClock.Now(), response.ProcessedAt)
+	})
+}
+
+func TestReset(t *testing.T) {
+	t.Run("reset makes the service healthy with no failures", func(t *testing.T) {
+		s, err := service.NewPaymentService(1)
+		require.NoError(t, err)
+		s.SetHealthy(false)
+
+		s.Reset()
+
+		for i := range 10 {
+			_, err := s.ProcessPayment(context.Background(), service.PaymentRequest{ID: fmt.Sprintf("payment-%d", i), Amount: 10})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("health can change while payments are processed", func(t *testing.T) {
+		s, err := service.NewPaymentService(0.5)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					_, _ = s.ProcessPayment(context.Background(), service.PaymentRequest{ID: fmt.Sprintf("payment-%d-%d", i, j), Amount: 10})
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					s.SetHealthy(j%2 == 0)
+					if j%10 == 0 {
+						s.Reset()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		s.Reset()
+		_, err = s.ProcessPayment(context.Background(), service.PaymentRequest{ID: "payment-final", Amount: 10})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/hedge/internal/hedge"
+)
+
+func main() {
+	log.Println("🏁 Request Hedging Demonstration")
+	log.Println("================================")
+
+	log.Println()
+
+	// Hedge after 50ms, launching at most 2 additional attempts
+	hedger, err := hedge.NewHedger[string, string](50*time.Millisecond, 2)
+	if err != nil {
+		log.Fatalf("Failed to create hedger: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		start := time.Now()
+		res, err := hedger.Do(ctx, "user-123", slowLookup)
+		if err != nil {
+			log.Printf("❌ Request %d failed: %v", i, err)
+			continue
+		}
+		log.Printf("✅ Request %d returned %q after %v", i, res, time.Since(start).Round(time.Millisecond))
+	}
+
+	log.Println()
+	log.Println("🎉 Request hedging demonstration complete!")
+}
+
+var attempts atomic.Int32
+
+// slowLookup simulates a dependency with a long latency tail
+func slowLookup(ctx context.Context, id string) (string, error) {
+	name := fmt.Sprintf("attempt %d", attempts.Add(1))
+
+	latency := 20 * time.Millisecond
+	if rand.Intn(2) == 0 {
+		latency = 500 * time.Millisecond // Tail latency
+	}
+
+	select {
+	case <-time.After(latency):
+		return fmt.Sprintf("%s from %s", id, name), nil
+	case <-ctx.Done():
+		log.Printf("   🛑 %s cancelled after losing the race", name)
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,129 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// options holds the configuration shared by every hedger regardless of its request and response types
+type options struct {
+	clock clockwork.Clock
+}
+
+// Option is a functional option for configuring the hedger
+type Option func(*options) error
+
+// WithClock sets a custom clock for measuring the hedge delay
+func WithClock(clock clockwork.Clock) Option {
+	return func(o *options) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		o.clock = clock
+		return nil
+	}
+}
+
+// Hedger reduces tail latency by launching additional attempts of a slow call
+// and returning whichever succeeds first
+type Hedger[Req, Res any] struct {
+	options
+	hedgeDelay time.Duration // How long to wait for an attempt before launching another
+	maxHedges  int           // Maximum number of attempts launched in addition to the primary
+}
+
+// result is the outcome of a single attempt
+type result[Res any] struct {
+	res Res
+	err error
+}
+
+// NewHedger creates a new hedger launching up to maxHedges additional attempts, one every hedgeDelay
+func NewHedger[Req, Res any](hedgeDelay time.Duration, maxHedges int, opts ...Option) (*Hedger[Req, Res], error) {
+	switch {
+	case hedgeDelay <= 0:
+		return nil, errors.New("hedge delay must be greater than 0")
+	case maxHedges < 0:
+		return nil, errors.New("max hedges cannot be negative")
+	}
+
+	h := &Hedger[Req, Res]{
+		options: options{
+			clock: clockwork.NewRealClock(), // Default to real clock
+		},
+		hedgeDelay: hedgeDelay,
+		maxHedges:  maxHedges,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(&h.options); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// Do executes fn, launching another attempt each time hedgeDelay passes without a
+// result until maxHedges is reached. The first successful result is returned and
+// the context passed to every other attempt is cancelled. A failed attempt is
+// ignored while others are still in flight; if every launched attempt fails, the
+// last error is returned without launching any further hedges.
+func (h *Hedger[Req, Res]) Do(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, error) {
+	var zero Res
+
+	// Cancelling on return stops every attempt still in flight
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so losing attempts never block once Do has returned
+	results := make(chan result[Res], h.maxHedges+1)
+	launch := func() {
+		go func() {
+			res, err := fn(attemptCtx, req)
+			results <- result[Res]{res: res, err: err}
+		}()
+	}
+
+	launch()
+	launched, inFlight := 1, 1
+
+	var (
+		timer clockwork.Timer
+		hedge <-chan time.Time
+	)
+	if h.maxHedges > 0 {
+		timer = h.clock.NewTimer(h.hedgeDelay)
+		defer timer.Stop()
+		hedge = timer.Chan()
+	}
+
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				return r.res, nil
+			}
+			if inFlight == 0 {
+				return zero, r.err
+			}
+		case <-hedge:
+			launch()
+			launched++
+			inFlight++
+
+			if launched > h.maxHedges {
+				hedge = nil // No more hedges to launch
+				continue
+			}
+			timer.Reset(h.hedgeDelay)
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/hedge/internal/hedge"
+)
+
+const hedgeDelay = 100 * time.Millisecond
+
+type response struct {
+	res string
+	err error
+}
+
+func TestNewHedger(t *testing.T) {
+	t.Run("invalid hedge delay", func(t *testing.T) {
+		h, err := hedge.NewHedger[string, string](0, 1)
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "hedge delay must be greater than 0")
+	})
+
+	t.Run("negative max hedges", func(t *testing.T) {
+		h, err := hedge.NewHedger[string, string](hedgeDelay, -1)
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "max hedges cannot be negative")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 1, hedge.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, h)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 0)
+		require.NoError(t, err)
+		require.NotNil(t, h)
+	})
+}
+
+func TestDo(t *testing.T) {
+	t.Run("fast primary is not hedged", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 2, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		var calls atomic.Int32
+		res, err := h.Do(context.Background(), "req", func(_ context.Context, req string) (string, error) {
+			calls.Add(1)
+			return "primary:" + req, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "primary:req", res)
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("hedge wins and the slow call is cancelled", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 1, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		var calls atomic.Int32
+		primaryErr := make(chan error, 1)
+		fn := func(ctx context.Context, _ string) (string, error) {
+			if calls.Add(1) == 1 {
+				<-ctx.Done()
+				primaryErr <- ctx.Err()
+				return "", ctx.Err()
+			}
+			return "hedge", nil
+		}
+
+		respChan := make(chan response)
+		go func() {
+			res, err := h.Do(ctx, "req", fn)
+			respChan <- response{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(hedgeDelay)
+
+		resp := <-respChan
+		require.NoError(t, resp.err)
+		require.Equal(t, "hedge", resp.res)
+		require.ErrorIs(t, <-primaryErr, context.Canceled)
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("only the first success is returned", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 1, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		release := make(chan struct{})
+		primaryDone := make(chan struct{})
+		var calls atomic.Int32
+		fn := func(ctx context.Context, _ string) (string, error) {
+			if calls.Add(1) == 1 {
+				// Ignores cancellation and succeeds after the hedge has won
+				defer close(primaryDone)
+				<-release
+				return "primary", nil
+			}
+			return "hedge", nil
+		}
+
+		respChan := make(chan response)
+		go func() {
+			res, err := h.Do(ctx, "req", fn)
+			respChan <- response{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(hedgeDelay)
+
+		resp := <-respChan
+		require.NoError(t, resp.err)
+		require.Equal(t, "hedge", resp.res)
+
+		// The late primary result is discarded without blocking
+		close(release)
+		<-primaryDone
+	})
+
+	t.Run("number of hedges is capped", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 2, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		started := make(chan struct{}, 10)
+		fn := func(ctx context.Context, _ string) (string, error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+
+		respChan := make(chan response)
+		go func() {
+			res, err := h.Do(ctx, "req", fn)
+			respChan <- response{res, err}
+		}()
+
+		// Primary plus two hedges
+		for i := 0; i < 3; i++ {
+			<-started
+			if i < 2 {
+				fakeClock.BlockUntilContext(ctx, 1)
+				fakeClock.Advance(hedgeDelay)
+			}
+		}
+
+		fakeClock.Advance(10 * hedgeDelay)
+		require.Never(t, func() bool { return len(started) > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+		cancel()
+		resp := <-respChan
+		require.ErrorIs(t, resp.err, context.Canceled)
+	})
+
+	t.Run("failure is ignored while another attempt is in flight", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 1, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		releasePrimary := make(chan struct{})
+		releaseHedge := make(chan struct{})
+		var calls atomic.Int32
+		fn := func(context.Context, string) (string, error) {
+			if calls.Add(1) == 1 {
+				<-releasePrimary
+				return "", errors.New("primary failed")
+			}
+			<-releaseHedge
+			return "hedge", nil
+		}
+
+		respChan := make(chan response)
+		go func() {
+			res, err := h.Do(ctx, "req", fn)
+			respChan <- response{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(hedgeDelay)
+		require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+		close(releasePrimary)
+		close(releaseHedge)
+
+		resp := <-respChan
+		require.NoError(t, resp.err)
+		require.Equal(t, "hedge", resp.res)
+	})
+
+	t.Run("all attempts fail", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 0, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		expectedErr := errors.New("service unavailable")
+		res, err := h.Do(context.Background(), "req", func(context.Context, string) (string, error) {
+			return "", expectedErr
+		})
+		require.ErrorIs(t, err, expectedErr)
+		require.Empty(t, res)
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		h, err := hedge.NewHedger[string, string](hedgeDelay, 1, hedge.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		fnErr := make(chan error, 1)
+		fn := func(ctx context.Context, _ string) (string, error) {
+			<-ctx.Done()
+			fnErr <- ctx.Err()
+			return "", ctx.Err()
+		}
+
+		respChan := make(chan response)
+		go func() {
+			res, err := h.Do(ctx, "req", fn)
+			respChan <- response{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		resp := <-respChan
+		require.ErrorIs(t, resp.err, context.Canceled)
+		require.ErrorIs(t, <-fnErr, context.Canceled)
+	})
+}
@@ -0,0 +1,34 @@
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// Harness wraps a fake clock for driving a pattern's timers deterministically without real sleeps.
+// Pass Clock to the pattern's WithClock option. It doesn't build or wire up a stack of patterns.
+type Harness struct {
+	Clock *clockwork.FakeClock
+}
+
+// NewHarness creates a harness with a fake clock starting at the current time
+func NewHarness() *Harness {
+	return &Harness{Clock: clockwork.NewFakeClock()}
+}
+
+// Advance moves the clock forward by d, firing every timer that becomes due
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// AdvanceWhenBlocked waits until waiters goroutines are blocked on the clock, e.g. a retry backoff,
+// then moves it forward by d. It returns ctx's error if ctx is done first.
+func (h *Harness) AdvanceWhenBlocked(ctx context.Context, waiters int, d time.Duration) error {
+	if err := h.Clock.BlockUntilContext(ctx, waiters); err != nil {
+		return err
+	}
+	h.Clock.Advance(d)
+	return nil
+}
@@ -0,0 +1,82 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrScripted is the error returned by scripted calls that fail without a specific error
+var ErrScripted = errors.New("scripted failure")
+
+// Script is a mock service whose sequence of outcomes is scripted up front.
+// Once the sequence is used up, every further call repeats its last outcome.
+// It is safe for concurrent use.
+type Script struct {
+	lock     sync.Mutex
+	outcomes []error // nil is a success
+	calls    int
+}
+
+// Sequence scripts one call per outcome, where a nil error is a success
+func Sequence(outcomes ...error) *Script {
+	if len(outcomes) == 0 {
+		outcomes = []error{nil}
+	}
+	return &Script{outcomes: outcomes}
+}
+
+// FailNTimesThenSucceed scripts n failures with ErrScripted followed by successes
+func FailNTimesThenSucceed(n int) *Script {
+	outcomes := make([]error, n+1)
+	for i := range n {
+		outcomes[i] = ErrScripted
+	}
+	return Sequence(outcomes...)
+}
+
+// AlwaysFail scripts every call to fail with ErrScripted
+func AlwaysFail() *Script {
+	return Sequence(ErrScripted)
+}
+
+// AlwaysSucceed scripts every call to succeed
+func AlwaysSucceed() *Script {
+	return Sequence(nil)
+}
+
+// Next records a call and returns its scripted outcome
+func (s *Script) Next() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	i := min(s.calls, len(s.outcomes)-1)
+	s.calls++
+	return s.outcomes[i]
+}
+
+// Calls returns how many calls have been made
+func (s *Script) Calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls
+}
+
+// Func adapts a script to the call signature the patterns wrap, returning res on every success.
+// A call whose context is already done fails with the context's error without consuming an outcome.
+func Func[Req, Res any](s *Script, res Res) func(context.Context, Req) (Res, error) {
+	return func(ctx context.Context, _ Req) (Res, error) {
+		var zero Res
+		// Select rather than calling ctx.Err, which blocks until done for contexts created on a fake clock
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		default:
+		}
+
+		if err := s.Next(); err != nil {
+			return zero, err
+		}
+		return res, nil
+	}
+}
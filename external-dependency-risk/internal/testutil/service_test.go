@@ -0,0 +1,60 @@
+package testutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/internal/testutil"
+)
+
+func TestScript(t *testing.T) {
+	t.Run("fail n times then succeed", func(t *testing.T) {
+		s := testutil.FailNTimesThenSucceed(2)
+		require.ErrorIs(t, s.Next(), testutil.ErrScripted)
+		require.ErrorIs(t, s.Next(), testutil.ErrScripted)
+		require.NoError(t, s.Next())
+		require.NoError(t, s.Next())
+		require.Equal(t, 4, s.Calls())
+	})
+
+	t.Run("sequence repeats its last outcome", func(t *testing.T) {
+		errTimeout := errors.New("timeout")
+		s := testutil.Sequence(nil, errTimeout)
+		require.NoError(t, s.Next())
+		require.ErrorIs(t, s.Next(), errTimeout)
+		require.ErrorIs(t, s.Next(), errTimeout)
+	})
+
+	t.Run("always fail and always succeed", func(t *testing.T) {
+		require.ErrorIs(t, testutil.AlwaysFail().Next(), testutil.ErrScripted)
+		require.NoError(t, testutil.AlwaysSucceed().Next())
+		require.NoError(t, testutil.Sequence().Next())
+	})
+
+	t.Run("func returns the result on success", func(t *testing.T) {
+		fn := testutil.Func[string](testutil.FailNTimesThenSucceed(1), 42)
+		ctx := context.Background()
+
+		_, err := fn(ctx, "req")
+		require.ErrorIs(t, err, testutil.ErrScripted)
+
+		res, err := fn(ctx, "req")
+		require.NoError(t, err)
+		require.Equal(t, 42, res)
+	})
+
+	t.Run("func doesn't consume an outcome once the context is done", func(t *testing.T) {
+		s := testutil.AlwaysSucceed()
+		fn := testutil.Func[string](s, 42)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := fn(ctx, "req")
+		require.ErrorIs(t, err, context.Canceled)
+		require.Zero(t, s.Calls())
+	})
+}
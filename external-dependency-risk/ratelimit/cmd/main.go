@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/ratelimit/internal/ratelimit"
+)
+
+func main() {
+	log.Println("🪣 Rate Limiter Demonstration")
+	log.Println("=============================")
+
+	log.Println()
+
+	// Demonstrate burst capacity and rejection
+	demonstrateBurst()
+
+	log.Println()
+
+	// Demonstrate waiting for tokens at a steady rate
+	demonstrateSteadyRate()
+
+	log.Println()
+	log.Println("🎉 Rate limiter demonstration complete!")
+}
+
+func demonstrateBurst() {
+	log.Println("💥 Burst Demo")
+	log.Println("-------------")
+
+	// Allow bursts of 5 calls, refilling at 2 calls per second
+	limiter, err := ratelimit.New(2, 5)
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	for i := 1; i <= 8; i++ {
+		if limiter.Allow() {
+			log.Printf("✅ Request %d allowed", i)
+		} else {
+			log.Printf("🚫 Request %d rejected - rate limit exceeded", i)
+		}
+	}
+}
+
+func demonstrateSteadyRate() {
+	log.Println("⏱️  Steady Rate Demo")
+	log.Println("-------------------")
+
+	// Allow a single call at a time, refilling at 4 calls per second
+	limiter, err := ratelimit.New(4, 1)
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+
+	for i := 1; i <= 5; i++ {
+		res, err := ratelimit.Do(ctx, limiter, func(context.Context) (int, error) {
+			return i * i, nil
+		})
+		if err != nil {
+			log.Printf("❌ Request %d failed: %v", i, err)
+			continue
+		}
+		log.Printf("✅ Request %d returned %d after %v", i, res, time.Since(start).Round(10*time.Millisecond))
+	}
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// limiter is a thread-safe token bucket allowing bursts of up to burst calls
+// and refilling at rate tokens per second
+type limiter struct {
+	lock   sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  int     // Maximum number of tokens held
+	tokens float64
+	last   time.Time // When tokens was last refilled
+	clock  clockwork.Clock
+}
+
+// Option is a functional option for configuring the rate limiter
+type Option func(*limiter) error
+
+// WithClock sets a custom clock for the rate limiter
+func WithClock(clock clockwork.Clock) Option {
+	return func(l *limiter) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		l.clock = clock
+		return nil
+	}
+}
+
+// New creates a new rate limiter that starts full, allowing burst calls immediately
+func New(rate float64, burst int, opts ...Option) (*limiter, error) {
+	switch {
+	case rate <= 0:
+		return nil, errors.New("rate must be greater than 0")
+	case burst <= 0:
+		return nil, errors.New("burst must be greater than 0")
+	}
+
+	l := &limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		clock:  clockwork.NewRealClock(), // Default to real clock
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(l); err != nil {
+			return nil, err
+		}
+	}
+
+	l.last = l.clock.Now()
+
+	return l, nil
+}
+
+// Allow takes a token if one is available, reporting whether it did
+func (l *limiter) Allow() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait blocks until a token is available and takes it, or returns the context's error if it is cancelled first
+func (l *limiter) Wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		l.lock.Lock()
+		l.refill()
+		if l.tokens >= 1 {
+			l.tokens--
+			l.lock.Unlock()
+			return nil
+		}
+		// Time until the next whole token is available
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.lock.Unlock()
+
+		select {
+		case <-l.clock.After(wait):
+			// Another caller may take the token first, so check again
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Do waits for a token and then executes fn
+func (l *limiter) Do(ctx context.Context, fn func(context.Context) error) error {
+	_, err := Do(ctx, l, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Do waits for a token from the limiter and then executes fn, returning its result
+func Do[T any](ctx context.Context, l *limiter, fn func(context.Context) (T, error)) (T, error) {
+	if err := l.Wait(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return fn(ctx)
+}
+
+// Tokens returns the number of tokens currently available
+func (l *limiter) Tokens() float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.refill()
+	return l.tokens
+}
+
+// refill adds the tokens accrued since the last refill, capped at burst.
+// It must be called with the lock held.
+func (l *limiter) refill() {
+	now := l.clock.Now()
+	elapsed := now.Sub(l.last)
+	l.last = now
+
+	l.tokens += elapsed.Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+}
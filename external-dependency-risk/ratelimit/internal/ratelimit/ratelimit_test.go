@@ -0,0 +1,183 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/ratelimit/internal/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("invalid rate", func(t *testing.T) {
+		l, err := ratelimit.New(0, 1)
+		require.Error(t, err)
+		require.Nil(t, l)
+		require.Contains(t, err.Error(), "rate must be greater than 0")
+	})
+
+	t.Run("invalid burst", func(t *testing.T) {
+		l, err := ratelimit.New(1, 0)
+		require.Error(t, err)
+		require.Nil(t, l)
+		require.Contains(t, err.Error(), "burst must be greater than 0")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		l, err := ratelimit.New(1, 1, ratelimit.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, l)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		l, err := ratelimit.New(1, 1)
+		require.NoError(t, err)
+		require.NotNil(t, l)
+	})
+}
+
+func TestAllow(t *testing.T) {
+	t.Run("burst capacity", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(2, 3, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.True(t, l.Allow(), "call %d", i)
+		}
+		require.False(t, l.Allow())
+	})
+
+	t.Run("steady state refill", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(2, 3, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.True(t, l.Allow())
+		}
+
+		// 2 tokens per second means one every 500ms
+		fakeClock.Advance(250 * time.Millisecond)
+		require.False(t, l.Allow())
+
+		fakeClock.Advance(250 * time.Millisecond)
+		require.True(t, l.Allow())
+		require.False(t, l.Allow())
+	})
+
+	t.Run("refill is capped at burst", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(2, 3, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		require.True(t, l.Allow())
+		fakeClock.Advance(time.Minute)
+		require.Equal(t, 3.0, l.Tokens())
+
+		for i := 0; i < 3; i++ {
+			require.True(t, l.Allow())
+		}
+		require.False(t, l.Allow())
+	})
+}
+
+func TestWait(t *testing.T) {
+	t.Run("returns immediately when a token is available", func(t *testing.T) {
+		l, err := ratelimit.New(1, 1, ratelimit.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+
+		require.NoError(t, l.Wait(context.Background()))
+	})
+
+	t.Run("blocks until a token is refilled", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(2, 1, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+		require.True(t, l.Allow())
+
+		ctx := context.Background()
+		errChan := make(chan error)
+		go func() {
+			errChan <- l.Wait(ctx)
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(500 * time.Millisecond)
+
+		require.NoError(t, <-errChan)
+		require.False(t, l.Allow())
+	})
+
+	t.Run("context cancelled while waiting", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(1, 1, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+		require.True(t, l.Allow())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			errChan <- l.Wait(ctx)
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		require.ErrorIs(t, <-errChan, context.Canceled)
+
+		// The cancelled waiter didn't take the refilled token
+		fakeClock.Advance(time.Second)
+		require.True(t, l.Allow())
+	})
+}
+
+func TestDo(t *testing.T) {
+	t.Run("executes fn once a token is available", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		l, err := ratelimit.New(1, 1, ratelimit.WithClock(fakeClock))
+		require.NoError(t, err)
+		require.True(t, l.Allow())
+
+		ctx := context.Background()
+		type result struct {
+			res string
+			err error
+		}
+		resultChan := make(chan result)
+		go func() {
+			res, err := ratelimit.Do(ctx, l, func(context.Context) (string, error) {
+				return "ok", nil
+			})
+			resultChan <- result{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(time.Second)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, "ok", res.res)
+	})
+
+	t.Run("does not execute fn when the context is cancelled", func(t *testing.T) {
+		l, err := ratelimit.New(1, 1, ratelimit.WithClock(clockwork.NewFakeClock()))
+		require.NoError(t, err)
+		require.True(t, l.Allow())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err = l.Do(ctx, func(context.Context) error {
+			called = true
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, called)
+	})
+}
@@ -55,3 +55,42 @@ func (mr *MockOrderProcessorMockRecorder) ProcessOrder(ctx, request any) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockOrderProcessor)(nil).ProcessOrder), ctx, request)
 }
+
+// MockItemProcessor is a mock of ItemProcessor interface.
+type MockItemProcessor struct {
+	ctrl     *gomock.Controller
+	recorder *MockItemProcessorMockRecorder
+	isgomock struct{}
+}
+
+// MockItemProcessorMockRecorder is the mock recorder for MockItemProcessor.
+type MockItemProcessorMockRecorder struct {
+	mock *MockItemProcessor
+}
+
+// NewMockItemProcessor creates a new mock instance.
+func NewMockItemProcessor(ctrl *gomock.Controller) *MockItemProcessor {
+	mock := &MockItemProcessor{ctrl: ctrl}
+	mock.recorder = &MockItemProcessorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockItemProcessor) EXPECT() *MockItemProcessorMockRecorder {
+	return m.recorder
+}
+
+// ProcessItem mocks base method.
+func (m *MockItemProcessor) ProcessItem(ctx context.Context, item service.Item) (service.ItemResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessItem", ctx, item)
+	ret0, _ := ret[0].(service.ItemResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessItem indicates an expected call of ProcessItem.
+func (mr *MockItemProcessorMockRecorder) ProcessItem(ctx, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessItem", reflect.TypeOf((*MockItemProcessor)(nil).ProcessItem), ctx, item)
+}
@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"math"
+	"time"
+)
+
+// BackoffStrategy determines how long to wait before the next retry attempt
+type BackoffStrategy interface {
+	// Delay returns the wait after the given zero-based attempt has failed
+	Delay(attempt int) time.Duration
+}
+
+// ExponentialBackoff multiplies the delay by Multiplier after every attempt, capped at Max
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Delay calculates the exponential backoff delay
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	// Clamp before converting, large attempts overflow to +Inf and converting that to a Duration is undefined
+	if math.IsInf(delay, 0) || math.IsNaN(delay) || delay > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(delay)
+}
+
+// ConstantBackoff waits the same Interval between every attempt
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+// Delay returns the constant interval
+func (b ConstantBackoff) Delay(int) time.Duration {
+	return b.Interval
+}
+
+// LinearBackoff adds Increment to the delay after every attempt, capped at Max if set
+type LinearBackoff struct {
+	Initial   time.Duration
+	Increment time.Duration
+	Max       time.Duration
+}
+
+// Delay calculates the linear backoff delay
+func (b LinearBackoff) Delay(attempt int) time.Duration {
+	delay := b.Initial + time.Duration(attempt)*b.Increment
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
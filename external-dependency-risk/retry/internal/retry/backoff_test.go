@@ -0,0 +1,69 @@
+package retry_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/retry"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strategy retry.BackoffStrategy
+		expected []time.Duration
+	}{
+		{
+			name:     "exponential",
+			strategy: retry.ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2},
+			expected: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+		{
+			name:     "constant",
+			strategy: retry.ConstantBackoff{Interval: 250 * time.Millisecond},
+			expected: []time.Duration{250 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			name:     "linear",
+			strategy: retry.LinearBackoff{Initial: 100 * time.Millisecond, Increment: 150 * time.Millisecond, Max: 500 * time.Millisecond},
+			expected: []time.Duration{100 * time.Millisecond, 250 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:     "linear without cap",
+			strategy: retry.LinearBackoff{Initial: time.Second, Increment: time.Second},
+			expected: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for attempt, expected := range tc.expected {
+				require.Equal(t, expected, tc.strategy.Delay(attempt), "attempt %d", attempt)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffOverflow(t *testing.T) {
+	backoff := retry.ExponentialBackoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second, Multiplier: 2}
+
+	for _, tc := range []struct {
+		name       string
+		attempt    int
+		multiplier float64
+	}{
+		{name: "attempt 64", attempt: 64, multiplier: 2},
+		{name: "attempt 99", attempt: 99, multiplier: 2},
+		{name: "attempt 1024 overflows to infinity", attempt: 1024, multiplier: 2},
+		{name: "large multiplier", attempt: 99, multiplier: 1000},
+		{name: "max int attempt", attempt: math.MaxInt, multiplier: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			backoff.Multiplier = tc.multiplier
+			delay := backoff.Delay(tc.attempt)
+			require.Equal(t, backoff.Max, delay)
+			require.Positive(t, delay)
+		})
+	}
+}
@@ -3,10 +3,18 @@ package retry
 import (
 	"context"
 	"errors"
-	"math"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jonboulle/clockwork"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/service"
 )
@@ -18,23 +26,61 @@ type OrderProcessor interface {
 	ProcessOrder(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error)
 }
 
-// retryClient wraps an order service with retry functionality
-type retryClient struct {
-	service         OrderProcessor
-	maxAttempts     int
-	timeout         time.Duration
-	initialInterval time.Duration
-	maxInterval     time.Duration
-	multiplier      float64
-	clock           clockwork.Clock
+// ItemProcessor defines the interface for processing a single order item, e.g. with an inventory service
+type ItemProcessor interface {
+	ProcessItem(ctx context.Context, item service.Item) (service.ItemResponse, error)
+}
+
+// Breaker guards the order service as a whole, failing calls fast while it is open and recording a single result
+// for every call it lets through. It is satisfied by a circuitbreaker.Breaker[service.OrderRequest, service.OrderResponse].
+type Breaker interface {
+	Do(ctx context.Context, req service.OrderRequest, fn func(context.Context, service.OrderRequest) (service.OrderResponse, error)) (service.OrderResponse, error)
+}
+
+// JitterType determines how randomness is applied to backoff delays
+type JitterType int
+
+const (
+	// NoJitter uses the computed backoff delay as is
+	NoJitter JitterType = iota
+	// FullJitter waits a random duration between 0 and the computed delay
+	FullJitter
+	// EqualJitter waits half the computed delay plus a random duration up to the other half
+	EqualJitter
+)
+
+// retrier holds the retry configuration shared by every wrapper
+type retrier struct {
+	maxAttempts int
+	timeout     time.Duration
+	budget      bool // Whether attempts share the time left before the caller's deadline rather than each getting timeout
+	backoff     BackoffStrategy
+	jitter      JitterType
+	random      func() float64                    // Returns a random number in [0.0, 1.0)
+	maxInterval time.Duration                     // Caps server-suggested delays
+	initial     time.Duration                     // Initial interval of the default exponential backoff, kept for Overrides
+	multiplier  float64                           // Multiplier of the default exponential backoff, kept for Overrides
+	retryable   func(error) bool                  // Decides whether an error should be retried, nil retries every error
+	retryAfter  func(error) (time.Duration, bool) // Extracts a server-suggested delay from an error
+	onRetry     func(attempt int, err error, nextDelay time.Duration)
+	clock       clockwork.Clock
+	logger      Logger
+	fields      func(ctx context.Context) []any // Extracts key-value pairs from an attempt's context to add to its log lines
+	tracer      trace.Tracer                    // Starts a span around every attempt, nil disables tracing
+
+	idempotencyKeys bool                                    // Whether the order retry client gives requests without a key their ID as one
+	committed       func(service.OrderResponse, error) bool // Reports whether a failed order attempt was committed anyway, nil resubmits every failure
+	itemProcessor   ItemProcessor                           // Processes each item for ProcessOrderItems, nil if not configured
+	itemParallelism int                                     // Items ProcessOrderItems retries at once, zero retries every item at once
+	breaker         Breaker                                 // Wraps every ProcessOrder call with all its attempts, nil if not configured
 }
 
 // Option is a functional option for configuring the retry client
-type Option func(*retryClient) error
+type Option func(*retrier) error
 
 // WithClock sets a custom clock for the retry client
 func WithClock(clock clockwork.Clock) Option {
-	return func(r *retryClient) error {
+	return func(r *retrier) error {
 		if clock == nil {
 			return errors.New("clock is nil")
 		}
@@ -43,71 +89,587 @@ func WithClock(clock clockwork.Clock) Option {
 	}
 }
 
-// New creates a new retry client
-func New(service OrderProcessor, maxAttempts int, timeout, initialInterval, maxInterval time.Duration, multiplier float64, opts ...Option) (*retryClient, error) {
+// WithLogger sets the logger the retry client reports failed attempts to
+func WithLogger(logger Logger) Option {
+	return func(r *retrier) error {
+		if logger == nil {
+			return errors.New("logger is nil")
+		}
+		r.logger = logger
+		return nil
+	}
+}
+
+// WithContextFields sets a function extracting key-value pairs, such as a request or trace ID, from the context.
+// It's called once per attempt with that attempt's context, and the pairs are appended to the attempt's log lines.
+func WithContextFields(fn func(ctx context.Context) []any) Option {
+	return func(r *retrier) error {
+		if fn == nil {
+			return errors.New("context fields func is nil")
+		}
+		r.fields = fn
+		return nil
+	}
+}
+
+// WithTracer sets the tracer used to start a span around every attempt
+func WithTracer(tracer trace.Tracer) Option {
+	return func(r *retrier) error {
+		if tracer == nil {
+			return errors.New("tracer is nil")
+		}
+		r.tracer = tracer
+		return nil
+	}
+}
+
+// WithIdempotencyKeys makes the order retry client send the same idempotency key on every attempt,
+// so an order whose response was lost to a timeout isn't processed twice. Requests without a key use
+// their ID. It has no effect on the generic Retrier.
+func WithIdempotencyKeys() Option {
+	return func(r *retrier) error {
+		r.idempotencyKeys = true
+		return nil
+	}
+}
+
+// WithCommittedResponse makes the order retry client remember, for the rest of a ProcessOrder call, the response
+// of a failed attempt that committed reports was processed anyway, e.g. one carrying an order ID alongside a
+// connection reset. Responses are kept by request ID, and later attempts return the remembered response instead
+// of resubmitting the order. Unlike idempotency keys, this needs no support from the service but only lasts for
+// a single call. It has no effect on the generic Retrier.
+func WithCommittedResponse(committed func(service.OrderResponse, error) bool) Option {
+	return func(r *retrier) error {
+		if committed == nil {
+			return errors.New("committed func is nil")
+		}
+		r.committed = committed
+		return nil
+	}
+}
+
+// WithBreaker runs every ProcessOrder call through breaker with all of its attempts, rather than each attempt.
+// While the breaker is open calls fail fast without making any attempts, and a call that exhausts its retries
+// counts as a single failure, or a single success if an attempt succeeds. It has no effect on the generic Retrier.
+func WithBreaker(breaker Breaker) Option {
+	return func(r *retrier) error {
+		if breaker == nil {
+			return errors.New("breaker is nil")
+		}
+		r.breaker = breaker
+		return nil
+	}
+}
+
+// WithItemProcessor sets the processor the order retry client uses for ProcessOrderItems.
+// It has no effect on the generic Retrier.
+func WithItemProcessor(processor ItemProcessor) Option {
+	return func(r *retrier) error {
+		if processor == nil {
+			return errors.New("item processor is nil")
+		}
+		r.itemProcessor = processor
+		return nil
+	}
+}
+
+// WithItemParallelism caps how many items ProcessOrderItems retries at once, rather than all of them
+func WithItemParallelism(n int) Option {
+	return func(r *retrier) error {
+		if n <= 0 {
+			return errors.New("item parallelism must be greater than 0")
+		}
+		r.itemParallelism = n
+		return nil
+	}
+}
+
+// WithJitter randomizes backoff delays to avoid synchronized retries across clients
+func WithJitter(kind JitterType) Option {
+	return func(r *retrier) error {
+		switch kind {
+		case NoJitter, FullJitter, EqualJitter:
+			r.jitter = kind
+			return nil
+		default:
+			return errors.New("unknown jitter type")
+		}
+	}
+}
+
+// WithRand sets a custom random source used for jitter
+func WithRand(rnd *rand.Rand) Option {
+	return func(r *retrier) error {
+		if rnd == nil {
+			return errors.New("rand is nil")
+		}
+		// rand.Rand isn't safe for concurrent use
+		var lock sync.Mutex
+		r.random = func() float64 {
+			lock.Lock()
+			defer lock.Unlock()
+			return rnd.Float64()
+		}
+		return nil
+	}
+}
+
+// WithBackoff replaces the default exponential backoff with a custom strategy
+func WithBackoff(backoff BackoffStrategy) Option {
+	return func(r *retrier) error {
+		if backoff == nil {
+			return errors.New("backoff is nil")
+		}
+		r.backoff = backoff
+		return nil
+	}
+}
+
+// WithRetryAfter sets a function extracting a server-suggested delay, such as an HTTP Retry-After header,
+// from an error. When it reports a delay, the next wait uses it instead of the backoff, capped at maxInterval.
+func WithRetryAfter(fn func(error) (time.Duration, bool)) Option {
+	return func(r *retrier) error {
+		if fn == nil {
+			return errors.New("retry after func is nil")
+		}
+		r.retryAfter = fn
+		return nil
+	}
+}
+
+// WithRetryable sets a predicate deciding which errors should be retried.
+// When it returns false, ProcessOrder stops immediately and returns the error.
+func WithRetryable(fn func(error) bool) Option {
+	return func(r *retrier) error {
+		if fn == nil {
+			return errors.New("retryable predicate is nil")
+		}
+		r.retryable = fn
+		return nil
+	}
+}
+
+// WithOnRetry sets a callback invoked after each failed attempt that will be retried,
+// before waiting for the backoff delay. It is not invoked after the final attempt.
+func WithOnRetry(fn func(attempt int, err error, nextDelay time.Duration)) Option {
+	return func(r *retrier) error {
+		if fn == nil {
+			return errors.New("onRetry is nil")
+		}
+		r.onRetry = fn
+		return nil
+	}
+}
+
+// WithDeadlineBudget splits the time left before the caller's deadline fairly between the remaining attempts,
+// so each attempt gets min(timeout, remaining/attemptsLeft) and an early attempt can't use up the whole budget.
+// The backoff still to be waited between attempts is taken off the remaining time before it is shared out.
+// The final attempt gets all the time remaining, up to timeout. It has no effect if the caller's context has
+// no deadline.
+func WithDeadlineBudget() Option {
+	return func(r *retrier) error {
+		r.budget = true
+		return nil
+	}
+}
+
+// newRetrier validates the configuration and creates the underlying retrier
+func newRetrier(maxAttempts int, timeout, initialInterval, maxInterval time.Duration, multiplier float64, opts ...Option) (*retrier, error) {
+	if err := validate(maxAttempts, timeout, initialInterval, maxInterval, multiplier); err != nil {
+		return nil, err
+	}
+
+	r := &retrier{
+		maxAttempts: maxAttempts,
+		timeout:     timeout,
+		initial:     initialInterval,
+		multiplier:  multiplier,
+		maxInterval: maxInterval,
+		backoff: ExponentialBackoff{
+			Initial:    initialInterval,
+			Max:        maxInterval,
+			Multiplier: multiplier,
+		},
+		random: rand.Float64,
+		clock:  clockwork.NewRealClock(),
+		logger: noopLogger{},
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// validate checks the retry parameters given to New, or the result of applying Overrides to them
+func validate(maxAttempts int, timeout, initialInterval, maxInterval time.Duration, multiplier float64) error {
 	switch {
-	case service == nil:
-		return nil, errors.New("service is nil")
 	case maxAttempts <= 0:
-		return nil, errors.New("maxAttempts must be greater than 0")
+		return errors.New("maxAttempts must be greater than 0")
 	case timeout <= 0:
-		return nil, errors.New("timeout must be greater than 0")
+		return errors.New("timeout must be greater than 0")
 	case initialInterval <= 0:
-		return nil, errors.New("initialInterval must be greater than 0")
+		return errors.New("initialInterval must be greater than 0")
 	case maxInterval <= 0:
-		return nil, errors.New("maxInterval must be greater than 0")
+		return errors.New("maxInterval must be greater than 0")
 	case multiplier <= 0:
-		return nil, errors.New("multiplier must be greater than 0")
+		return errors.New("multiplier must be greater than 0")
 	}
+	return nil
+}
+
+// Overrides replaces parts of the retry configuration for a single call. Zero fields keep the client's value.
+type Overrides struct {
+	MaxAttempts     int
+	Timeout         time.Duration // Timeout per attempt
+	InitialInterval time.Duration
+	MaxInterval     time.Duration // Also caps server-suggested delays
+	Multiplier      float64
+}
 
-	r := &retryClient{
-		service:         service,
-		maxAttempts:     maxAttempts,
-		timeout:         timeout,
-		initialInterval: initialInterval,
-		maxInterval:     maxInterval,
-		multiplier:      multiplier,
-		clock:           clockwork.NewRealClock(),
+// apply returns a copy of the retrier with the overrides applied, validated like New's parameters.
+// Overriding any backoff parameter replaces a strategy set with WithBackoff by exponential backoff.
+func (o Overrides) apply(r *retrier) (*retrier, error) {
+	c := *r
+	if o.MaxAttempts != 0 {
+		c.maxAttempts = o.MaxAttempts
+	}
+	if o.Timeout != 0 {
+		c.timeout = o.Timeout
+	}
+	if o.InitialInterval != 0 {
+		c.initial = o.InitialInterval
+	}
+	if o.MaxInterval != 0 {
+		c.maxInterval = o.MaxInterval
+	}
+	if o.Multiplier != 0 {
+		c.multiplier = o.Multiplier
 	}
 
-	// Apply options
-	for _, opt := range opts {
-		if err := opt(r); err != nil {
-			return nil, err
+	if err := validate(c.maxAttempts, c.timeout, c.initial, c.maxInterval, c.multiplier); err != nil {
+		return nil, fmt.Errorf("invalid overrides: %w", err)
+	}
+
+	if o.InitialInterval != 0 || o.MaxInterval != 0 || o.Multiplier != 0 {
+		c.backoff = ExponentialBackoff{
+			Initial:    c.initial,
+			Max:        c.maxInterval,
+			Multiplier: c.multiplier,
 		}
 	}
+	return &c, nil
+}
 
-	return r, nil
+// Retrier retries any operation taking a Req and returning a Res
+type Retrier[Req, Res any] struct {
+	*retrier
 }
 
-// ProcessOrder processes an order request with retry logic and exponential backoff
-func (r *retryClient) ProcessOrder(ctx context.Context, req service.OrderRequest) (service.OrderResponse, error) {
+// NewRetrier creates a new generic retrier
+func NewRetrier[Req, Res any](maxAttempts int, timeout, initialInterval, maxInterval time.Duration, multiplier float64, opts ...Option) (*Retrier[Req, Res], error) {
+	r, err := newRetrier(maxAttempts, timeout, initialInterval, maxInterval, multiplier, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Retrier[Req, Res]{retrier: r}, nil
+}
+
+// AttemptRecord describes a single attempt made by a retrier
+type AttemptRecord struct {
+	Attempt  int           // 1-based attempt number
+	Err      error         // Error the attempt failed with, nil if it succeeded
+	Duration time.Duration // How long the attempt took, measured on the retrier's clock, excluding backoff
+	Timeout  time.Duration // How long the attempt was allowed to take
+}
+
+// Do executes fn with the given request, retrying failed attempts with backoff
+func (r *Retrier[Req, Res]) Do(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, error) {
+	res, _, err := r.DoWithHistory(ctx, req, fn)
+	return res, err
+}
+
+// DoWithHistory executes fn like Do, also returning a record of every attempt made whether it succeeded or not
+func (r *Retrier[Req, Res]) DoWithHistory(ctx context.Context, req Req, fn func(context.Context, Req) (Res, error)) (Res, []AttemptRecord, error) {
+	var zero Res
+	history := make([]AttemptRecord, 0, r.maxAttempts)
+	var fields string // Log fields from the latest attempt's context
+
 	for i := 0; i < r.maxAttempts; i++ {
-		// Create timeout context for this attempt
-		ctx, cancel := context.WithTimeout(ctx, r.timeout)
+		// Don't call the service if the caller has given up
+		if err := ctx.Err(); err != nil {
+			return zero, history, err
+		}
+
+		attemptCtx, span := r.startSpan(ctx, i+1)
+		fields = r.logFields(attemptCtx)
+
+		// Each attempt gets its own timeout, derived from the caller's context rather than a previous attempt's
+		timeout := r.attemptTimeout(ctx, i)
+		attemptCtx, cancel := context.WithTimeout(attemptCtx, timeout)
 
 		// Try the operation
-		resp, err := r.service.ProcessOrder(ctx, req)
+		start := r.clock.Now()
+		resp, err := fn(attemptCtx, req)
+		history = append(history, AttemptRecord{Attempt: i + 1, Err: err, Duration: r.clock.Since(start), Timeout: timeout})
 		cancel()
+		endSpan(span, err)
 
 		if err == nil {
-			return resp, nil
+			return resp, history, nil
+		}
+
+		// The caller gave up during the attempt, so it failed because of the caller rather than the service
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return zero, history, ctxErr
+		}
+
+		if r.retryable != nil && !r.retryable(err) {
+			r.logger.Debugf("attempt %d/%d failed with a non-retryable error: %v%s", i+1, r.maxAttempts, err, fields)
+			return zero, history, fmt.Errorf("non-retryable error on attempt %d: %w", i+1, err)
 		}
 
 		// Don't wait after the last attempt
 		if i < r.maxAttempts-1 {
-			<-r.clock.After(r.backoffDelay(i))
+			delay := r.nextDelay(i, err)
+			r.logger.Warnf("attempt %d/%d failed, retrying in %v: %v%s", i+1, r.maxAttempts, delay, err, fields)
+			if r.onRetry != nil {
+				r.onRetry(i, err, delay)
+			}
+
+			select {
+			case <-r.clock.After(delay):
+			case <-ctx.Done():
+				return zero, history, ctx.Err()
+			}
 		}
 	}
 
-	return service.OrderResponse{}, ErrMaxAttemptsExceeded
+	r.logger.Errorf("all %d attempts failed%s", r.maxAttempts, fields)
+	return zero, history, ErrMaxAttemptsExceeded
+}
+
+// retryClient wraps an order service with retry functionality
+type retryClient struct {
+	*Retrier[service.OrderRequest, service.OrderResponse]
+	service OrderProcessor
+}
+
+// New creates a new retry client
+func New(svc OrderProcessor, maxAttempts int, timeout, initialInterval, maxInterval time.Duration, multiplier float64, opts ...Option) (*retryClient, error) {
+	if svc == nil {
+		return nil, errors.New("service is nil")
+	}
+
+	r, err := NewRetrier[service.OrderRequest, service.OrderResponse](maxAttempts, timeout, initialInterval, maxInterval, multiplier, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &retryClient{
+		Retrier: r,
+		service: svc,
+	}, nil
+}
+
+// ProcessOrder processes an order request with retry logic and exponential backoff
+func (r *retryClient) ProcessOrder(ctx context.Context, req service.OrderRequest) (service.OrderResponse, error) {
+	resp, _, err := r.ProcessOrderWithHistory(ctx, req)
+	return resp, err
+}
+
+// ProcessOrderWith processes an order request like ProcessOrder, with overrides replacing the client's retry
+// configuration for this call only, e.g. to give a high-priority order more attempts
+func (r *retryClient) ProcessOrderWith(ctx context.Context, req service.OrderRequest, overrides Overrides) (service.OrderResponse, error) {
+	retrier, err := overrides.apply(r.retrier)
+	if err != nil {
+		return service.OrderResponse{}, err
+	}
+
+	client := &retryClient{
+		Retrier: &Retrier[service.OrderRequest, service.OrderResponse]{retrier: retrier},
+		service: r.service,
+	}
+	return client.ProcessOrder(ctx, req)
+}
+
+// ProcessOrderWithHistory processes an order request like ProcessOrder, also returning a record of every attempt
+func (r *retryClient) ProcessOrderWithHistory(ctx context.Context, req service.OrderRequest) (service.OrderResponse, []AttemptRecord, error) {
+	if r.idempotencyKeys && req.IdempotencyKey == "" {
+		req.IdempotencyKey = req.ID
+	}
+	if r.breaker == nil {
+		return r.DoWithHistory(ctx, req, r.processOrder())
+	}
+
+	// The breaker sees the outcome of the call as a whole, so it isn't tripped by failures the retries recover from
+	var history []AttemptRecord
+	resp, err := r.breaker.Do(ctx, req, func(ctx context.Context, req service.OrderRequest) (service.OrderResponse, error) {
+		var (
+			resp service.OrderResponse
+			err  error
+		)
+		resp, history, err = r.DoWithHistory(ctx, req, r.processOrder())
+		return resp, err
+	})
+	return resp, history, err
+}
+
+// processOrder returns the function making each attempt of a single ProcessOrder call. If WithCommittedResponse
+// is set, it skips the service once an attempt was committed despite failing.
+func (r *retryClient) processOrder() func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+	if r.committed == nil {
+		return r.service.ProcessOrder
+	}
+
+	// Attempts are made one at a time, so the results don't need a lock
+	results := make(map[string]service.OrderResponse)
+	return func(ctx context.Context, req service.OrderRequest) (service.OrderResponse, error) {
+		if resp, ok := results[req.ID]; ok {
+			r.logger.Infof("order %s was committed by an earlier attempt, not resubmitting it", req.ID)
+			return resp, nil
+		}
+
+		resp, err := r.service.ProcessOrder(ctx, req)
+		if err != nil && r.committed(resp, err) {
+			results[req.ID] = resp
+		}
+		return resp, err
+	}
+}
+
+// ProcessOrderItems processes each item independently and in parallel, retrying them with the client's retry logic.
+// Responses are returned in the same order as items, with a zero response for every item that failed,
+// and the error lists which items failed.
+func (r *retryClient) ProcessOrderItems(ctx context.Context, items []service.Item) ([]service.ItemResponse, error) {
+	if r.itemProcessor == nil {
+		return nil, errors.New("item processor is not configured")
+	}
+
+	itemRetrier := &Retrier[service.Item, service.ItemResponse]{retrier: r.retrier}
+
+	var g errgroup.Group
+	if r.itemParallelism > 0 {
+		g.SetLimit(r.itemParallelism)
+	}
+
+	// Each goroutine only writes its own index, so neither slice needs a lock
+	responses := make([]service.ItemResponse, len(items))
+	errs := make([]error, len(items))
+	for i, item := range items {
+		g.Go(func() error {
+			responses[i], errs[i] = itemRetrier.Do(ctx, item, r.itemProcessor.ProcessItem)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("item %d (product %s): %w", i, item.ProductID, errs[i])
+			}
+			// Items are independent, so one failing mustn't stop the others
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return responses, errors.Join(errs...)
+}
+
+// logFields formats the key-value pairs extracted from ctx as " key=value" for the end of a log line
+func (r *retrier) logFields(ctx context.Context) string {
+	if r.fields == nil {
+		return ""
+	}
+
+	kvs := r.fields(ctx)
+	var b strings.Builder
+	for i := 0; i < len(kvs); i += 2 {
+		if i+1 == len(kvs) {
+			// A key without a value is logged on its own rather than dropped
+			fmt.Fprintf(&b, " %v", kvs[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", kvs[i], kvs[i+1])
+	}
+	return b.String()
+}
+
+// attemptTimeout returns the timeout for the given zero-based attempt, sharing the time left before
+// ctx's deadline between the remaining attempts if WithDeadlineBudget is set
+func (r *retrier) attemptTimeout(ctx context.Context, attempt int) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !r.budget || !ok {
+		return r.timeout
+	}
+
+	left := r.clock.Until(deadline)
+	attemptsLeft := r.maxAttempts - attempt
+	if attemptsLeft <= 1 {
+		// The last attempt gets all the time remaining
+		return min(r.timeout, left)
+	}
+
+	// Leave time for the backoff before each remaining retry, then share out what's left
+	var pending time.Duration
+	for i := attempt; i < r.maxAttempts-1; i++ {
+		pending += r.backoff.Delay(i)
+	}
+	remaining := left - pending
+	if remaining <= 0 {
+		// The waits alone outlast the deadline, so later attempts won't run and this one gets all the time left
+		return min(r.timeout, left)
+	}
+	return min(r.timeout, remaining/time.Duration(attemptsLeft))
+}
+
+// nextDelay returns the wait after the given zero-based attempt failed with err,
+// preferring a server-suggested delay over the backoff
+func (r *retrier) nextDelay(attempt int, err error) time.Duration {
+	if r.retryAfter != nil {
+		if delay, ok := r.retryAfter(err); ok {
+			return max(0, min(delay, r.maxInterval))
+		}
+	}
+	return r.backoffDelay(attempt)
+}
+
+// backoffDelay calculates the delay before the next attempt using the backoff strategy
+func (r *retrier) backoffDelay(attempt int) time.Duration {
+	return r.applyJitter(r.backoff.Delay(attempt))
+}
+
+// applyJitter randomizes the delay according to the configured jitter type
+func (r *retrier) applyJitter(delay time.Duration) time.Duration {
+	switch r.jitter {
+	case FullJitter:
+		return time.Duration(r.random() * float64(delay))
+	case EqualJitter:
+		return delay/2 + time.Duration(r.random()*float64(delay/2))
+	default:
+		return delay
+	}
+}
+
+// startSpan starts a span for an attempt, or returns a no-op span and ctx unchanged when no tracer is set
+func (r *retrier) startSpan(ctx context.Context, attempt int) (context.Context, trace.Span) {
+	if r.tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	return r.tracer.Start(ctx, "retry.attempt", trace.WithAttributes(
+		attribute.Int("retry.attempt", attempt),
+		attribute.Int("retry.max_attempts", r.maxAttempts),
+	))
 }
 
-// backoffDelay calculates the exponential backoff delay
-func (r *retryClient) backoffDelay(attempt int) time.Duration {
-	delay := float64(r.initialInterval) * math.Pow(r.multiplier, float64(attempt))
-	if time.Duration(delay) > r.maxInterval {
-		return r.maxInterval
+// endSpan records the outcome of an attempt on its span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
 	}
-	return time.Duration(delay)
+	span.End()
 }
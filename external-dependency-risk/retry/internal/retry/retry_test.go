@@ -3,13 +3,21 @@ package retry_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/mock/gomock"
 
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/internal/testutil"
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/mocks"
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/retry"
 	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/service"
@@ -249,3 +257,1471 @@ func TestProcessOrder(t *testing.T) {
 		require.Equal(t, expectedOrder, result.order)
 	})
 }
+
+// fixedSource is a rand.Source that always produces the same value
+type fixedSource int64
+
+func (s fixedSource) Int63() int64 { return int64(s) }
+func (s fixedSource) Seed(int64)   {}
+
+// half makes rand.Float64 return 0.5
+const half = fixedSource(1 << 62)
+
+func TestJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("unknown jitter type", func(t *testing.T) {
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithJitter(retry.JitterType(42)))
+		require.Error(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("nil rand", func(t *testing.T) {
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithRand(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "rand is nil")
+	})
+
+	for _, tc := range []struct {
+		name  string
+		kind  retry.JitterType
+		delay time.Duration
+	}{
+		{name: "no jitter", kind: retry.NoJitter, delay: 100 * time.Millisecond},
+		{name: "full jitter", kind: retry.FullJitter, delay: 50 * time.Millisecond},
+		{name: "equal jitter", kind: retry.EqualJitter, delay: 75 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewMockOrderProcessor(ctrl)
+			fakeClock := clockwork.NewFakeClock()
+			r, err := retry.New(mockService, 2, time.Second, 100*time.Millisecond, time.Second, 2.0,
+				retry.WithClock(fakeClock),
+				retry.WithJitter(tc.kind),
+				retry.WithRand(rand.New(half)),
+			)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+			mockService.EXPECT().
+				ProcessOrder(gomock.Any(), request).
+				Return(service.OrderResponse{}, errors.New("service unavailable")).
+				Times(1)
+
+			mockService.EXPECT().
+				ProcessOrder(gomock.Any(), request).
+				Return(service.OrderResponse{ID: "order-1"}, nil).
+				Times(1)
+
+			errChan := make(chan error)
+			go func() {
+				_, err := r.ProcessOrder(ctx, request)
+				errChan <- err
+			}()
+
+			// Advancing by exactly the jittered delay must release the retry
+			fakeClock.BlockUntilContext(ctx, 1)
+			fakeClock.Advance(tc.delay)
+
+			select {
+			case err := <-errChan:
+				require.NoError(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("retry did not happen after jittered delay")
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	errInvalid := errors.New("invalid order")
+	retryable := func(err error) bool {
+		return !errors.Is(err, errInvalid)
+	}
+
+	t.Run("nil predicate", func(t *testing.T) {
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithRetryable(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("non-retryable error aborts after a single attempt", func(t *testing.T) {
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		r, err := retry.New(mockService, 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithRetryable(retryable))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{}, errInvalid).
+			Times(1)
+
+		order, err := r.ProcessOrder(ctx, request)
+		require.ErrorIs(t, err, errInvalid)
+		require.NotErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, service.OrderResponse{}, order)
+	})
+
+	t.Run("retryable error is retried", func(t *testing.T) {
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(mockService, 2, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithRetryable(retryable),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{}, errors.New("service unavailable")).
+			Times(2)
+
+		errChan := make(chan error)
+		go func() {
+			_, err := r.ProcessOrder(ctx, request)
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+
+		require.Equal(t, retry.ErrMaxAttemptsExceeded, <-errChan)
+	})
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("cancelled context does not call service", func(t *testing.T) {
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		r, err := retry.New(mockService, 3, time.Second, 100*time.Millisecond, time.Second, 2.0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		order, err := r.ProcessOrder(ctx, service.OrderRequest{ID: "order-1", Amount: 99.99})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, service.OrderResponse{}, order)
+	})
+
+	t.Run("cancellation during backoff stops retrying", func(t *testing.T) {
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(mockService, 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{}, errors.New("service unavailable")).
+			Times(1)
+
+		errChan := make(chan error)
+		go func() {
+			_, err := r.ProcessOrder(ctx, request)
+			errChan <- err
+		}()
+
+		// Cancel while waiting for the first backoff
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		require.ErrorIs(t, <-errChan, context.Canceled)
+	})
+
+	t.Run("parent deadline expiring mid-backoff returns the context error", func(t *testing.T) {
+		// The fake clock is never advanced, so the deadline passes during the first backoff
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.NewRetrier[string, int](5, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		calls := 0
+		_, err = r.Do(ctx, "lookup", func(context.Context, string) (int, error) {
+			calls++
+			return 0, errors.New("connection reset")
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("attempt failing because the caller gave up doesn't use up an attempt", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](3, time.Second, 100*time.Millisecond, time.Second, 2.0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		_, err = r.Do(ctx, "lookup", func(ctx context.Context, _ string) (int, error) {
+			calls++
+			cancel()
+			return 0, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("caller giving up on the last attempt returns the context error", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](1, time.Second, 100*time.Millisecond, time.Second, 2.0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = r.Do(ctx, "lookup", func(context.Context, string) (int, error) {
+			cancel()
+			return 0, errors.New("connection reset")
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("each attempt gets a fresh full timeout", func(t *testing.T) {
+		const timeout = 50 * time.Millisecond
+
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.NewRetrier[string, int](2, timeout, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		type key struct{}
+		ctx := context.WithValue(context.Background(), key{}, "request-scoped")
+
+		var remaining []time.Duration
+		errChan := make(chan error)
+		go func() {
+			_, err := r.Do(ctx, "lookup", func(ctx context.Context, req string) (int, error) {
+				require.Equal(t, "request-scoped", ctx.Value(key{}))
+				require.NoError(t, ctx.Err())
+
+				deadline, ok := ctx.Deadline()
+				require.True(t, ok)
+				remaining = append(remaining, time.Until(deadline))
+
+				if len(remaining) == 1 {
+					// Use up the whole timeout of the first attempt
+					<-ctx.Done()
+					return 0, ctx.Err()
+				}
+				return len(req), nil
+			})
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+
+		require.NoError(t, <-errChan)
+		require.Len(t, remaining, 2)
+		// The second attempt starts after the first timed out, yet still has close to the full timeout
+		require.Greater(t, remaining[1], timeout/2)
+	})
+}
+
+func TestWithBackoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("nil backoff", func(t *testing.T) {
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithBackoff(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "backoff is nil")
+	})
+
+	t.Run("custom backoff is used", func(t *testing.T) {
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(mockService, 3, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithBackoff(retry.ConstantBackoff{Interval: 300 * time.Millisecond}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{}, errors.New("service unavailable")).
+			Times(2)
+
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{ID: "order-1"}, nil).
+			Times(1)
+
+		errChan := make(chan error)
+		go func() {
+			_, err := r.ProcessOrder(ctx, request)
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(300 * time.Millisecond)
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(300 * time.Millisecond)
+
+		require.NoError(t, <-errChan)
+	})
+}
+
+func TestOnRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("nil callback", func(t *testing.T) {
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithOnRetry(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("invoked before each retry but not after the final attempt", func(t *testing.T) {
+		type retryEvent struct {
+			attempt int
+			err     error
+			delay   time.Duration
+		}
+
+		var events []retryEvent
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(mockService, 3, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithOnRetry(func(attempt int, err error, nextDelay time.Duration) {
+				events = append(events, retryEvent{attempt: attempt, err: err, delay: nextDelay})
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+		serviceErr := errors.New("service unavailable")
+		mockService.EXPECT().
+			ProcessOrder(gomock.Any(), request).
+			Return(service.OrderResponse{}, serviceErr).
+			Times(3)
+
+		errChan := make(chan error)
+		go func() {
+			_, err := r.ProcessOrder(ctx, request)
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(200 * time.Millisecond)
+
+		require.Equal(t, retry.ErrMaxAttemptsExceeded, <-errChan)
+		require.Equal(t, []retryEvent{
+			{attempt: 0, err: serviceErr, delay: 100 * time.Millisecond},
+			{attempt: 1, err: serviceErr, delay: 200 * time.Millisecond},
+		}, events)
+	})
+}
+
+func TestRetrier(t *testing.T) {
+	t.Run("invalid configuration", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](0, time.Second, 100*time.Millisecond, time.Second, 2.0)
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "maxAttempts must be greater than 0")
+	})
+
+	t.Run("retries arbitrary operation until success", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.NewRetrier[string, int](3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		calls := 0
+
+		type result struct {
+			res int
+			err error
+		}
+		resultChan := make(chan result)
+		go func() {
+			res, err := r.Do(ctx, "lookup", func(ctx context.Context, req string) (int, error) {
+				calls++
+				if calls < 2 {
+					return 0, errors.New("connection reset")
+				}
+				return len(req), nil
+			})
+			resultChan <- result{res, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, 6, res.res)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("returns zero value when attempts are exhausted", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](1, time.Second, 100*time.Millisecond, time.Second, 2.0)
+		require.NoError(t, err)
+
+		res, err := r.Do(context.Background(), "lookup", func(ctx context.Context, req string) (int, error) {
+			return 42, errors.New("connection reset")
+		})
+		require.Equal(t, retry.ErrMaxAttemptsExceeded, err)
+		require.Zero(t, res)
+	})
+}
+
+func TestTracer(t *testing.T) {
+	t.Run("nil tracer", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithTracer(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "tracer is nil")
+	})
+
+	t.Run("starts a child span for every attempt", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		tracer := provider.Tracer("retry_test")
+
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.NewRetrier[string, int](3, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithTracer(tracer),
+		)
+		require.NoError(t, err)
+
+		ctx, parent := tracer.Start(context.Background(), "lookup")
+		calls := 0
+
+		errChan := make(chan error)
+		go func() {
+			_, err := r.Do(ctx, "lookup", func(ctx context.Context, req string) (int, error) {
+				calls++
+				if calls < 3 {
+					return 0, errors.New("connection reset")
+				}
+				return len(req), nil
+			})
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(200 * time.Millisecond)
+
+		require.NoError(t, <-errChan)
+		parent.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 4)
+
+		wantStatus := []codes.Code{codes.Error, codes.Error, codes.Ok}
+		for i, span := range spans[:3] {
+			require.Equal(t, "retry.attempt", span.Name())
+			require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			require.ElementsMatch(t, []attribute.KeyValue{
+				attribute.Int("retry.attempt", i+1),
+				attribute.Int("retry.max_attempts", 3),
+			}, span.Attributes())
+			require.Equal(t, wantStatus[i], span.Status().Code)
+		}
+
+		// Failed attempts record their error as a span event
+		require.Len(t, spans[0].Events(), 1)
+		require.Equal(t, "exception", spans[0].Events()[0].Name)
+		require.Empty(t, spans[2].Events())
+	})
+}
+
+// orderProcessorFunc adapts a function to the OrderProcessor interface
+type orderProcessorFunc func(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error)
+
+func (f orderProcessorFunc) ProcessOrder(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error) {
+	return f(ctx, request)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	// lostFirstResponse processes every attempt, but the response to the first is lost to a timeout
+	lostFirstResponse := func(t *testing.T) (retry.OrderProcessor, *int, *[]string) {
+		orders := 0
+		svc, err := service.NewOrderService(0, 0, service.WithIDGenerator(func() string {
+			orders++
+			return fmt.Sprintf("ord-%d", orders)
+		}))
+		require.NoError(t, err)
+
+		var keys []string
+		return orderProcessorFunc(func(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error) {
+			keys = append(keys, request.IdempotencyKey)
+			response, err := svc.ProcessOrder(ctx, request)
+			if len(keys) == 1 {
+				return service.OrderResponse{}, context.DeadlineExceeded
+			}
+			return response, err
+		}), &orders, &keys
+	}
+
+	run := func(t *testing.T, r retry.OrderProcessor, fakeClock *clockwork.FakeClock, request service.OrderRequest) service.OrderResponse {
+		ctx := context.Background()
+		type result struct {
+			response service.OrderResponse
+			err      error
+		}
+		resultChan := make(chan result)
+		go func() {
+			response, err := r.ProcessOrder(ctx, request)
+			resultChan <- result{response, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(100 * time.Millisecond)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		return res.response
+	}
+
+	t.Run("timeout then success yields a single order", func(t *testing.T) {
+		svc, orders, keys := lostFirstResponse(t)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(svc, 3, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithIdempotencyKeys(),
+		)
+		require.NoError(t, err)
+
+		response := run(t, r, fakeClock, service.OrderRequest{ID: "order-1", Amount: 99.99})
+		require.Equal(t, "ord-1", response.OrderID)
+		require.Equal(t, 1, *orders)
+		require.Equal(t, []string{"order-1", "order-1"}, *keys)
+	})
+
+	t.Run("caller provided key is used for every attempt", func(t *testing.T) {
+		svc, orders, keys := lostFirstResponse(t)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(svc, 3, time.Second, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithIdempotencyKeys(),
+		)
+		require.NoError(t, err)
+
+		response := run(t, r, fakeClock, service.OrderRequest{ID: "order-1", IdempotencyKey: "checkout-42"})
+		require.Equal(t, "ord-1", response.OrderID)
+		require.Equal(t, 1, *orders)
+		require.Equal(t, []string{"checkout-42", "checkout-42"}, *keys)
+	})
+
+	t.Run("order processed after its attempt timed out is returned by the retry", func(t *testing.T) {
+		orders := 0
+		fakeClock := clockwork.NewFakeClock()
+		svc, err := service.NewOrderService(time.Second, 0, service.WithClock(fakeClock), service.WithIDGenerator(func() string {
+			orders++
+			return fmt.Sprintf("ord-%d", orders)
+		}))
+		require.NoError(t, err)
+
+		r, err := retry.New(svc, 3, 200*time.Millisecond, 100*time.Millisecond, time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithIdempotencyKeys(),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		type result struct {
+			response service.OrderResponse
+			err      error
+		}
+		resultChan := make(chan result)
+		go func() {
+			response, err := r.ProcessOrder(ctx, service.OrderRequest{ID: "order-1"})
+			resultChan <- result{response, err}
+		}()
+
+		// The first attempt times out while the service is still processing it, then the backoff starts
+		fakeClock.BlockUntilContext(ctx, 2)
+		fakeClock.Advance(100 * time.Millisecond)
+
+		// The retry reaches the service alongside the first attempt, which processes the order once
+		fakeClock.BlockUntilContext(ctx, 2)
+		fakeClock.Advance(time.Second)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, "ord-1", res.response.OrderID)
+		require.Equal(t, 1, orders)
+	})
+
+	t.Run("without keys a retry processes the order twice", func(t *testing.T) {
+		svc, orders, keys := lostFirstResponse(t)
+		fakeClock := clockwork.NewFakeClock()
+		r, err := retry.New(svc, 3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		response := run(t, r, fakeClock, service.OrderRequest{ID: "order-1"})
+		require.Equal(t, "ord-2", response.OrderID)
+		require.Equal(t, 2, *orders)
+		require.Equal(t, []string{"", ""}, *keys)
+	})
+}
+
+// retryAfterError carries a server-suggested delay, as an HTTP client might for a Retry-After header
+type retryAfterError struct {
+	delay time.Duration
+}
+
+func (e retryAfterError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %v", e.delay)
+}
+
+func TestRetryAfter(t *testing.T) {
+	retryAfter := func(err error) (time.Duration, bool) {
+		var target retryAfterError
+		if errors.As(err, &target) {
+			return target.delay, true
+		}
+		return 0, false
+	}
+
+	t.Run("nil func", func(t *testing.T) {
+		r, err := retry.NewRetrier[string, int](3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithRetryAfter(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "retry after func is nil")
+	})
+
+	tests := []struct {
+		name      string
+		err       error
+		wantDelay time.Duration
+	}{
+		{name: "server hint replaces the backoff", err: retryAfterError{delay: 5 * time.Second}, wantDelay: 5 * time.Second},
+		{name: "server hint is capped at max interval", err: retryAfterError{delay: time.Minute}, wantDelay: 10 * time.Second},
+		{name: "errors without a hint use the backoff", err: errors.New("connection reset"), wantDelay: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delays []time.Duration
+			fakeClock := clockwork.NewFakeClock()
+			r, err := retry.NewRetrier[string, int](2, time.Second, 100*time.Millisecond, 10*time.Second, 2.0,
+				retry.WithClock(fakeClock),
+				retry.WithRetryAfter(retryAfter),
+				retry.WithOnRetry(func(_ int, _ error, nextDelay time.Duration) {
+					delays = append(delays, nextDelay)
+				}),
+			)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			calls := 0
+			errChan := make(chan error)
+			go func() {
+				_, err := r.Do(ctx, "lookup", func(context.Context, string) (int, error) {
+					calls++
+					if calls == 1 {
+						return 0, tt.err
+					}
+					return 1, nil
+				})
+				errChan <- err
+			}()
+
+			fakeClock.BlockUntilContext(ctx, 1)
+			fakeClock.Advance(tt.wantDelay - time.Millisecond)
+			select {
+			case err := <-errChan:
+				t.Fatalf("retried before the delay elapsed: %v", err)
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			fakeClock.Advance(time.Millisecond)
+			require.NoError(t, <-errChan)
+			require.Equal(t, []time.Duration{tt.wantDelay}, delays)
+		})
+	}
+}
+
+func TestHarness(t *testing.T) {
+	h := testutil.NewHarness()
+	script := testutil.FailNTimesThenSucceed(2)
+
+	r, err := retry.NewRetrier[string, string](3, time.Second, 100*time.Millisecond, time.Second, 2.0, retry.WithClock(h.Clock))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	type result struct {
+		res string
+		err error
+	}
+	resultChan := make(chan result)
+	go func() {
+		res, err := r.Do(ctx, "lookup", testutil.Func[string](script, "found"))
+		resultChan <- result{res, err}
+	}()
+
+	// Each failure waits out its backoff on the shared clock
+	require.NoError(t, h.AdvanceWhenBlocked(ctx, 1, 100*time.Millisecond))
+	require.NoError(t, h.AdvanceWhenBlocked(ctx, 1, 200*time.Millisecond))
+
+	res := <-resultChan
+	require.NoError(t, res.err)
+	require.Equal(t, "found", res.res)
+	require.Equal(t, 3, script.Calls())
+}
+
+func TestProcessOrderItems(t *testing.T) {
+	items := []service.Item{
+		{ProductID: "widget", Quantity: 1, Price: 10},
+		{ProductID: "gadget", Quantity: 2, Price: 20},
+		{ProductID: "gizmo", Quantity: 3, Price: 30},
+	}
+	reserved := func(item service.Item) service.ItemResponse {
+		return service.ItemResponse{ProductID: item.ProductID, Quantity: item.Quantity, Status: "reserved"}
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithItemProcessor(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "item processor is nil")
+
+		r, err = retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithItemParallelism(0))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "item parallelism must be greater than 0")
+	})
+
+	t.Run("item processor not configured", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+
+		_, err = r.ProcessOrderItems(context.Background(), items)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "item processor is not configured")
+	})
+
+	t.Run("every item succeeds after retries", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockItems := mocks.NewMockItemProcessor(ctrl)
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithItemProcessor(mockItems),
+		)
+		require.NoError(t, err)
+
+		for _, item := range items {
+			gomock.InOrder(
+				mockItems.EXPECT().ProcessItem(gomock.Any(), item).Return(service.ItemResponse{}, errors.New("inventory unavailable")),
+				mockItems.EXPECT().ProcessItem(gomock.Any(), item).Return(reserved(item), nil),
+			)
+		}
+
+		responses, err := r.ProcessOrderItems(context.Background(), items)
+		require.NoError(t, err)
+		require.Equal(t, []service.ItemResponse{reserved(items[0]), reserved(items[1]), reserved(items[2])}, responses)
+	})
+
+	t.Run("one item exhausting its retries doesn't stop the others", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockItems := mocks.NewMockItemProcessor(ctrl)
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithItemProcessor(mockItems),
+		)
+		require.NoError(t, err)
+
+		mockItems.EXPECT().ProcessItem(gomock.Any(), items[0]).Return(reserved(items[0]), nil)
+		mockItems.EXPECT().ProcessItem(gomock.Any(), items[1]).Return(service.ItemResponse{}, errors.New("out of stock")).Times(3)
+		mockItems.EXPECT().ProcessItem(gomock.Any(), items[2]).Return(reserved(items[2]), nil)
+
+		responses, err := r.ProcessOrderItems(context.Background(), items)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Contains(t, err.Error(), "item 1 (product gadget)")
+		require.NotContains(t, err.Error(), "widget")
+		require.NotContains(t, err.Error(), "gizmo")
+		require.Equal(t, []service.ItemResponse{reserved(items[0]), {}, reserved(items[2])}, responses)
+	})
+
+	t.Run("items run in parallel up to the configured limit", func(t *testing.T) {
+		var (
+			lock              sync.Mutex
+			inFlight, maxSeen int
+		)
+		processor := itemProcessorFunc(func(ctx context.Context, item service.Item) (service.ItemResponse, error) {
+			lock.Lock()
+			inFlight++
+			maxSeen = max(maxSeen, inFlight)
+			lock.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			lock.Lock()
+			inFlight--
+			lock.Unlock()
+			return reserved(item), nil
+		})
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithItemProcessor(processor),
+			retry.WithItemParallelism(2),
+		)
+		require.NoError(t, err)
+
+		many := make([]service.Item, 8)
+		for i := range many {
+			many[i] = service.Item{ProductID: fmt.Sprintf("product-%d", i), Quantity: 1}
+		}
+
+		responses, err := r.ProcessOrderItems(context.Background(), many)
+		require.NoError(t, err)
+		require.Len(t, responses, len(many))
+		for i, resp := range responses {
+			require.Equal(t, many[i].ProductID, resp.ProductID)
+		}
+		require.Equal(t, 2, maxSeen)
+	})
+}
+
+// itemProcessorFunc adapts a function to the ItemProcessor interface
+type itemProcessorFunc func(ctx context.Context, item service.Item) (service.ItemResponse, error)
+
+func (f itemProcessorFunc) ProcessItem(ctx context.Context, item service.Item) (service.ItemResponse, error) {
+	return f(ctx, item)
+}
+
+func TestProcessOrderWithHistory(t *testing.T) {
+	request := service.OrderRequest{ID: "order-1", Amount: 100}
+
+	// attempts returns a processor whose nth call takes latencies[n] on fakeClock and returns errs[n]
+	attempts := func(fakeClock *clockwork.FakeClock, latencies []time.Duration, errs []error) retry.OrderProcessor {
+		call := 0
+		return orderProcessorFunc(func(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error) {
+			n := call
+			call++
+			fakeClock.Advance(latencies[n])
+			if errs[n] != nil {
+				return service.OrderResponse{}, errs[n]
+			}
+			return service.OrderResponse{ID: request.ID, Status: "completed"}, nil
+		})
+	}
+
+	type result struct {
+		response service.OrderResponse
+		history  []retry.AttemptRecord
+		err      error
+	}
+
+	// run processes the request in the background, advancing fakeClock through each backoff
+	run := func(t *testing.T, r interface {
+		ProcessOrderWithHistory(context.Context, service.OrderRequest) (service.OrderResponse, []retry.AttemptRecord, error)
+	}, fakeClock *clockwork.FakeClock, backoffs ...time.Duration) result {
+		ctx := context.Background()
+		resultChan := make(chan result, 1)
+		go func() {
+			response, history, err := r.ProcessOrderWithHistory(ctx, request)
+			resultChan <- result{response, history, err}
+		}()
+
+		for _, backoff := range backoffs {
+			require.NoError(t, fakeClock.BlockUntilContext(ctx, 1))
+			fakeClock.Advance(backoff)
+		}
+		return <-resultChan
+	}
+
+	t.Run("history is returned on success", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		err1, err2 := errors.New("timeout"), errors.New("unavailable")
+		r, err := retry.New(
+			attempts(fakeClock, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, []error{err1, err2, nil}),
+			3, time.Minute, time.Second, 10*time.Second, 2.0,
+			retry.WithClock(fakeClock),
+		)
+		require.NoError(t, err)
+
+		res := run(t, r, fakeClock, time.Second, 2*time.Second)
+		require.NoError(t, res.err)
+		require.Equal(t, "completed", res.response.Status)
+		require.Equal(t, []retry.AttemptRecord{
+			{Attempt: 1, Err: err1, Duration: 100 * time.Millisecond, Timeout: time.Minute},
+			{Attempt: 2, Err: err2, Duration: 200 * time.Millisecond, Timeout: time.Minute},
+			{Attempt: 3, Err: nil, Duration: 300 * time.Millisecond, Timeout: time.Minute},
+		}, res.history)
+	})
+
+	t.Run("history is returned when attempts are exhausted", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		errs := []error{errors.New("first"), errors.New("second"), errors.New("third")}
+		r, err := retry.New(
+			attempts(fakeClock, []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}, errs),
+			3, time.Minute, time.Second, 10*time.Second, 2.0,
+			retry.WithClock(fakeClock),
+		)
+		require.NoError(t, err)
+
+		res := run(t, r, fakeClock, time.Second, 2*time.Second)
+		require.ErrorIs(t, res.err, retry.ErrMaxAttemptsExceeded)
+		require.Len(t, res.history, 3)
+		for i, record := range res.history {
+			require.Equal(t, i+1, record.Attempt)
+			require.Equal(t, errs[i], record.Err)
+		}
+	})
+
+	t.Run("non-retryable error stops the history", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		errInvalid := errors.New("invalid order")
+		r, err := retry.New(
+			attempts(fakeClock, []time.Duration{50 * time.Millisecond}, []error{errInvalid}),
+			3, time.Minute, time.Second, 10*time.Second, 2.0,
+			retry.WithClock(fakeClock),
+			retry.WithRetryable(func(err error) bool { return !errors.Is(err, errInvalid) }),
+		)
+		require.NoError(t, err)
+
+		res := run(t, r, fakeClock)
+		require.ErrorIs(t, res.err, errInvalid)
+		require.Equal(t, []retry.AttemptRecord{{Attempt: 1, Err: errInvalid, Duration: 50 * time.Millisecond, Timeout: time.Minute}}, res.history)
+	})
+}
+
+// capturingLogger records every formatted message logged to it
+type capturingLogger struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...any) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...any) { l.log("DEBUG", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...any)  { l.log("INFO", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...any)  { l.log("WARN", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...any) { l.log("ERROR", format, args...) }
+
+// traceIDKey is the context key the tests carry a trace ID under
+type traceIDKey struct{}
+
+func TestContextFields(t *testing.T) {
+	request := service.OrderRequest{ID: "order-1"}
+	traceID := func(ctx context.Context) []any {
+		id, _ := ctx.Value(traceIDKey{}).(string)
+		return []any{"trace_id", id}
+	}
+
+	t.Run("nil func", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithContextFields(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "context fields func is nil")
+	})
+
+	t.Run("trace ID from the context appears on retry lines", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		logger := &capturingLogger{}
+		calls := 0
+		r, err := retry.New(mockService, 3, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithLogger(logger),
+			retry.WithContextFields(func(ctx context.Context) []any {
+				calls++
+				return traceID(ctx)
+			}),
+		)
+		require.NoError(t, err)
+
+		ctx := context.WithValue(context.Background(), traceIDKey{}, "abc123")
+		mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(service.OrderResponse{}, errors.New("boom")).Times(3)
+
+		_, err = r.ProcessOrder(ctx, request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, []string{
+			"WARN: attempt 1/3 failed, retrying in 1ms: boom trace_id=abc123",
+			"WARN: attempt 2/3 failed, retrying in 1ms: boom trace_id=abc123",
+			"ERROR: all 3 attempts failed trace_id=abc123",
+		}, logger.messages)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("fields are added to non-retryable lines", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		logger := &capturingLogger{}
+		r, err := retry.New(mockService, 3, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithLogger(logger),
+			retry.WithContextFields(traceID),
+			retry.WithRetryable(func(error) bool { return false }),
+		)
+		require.NoError(t, err)
+
+		ctx := context.WithValue(context.Background(), traceIDKey{}, "def456")
+		mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(service.OrderResponse{}, errors.New("invalid"))
+
+		_, err = r.ProcessOrder(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, []string{
+			"DEBUG: attempt 1/3 failed with a non-retryable error: invalid trace_id=def456",
+		}, logger.messages)
+	})
+
+	t.Run("key without a value is still logged", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		logger := &capturingLogger{}
+		r, err := retry.New(mockService, 1, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithLogger(logger),
+			retry.WithContextFields(func(context.Context) []any { return []any{"user", "u1", "dangling"} }),
+		)
+		require.NoError(t, err)
+
+		mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(service.OrderResponse{}, errors.New("boom"))
+
+		_, err = r.ProcessOrder(context.Background(), request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, []string{"ERROR: all 1 attempts failed user=u1 dangling"}, logger.messages)
+	})
+}
+
+func TestCommittedResponse(t *testing.T) {
+	errConnectionReset := errors.New("connection reset by peer")
+
+	// hasOrderID treats a response carrying an order ID as committed, whatever the error
+	hasOrderID := func(response service.OrderResponse, _ error) bool {
+		return response.OrderID != ""
+	}
+
+	// committedThenLost processes every attempt, but the first fails after the order was committed
+	committedThenLost := func(t *testing.T) (retry.OrderProcessor, *int) {
+		orders := 0
+		svc, err := service.NewOrderService(0, 0, service.WithIDGenerator(func() string {
+			orders++
+			return fmt.Sprintf("ord-%d", orders)
+		}))
+		require.NoError(t, err)
+
+		return orderProcessorFunc(func(ctx context.Context, request service.OrderRequest) (service.OrderResponse, error) {
+			response, err := svc.ProcessOrder(ctx, request)
+			if orders == 1 {
+				return response, errConnectionReset
+			}
+			return response, err
+		}), &orders
+	}
+
+	t.Run("nil func", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithCommittedResponse(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "committed func is nil")
+	})
+
+	t.Run("committed order whose response was lost isn't resubmitted", func(t *testing.T) {
+		svc, orders := committedThenLost(t)
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithCommittedResponse(hasOrderID))
+		require.NoError(t, err)
+
+		response, history, err := r.ProcessOrderWithHistory(context.Background(), service.OrderRequest{ID: "order-1", Amount: 99.99})
+		require.NoError(t, err)
+		require.Equal(t, "ord-1", response.OrderID)
+		require.Equal(t, 1, *orders)
+		require.Len(t, history, 2)
+		require.ErrorIs(t, history[0].Err, errConnectionReset)
+		require.NoError(t, history[1].Err)
+	})
+
+	t.Run("without it the order is processed twice", func(t *testing.T) {
+		svc, orders := committedThenLost(t)
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+
+		response, err := r.ProcessOrder(context.Background(), service.OrderRequest{ID: "order-1", Amount: 99.99})
+		require.NoError(t, err)
+		require.Equal(t, "ord-2", response.OrderID)
+		require.Equal(t, 2, *orders)
+	})
+
+	t.Run("uncommitted failures are resubmitted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		r, err := retry.New(mockService, 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithCommittedResponse(hasOrderID))
+		require.NoError(t, err)
+
+		request := service.OrderRequest{ID: "order-1"}
+		expected := service.OrderResponse{OrderID: "ord-1", Status: "completed"}
+		gomock.InOrder(
+			mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(service.OrderResponse{}, errConnectionReset).Times(2),
+			mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(expected, nil),
+		)
+
+		response, err := r.ProcessOrder(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, expected, response)
+	})
+
+	t.Run("committed responses aren't shared between calls", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockService := mocks.NewMockOrderProcessor(ctrl)
+		r, err := retry.New(mockService, 1, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithCommittedResponse(hasOrderID))
+		require.NoError(t, err)
+
+		request := service.OrderRequest{ID: "order-1"}
+		mockService.EXPECT().ProcessOrder(gomock.Any(), request).Return(service.OrderResponse{OrderID: "ord-1"}, errConnectionReset).Times(2)
+
+		for range 2 {
+			_, err := r.ProcessOrder(context.Background(), request)
+			require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		}
+	})
+}
+
+// deadlineCtx reports a deadline without ever expiring, so budgets can be measured against a fake clock
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func TestDeadlineBudget(t *testing.T) {
+	request := service.OrderRequest{ID: "order-1"}
+	errUnavailable := errors.New("service unavailable")
+
+	// timeouts fails every attempt after it takes latency on fakeClock, advancing fakeClock through each
+	// backoff, and returns how long each attempt was given
+	timeouts := func(t *testing.T, ctx context.Context, fakeClock *clockwork.FakeClock, maxAttempts int, timeout, backoff, latency time.Duration) []time.Duration {
+		processor := orderProcessorFunc(func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+			fakeClock.Advance(latency)
+			return service.OrderResponse{}, errUnavailable
+		})
+		r, err := retry.New(processor, maxAttempts, timeout, backoff, backoff, 1, retry.WithClock(fakeClock), retry.WithDeadlineBudget())
+		require.NoError(t, err)
+
+		type result struct {
+			history []retry.AttemptRecord
+			err     error
+		}
+		resultChan := make(chan result, 1)
+		go func() {
+			_, history, err := r.ProcessOrderWithHistory(ctx, request)
+			resultChan <- result{history, err}
+		}()
+
+		for range maxAttempts - 1 {
+			require.NoError(t, fakeClock.BlockUntilContext(context.Background(), 1))
+			fakeClock.Advance(backoff)
+		}
+		res := <-resultChan
+		require.ErrorIs(t, res.err, retry.ErrMaxAttemptsExceeded)
+
+		var timeouts []time.Duration
+		for _, record := range res.history {
+			timeouts = append(timeouts, record.Timeout)
+		}
+		return timeouts
+	}
+
+	t.Run("attempts shrink to fit a tight parent deadline", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		ctx := deadlineCtx{Context: context.Background(), deadline: fakeClock.Now().Add(320 * time.Millisecond)}
+
+		// A third of what's left after two 10ms waits, then half of what's left after the first 40ms
+		// attempt and one more wait, then the rest
+		got := timeouts(t, ctx, fakeClock, 3, 10*time.Second, 10*time.Millisecond, 40*time.Millisecond)
+		require.Equal(t, []time.Duration{100 * time.Millisecond, 130 * time.Millisecond, 220 * time.Millisecond}, got)
+	})
+
+	t.Run("backoff between the remaining attempts is left out of the budget", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		ctx := deadlineCtx{Context: context.Background(), deadline: fakeClock.Now().Add(400 * time.Millisecond)}
+
+		// A third of what's left after two 50ms waits, then half of what's left after one, then the rest
+		got := timeouts(t, ctx, fakeClock, 3, 10*time.Second, 50*time.Millisecond, 0)
+		require.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 300 * time.Millisecond}, got)
+	})
+
+	t.Run("without a budget the first attempt can use up the deadline", func(t *testing.T) {
+		attempts := 0
+		hang := orderProcessorFunc(func(ctx context.Context, _ service.OrderRequest) (service.OrderResponse, error) {
+			attempts++
+			<-ctx.Done()
+			return service.OrderResponse{}, ctx.Err()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+
+		r, err := retry.New(hang, 3, 10*time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+		_, err = r.ProcessOrder(ctx, request)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, attempts)
+
+		ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+
+		attempts = 0
+		r, err = retry.New(hang, 3, 10*time.Second, time.Millisecond, time.Millisecond, 1, retry.WithDeadlineBudget())
+		require.NoError(t, err)
+		_, err = r.ProcessOrder(ctx, request)
+		require.Error(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("timeout still caps each attempt", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		ctx := deadlineCtx{Context: context.Background(), deadline: fakeClock.Now().Add(10 * time.Second)}
+
+		got := timeouts(t, ctx, fakeClock, 2, 50*time.Millisecond, time.Millisecond, 0)
+		require.Equal(t, []time.Duration{50 * time.Millisecond, 50 * time.Millisecond}, got)
+	})
+
+	t.Run("no parent deadline uses the timeout", func(t *testing.T) {
+		got := timeouts(t, context.Background(), clockwork.NewFakeClock(), 2, time.Second, time.Millisecond, 0)
+		require.Equal(t, []time.Duration{time.Second, time.Second}, got)
+	})
+}
+
+// errOpen is returned by fakeBreaker while it is open
+var errOpen = errors.New("circuit breaker is open")
+
+// fakeBreaker opens once threshold consecutive calls have failed, counting the results it records
+type fakeBreaker struct {
+	threshold           int
+	failures, successes int
+	open                bool
+}
+
+func (b *fakeBreaker) Do(ctx context.Context, req service.OrderRequest, fn func(context.Context, service.OrderRequest) (service.OrderResponse, error)) (service.OrderResponse, error) {
+	if b.open {
+		return service.OrderResponse{}, errOpen
+	}
+
+	resp, err := fn(ctx, req)
+	if err != nil {
+		b.failures++
+		b.open = b.failures >= b.threshold
+		return resp, err
+	}
+	b.successes++
+	b.failures = 0
+	return resp, nil
+}
+
+func TestBreaker(t *testing.T) {
+	errService := errors.New("service unavailable")
+	request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+	t.Run("nil breaker", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		r, err := retry.New(mocks.NewMockOrderProcessor(ctrl), 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithBreaker(nil))
+		require.Error(t, err)
+		require.Nil(t, r)
+		require.Contains(t, err.Error(), "breaker is nil")
+	})
+
+	t.Run("exhausted retries count as a single failure", func(t *testing.T) {
+		attempts := 0
+		svc := orderProcessorFunc(func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+			attempts++
+			return service.OrderResponse{}, errService
+		})
+		breaker := &fakeBreaker{threshold: 2}
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithBreaker(breaker))
+		require.NoError(t, err)
+
+		_, history, err := r.ProcessOrderWithHistory(context.Background(), request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Len(t, history, 3)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, 1, breaker.failures)
+		require.False(t, breaker.open)
+
+		_, err = r.ProcessOrder(context.Background(), request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, 6, attempts)
+		require.Equal(t, 2, breaker.failures)
+		require.True(t, breaker.open)
+	})
+
+	t.Run("open breaker fails fast without making attempts", func(t *testing.T) {
+		attempts := 0
+		svc := orderProcessorFunc(func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+			attempts++
+			return service.OrderResponse{}, nil
+		})
+		breaker := &fakeBreaker{threshold: 1, open: true}
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithBreaker(breaker))
+		require.NoError(t, err)
+
+		_, history, err := r.ProcessOrderWithHistory(context.Background(), request)
+		require.ErrorIs(t, err, errOpen)
+		require.Empty(t, history)
+		require.Zero(t, attempts)
+	})
+
+	t.Run("eventual success counts as a single success", func(t *testing.T) {
+		attempts := 0
+		svc := orderProcessorFunc(func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+			attempts++
+			if attempts < 3 {
+				return service.OrderResponse{}, errService
+			}
+			return service.OrderResponse{OrderID: "ord-1"}, nil
+		})
+		breaker := &fakeBreaker{threshold: 1}
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1, retry.WithBreaker(breaker))
+		require.NoError(t, err)
+
+		response, err := r.ProcessOrder(context.Background(), request)
+		require.NoError(t, err)
+		require.Equal(t, "ord-1", response.OrderID)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, 1, breaker.successes)
+		require.Zero(t, breaker.failures)
+		require.False(t, breaker.open)
+	})
+}
+
+func TestProcessOrderWith(t *testing.T) {
+	errService := errors.New("service unavailable")
+	request := service.OrderRequest{ID: "order-1", Amount: 99.99}
+
+	// failingService fails every attempt, counting them
+	failingService := func() (retry.OrderProcessor, *int) {
+		attempts := 0
+		return orderProcessorFunc(func(context.Context, service.OrderRequest) (service.OrderResponse, error) {
+			attempts++
+			return service.OrderResponse{}, errService
+		}), &attempts
+	}
+
+	t.Run("invalid overrides", func(t *testing.T) {
+		svc, attempts := failingService()
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+
+		tests := []struct {
+			name      string
+			overrides retry.Overrides
+			err       string
+		}{
+			{name: "negative max attempts", overrides: retry.Overrides{MaxAttempts: -1}, err: "maxAttempts must be greater than 0"},
+			{name: "negative timeout", overrides: retry.Overrides{Timeout: -time.Second}, err: "timeout must be greater than 0"},
+			{name: "negative initial interval", overrides: retry.Overrides{InitialInterval: -time.Millisecond}, err: "initialInterval must be greater than 0"},
+			{name: "negative max interval", overrides: retry.Overrides{MaxInterval: -time.Millisecond}, err: "maxInterval must be greater than 0"},
+			{name: "negative multiplier", overrides: retry.Overrides{Multiplier: -1}, err: "multiplier must be greater than 0"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := r.ProcessOrderWith(context.Background(), request, tt.overrides)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+			})
+		}
+		require.Zero(t, *attempts)
+	})
+
+	t.Run("max attempts override applies to that call only", func(t *testing.T) {
+		svc, attempts := failingService()
+		r, err := retry.New(svc, 2, time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+
+		_, err = r.ProcessOrderWith(context.Background(), request, retry.Overrides{MaxAttempts: 5})
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, 5, *attempts)
+
+		_, err = r.ProcessOrder(context.Background(), request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, 7, *attempts)
+	})
+
+	t.Run("zero overrides keep the client's configuration", func(t *testing.T) {
+		svc, attempts := failingService()
+		r, err := retry.New(svc, 3, time.Second, time.Millisecond, time.Millisecond, 1)
+		require.NoError(t, err)
+
+		_, err = r.ProcessOrderWith(context.Background(), request, retry.Overrides{})
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, 3, *attempts)
+	})
+
+	t.Run("backoff overrides change the delays", func(t *testing.T) {
+		svc, _ := failingService()
+		var delays []time.Duration
+		r, err := retry.New(svc, 4, time.Second, time.Millisecond, time.Millisecond, 1,
+			retry.WithBackoff(retry.ConstantBackoff{Interval: time.Millisecond}),
+			retry.WithOnRetry(func(_ int, _ error, nextDelay time.Duration) {
+				delays = append(delays, nextDelay)
+			}),
+		)
+		require.NoError(t, err)
+
+		_, err = r.ProcessOrderWith(context.Background(), request, retry.Overrides{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     3 * time.Millisecond,
+			Multiplier:      2,
+		})
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, delays)
+
+		// The client's own strategy is used again afterwards
+		delays = nil
+		_, err = r.ProcessOrder(context.Background(), request)
+		require.ErrorIs(t, err, retry.ErrMaxAttemptsExceeded)
+		require.Equal(t, []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}, delays)
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
 )
 
 // OrderRequest represents an order processing request
@@ -17,6 +19,9 @@ type OrderRequest struct {
 	Amount   float64 `json:"amount"`
 	Currency string  `json:"currency"`
 	Items    []Item  `json:"items"`
+	// IdempotencyKey identifies the logical order across attempts. Repeating a key returns the
+	// response of the order already processed with it instead of processing it again.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // Item represents an order item
@@ -26,6 +31,13 @@ type Item struct {
 	Price     float64 `json:"price"`
 }
 
+// ItemResponse represents the result of processing a single order item, such as reserving its stock
+type ItemResponse struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Status    string `json:"status"`
+}
+
 // OrderResponse represents an order processing response
 type OrderResponse struct {
 	ID          string    `json:"id"`
@@ -38,12 +50,60 @@ type OrderResponse struct {
 
 // orderService simulates an external order processing service
 type orderService struct {
+	delay  time.Duration
+	newID  func() string
+	random func() float64 // Returns a number in [0.0,1.0) used to simulate failures
+	clock  clockwork.Clock
+
+	lock        sync.Mutex // Guards failureRate, which can change while orders are processed, and orders
 	failureRate float64
-	delay       time.Duration
+	orders      map[string]OrderResponse // Processed orders by idempotency key
+}
+
+// Option is a functional option for configuring the order service
+type Option func(*orderService) error
+
+// WithIDGenerator sets a custom generator for order IDs
+func WithIDGenerator(fn func() string) Option {
+	return func(s *orderService) error {
+		if fn == nil {
+			return errors.New("id generator is nil")
+		}
+		s.newID = fn
+		return nil
+	}
+}
+
+// WithRand sets the random source used to simulate failures
+func WithRand(r *rand.Rand) Option {
+	return func(s *orderService) error {
+		if r == nil {
+			return errors.New("rand is nil")
+		}
+		// rand.Rand isn't safe for concurrent use
+		var lock sync.Mutex
+		s.random = func() float64 {
+			lock.Lock()
+			defer lock.Unlock()
+			return r.Float64()
+		}
+		return nil
+	}
+}
+
+// WithClock sets a custom clock for the service
+func WithClock(clock clockwork.Clock) Option {
+	return func(s *orderService) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		s.clock = clock
+		return nil
+	}
 }
 
 // NewOrderService creates a new order service
-func NewOrderService(delay time.Duration, failureRate float64) (*orderService, error) {
+func NewOrderService(delay time.Duration, failureRate float64, opts ...Option) (*orderService, error) {
 	if delay < 0 {
 		return nil, errors.New("delay must be greater than or equal to 0")
 	}
@@ -51,34 +111,83 @@ func NewOrderService(delay time.Duration, failureRate float64) (*orderService, e
 		return nil, errors.New("failure rate must be between 0 and 1")
 	}
 
-	return &orderService{
+	s := &orderService{
 		failureRate: failureRate,
 		delay:       delay,
-	}, nil
+		newID:       func() string { return uuid.New().String() }, // Default to random UUIDs
+		random:      rand.Float64,                                 // Default to the global random source
+		clock:       clockwork.NewRealClock(),                     // Default to real clock
+		orders:      make(map[string]OrderResponse),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
 }
 
-// ProcessOrder processes an order request
+// ProcessOrder processes an order request. Like a real remote service, it carries on with a request whose caller
+// gives up during the delay, so a request that timed out may still have processed the order.
 func (s *orderService) ProcessOrder(ctx context.Context, request OrderRequest) (OrderResponse, error) {
-	// Simulate network delay
+	// A request whose caller has already given up is never sent
+	if err := ctx.Err(); err != nil {
+		return OrderResponse{}, err
+	}
+
+	type result struct {
+		response OrderResponse
+		err      error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		// Simulate network delay
+		<-s.clock.After(s.delay)
+		response, err := s.process(request)
+		resultChan <- result{response, err}
+	}()
+
 	select {
-	case <-time.After(s.delay):
+	case res := <-resultChan:
+		return res.response, res.err
 	case <-ctx.Done():
 		return OrderResponse{}, ctx.Err()
 	}
+}
+
+// process processes an order once it reaches the service. The idempotency key is looked up and stored under
+// one lock, so concurrent requests with the same key process a single order.
+func (s *orderService) process(request OrderRequest) (OrderResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// A repeated key is an order that was already processed, e.g. one whose response was lost to a timeout
+	if request.IdempotencyKey != "" {
+		if response, ok := s.orders[request.IdempotencyKey]; ok {
+			return response, nil
+		}
+	}
 
 	// Simulate failures
-	if rand.Float64() < s.failureRate {
+	if s.random() < s.failureRate {
 		return OrderResponse{}, fmt.Errorf("order processing failed: service unavailable for order %s", request.ID)
 	}
 
 	// Create successful response
 	response := OrderResponse{
 		ID:          request.ID,
-		OrderID:     uuid.New().String(),
+		OrderID:     s.newID(),
 		Status:      "completed",
 		Amount:      request.Amount,
 		Currency:    request.Currency,
-		ProcessedAt: time.Now(),
+		ProcessedAt: s.clock.Now(),
+	}
+
+	if request.IdempotencyKey != "" {
+		s.orders[request.IdempotencyKey] = response
 	}
 
 	return response, nil
@@ -89,6 +198,16 @@ func (s *orderService) SetFailureRate(rate float64) error {
 	if rate < 0 || rate > 1 {
 		return errors.New("failure rate must be between 0 and 1")
 	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	s.failureRate = rate
 	return nil
 }
+
+// Reset sets the failure rate back to 0, e.g. between demos or tests.
+// Orders already processed are still remembered by their idempotency key.
+func (s *orderService) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failureRate = 0
+}
@@ -0,0 +1,294 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cshep4/resiliency-patterns/external-dependency-risk/retry/internal/service"
+)
+
+func TestNewOrderService(t *testing.T) {
+	t.Run("invalid delay", func(t *testing.T) {
+		s, err := service.NewOrderService(-1, 0)
+		require.Error(t, err)
+		require.Nil(t, s)
+	})
+
+	t.Run("invalid failure rate", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 1.5)
+		require.Error(t, err)
+		require.Nil(t, s)
+	})
+
+	t.Run("nil id generator", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0, service.WithIDGenerator(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "id generator is nil")
+	})
+
+	t.Run("nil rand", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0, service.WithRand(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "rand is nil")
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0, service.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, s)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0)
+		require.NoError(t, err)
+		require.NotNil(t, s)
+	})
+}
+
+func TestProcessOrder(t *testing.T) {
+	t.Run("default id generator", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0)
+		require.NoError(t, err)
+
+		response, err := s.ProcessOrder(context.Background(), service.OrderRequest{ID: "order-1"})
+		require.NoError(t, err)
+		require.NotEmpty(t, response.OrderID)
+	})
+
+	t.Run("custom id generator", func(t *testing.T) {
+		var count int
+		s, err := service.NewOrderService(0, 0, service.WithIDGenerator(func() string {
+			count++
+			return fmt.Sprintf("ord-%d", count)
+		}))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		response, err := s.ProcessOrder(ctx, service.OrderRequest{ID: "order-1", Amount: 10, Currency: "USD"})
+		require.NoError(t, err)
+		require.Equal(t, "ord-1", response.OrderID)
+		require.Equal(t, "order-1", response.ID)
+
+		response, err = s.ProcessOrder(ctx, service.OrderRequest{ID: "order-2", Amount: 20, Currency: "USD"})
+		require.NoError(t, err)
+		require.Equal(t, "ord-2", response.OrderID)
+		require.Equal(t, "order-2", response.ID)
+	})
+}
+
+func TestProcessOrderDeterminism(t *testing.T) {
+	t.Run("seeded rand gives a deterministic failure sequence", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0.5, service.WithRand(rand.New(rand.NewSource(1))))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		// Seed 1 yields 0.60, 0.94, 0.66, 0.44, 0.42, 0.69
+		expected := []bool{true, true, true, false, false, true}
+		for i, succeeds := range expected {
+			_, err := s.ProcessOrder(ctx, service.OrderRequest{ID: fmt.Sprintf("order-%d", i)})
+			if succeeds {
+				require.NoError(t, err, "call %d", i)
+			} else {
+				require.Error(t, err, "call %d", i)
+				require.Contains(t, err.Error(), "service unavailable")
+			}
+		}
+	})
+
+	t.Run("delay is measured on the injected clock", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		s, err := service.NewOrderService(time.Second, 0, service.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		type result struct {
+			response service.OrderResponse
+			err      error
+		}
+		resultChan := make(chan result)
+		go func() {
+			response, err := s.ProcessOrder(ctx, service.OrderRequest{ID: "order-1"})
+			resultChan <- result{response, err}
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(time.Second)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, fakeClock.Now(), res.response.ProcessedAt)
+	})
+
+	t.Run("context cancelled during delay", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		s, err := service.NewOrderService(time.Second, 0, service.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			_, err := s.ProcessOrder(ctx, service.OrderRequest{ID: "order-1"})
+			errChan <- err
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		cancel()
+
+		require.ErrorIs(t, <-errChan, context.Canceled)
+	})
+}
+
+func TestProcessOrderIdempotency(t *testing.T) {
+	// countingIDs generates sequential order IDs, counting the orders processed
+	countingIDs := func() (service.Option, *int) {
+		count := new(int)
+		return service.WithIDGenerator(func() string {
+			*count++
+			return fmt.Sprintf("ord-%d", *count)
+		}), count
+	}
+
+	t.Run("repeated key returns the processed order", func(t *testing.T) {
+		ids, count := countingIDs()
+		s, err := service.NewOrderService(0, 0, ids)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1", Amount: 10, Currency: "USD", IdempotencyKey: "key-1"}
+
+		first, err := s.ProcessOrder(ctx, request)
+		require.NoError(t, err)
+
+		second, err := s.ProcessOrder(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+		require.Equal(t, 1, *count)
+	})
+
+	t.Run("distinct keys are processed separately", func(t *testing.T) {
+		ids, count := countingIDs()
+		s, err := service.NewOrderService(0, 0, ids)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		first, err := s.ProcessOrder(ctx, service.OrderRequest{ID: "order-1", IdempotencyKey: "key-1"})
+		require.NoError(t, err)
+
+		second, err := s.ProcessOrder(ctx, service.OrderRequest{ID: "order-1", IdempotencyKey: "key-2"})
+		require.NoError(t, err)
+		require.NotEqual(t, first.OrderID, second.OrderID)
+		require.Equal(t, 2, *count)
+	})
+
+	t.Run("timeout after success yields a single order", func(t *testing.T) {
+		ids, count := countingIDs()
+		fakeClock := clockwork.NewFakeClock()
+		s, err := service.NewOrderService(time.Second, 0, ids, service.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		request := service.OrderRequest{ID: "order-1", IdempotencyKey: "key-1"}
+
+		// The caller gives up before the response arrives, but the service still processes the order
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error)
+		go func() {
+			_, err := s.ProcessOrder(ctx, request)
+			errChan <- err
+		}()
+		fakeClock.BlockUntilContext(context.Background(), 1)
+		cancel()
+		require.ErrorIs(t, <-errChan, context.Canceled)
+
+		// The retry is in flight alongside the abandoned request, and both reach the service together
+		type result struct {
+			response service.OrderResponse
+			err      error
+		}
+		resultChan := make(chan result)
+		go func() {
+			response, err := s.ProcessOrder(context.Background(), request)
+			resultChan <- result{response, err}
+		}()
+		fakeClock.BlockUntilContext(context.Background(), 2)
+		fakeClock.Advance(time.Second)
+
+		res := <-resultChan
+		require.NoError(t, res.err)
+		require.Equal(t, "ord-1", res.response.OrderID)
+		require.Equal(t, 1, *count)
+	})
+
+	t.Run("requests without a key are always processed", func(t *testing.T) {
+		ids, count := countingIDs()
+		s, err := service.NewOrderService(0, 0, ids)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		request := service.OrderRequest{ID: "order-1"}
+
+		_, err = s.ProcessOrder(ctx, request)
+		require.NoError(t, err)
+		_, err = s.ProcessOrder(ctx, request)
+		require.NoError(t, err)
+		require.Equal(t, 2, *count)
+	})
+}
+
+func TestReset(t *testing.T) {
+	t.Run("reset stops failures", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 1)
+		require.NoError(t, err)
+
+		s.Reset()
+
+		for i := range 10 {
+			_, err := s.ProcessOrder(context.Background(), service.OrderRequest{ID: fmt.Sprintf("order-%d", i), Amount: 99.99})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("failure rate can change while orders are processed", func(t *testing.T) {
+		s, err := service.NewOrderService(0, 0.5)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					_, _ = s.ProcessOrder(context.Background(), service.OrderRequest{ID: fmt.Sprintf("order-%d-%d", i, j), Amount: 99.99})
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					if err := s.SetFailureRate(float64(j % 2)); err != nil {
+						t.Error(err)
+					}
+					if j%10 == 0 {
+						s.Reset()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		s.Reset()
+		_, err = s.ProcessOrder(context.Background(), service.OrderRequest{ID: "order-final", Amount: 99.99})
+		require.NoError(t, err)
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -10,24 +11,22 @@ import (
 
 	"golang.org/x/sync/errgroup"
 
-	leaderelection "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection/file"
+	"github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection"
+	fileelection "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection/file"
+	k8selection "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection/kubernetes"
 )
 
-// LeaderElector is an interface that defines the methods for leader election
-// implementations of the leader election pattern can be found in the
-// internal/leaderelection package.
-type LeaderElector interface {
-	AcquireLease(ctx context.Context)
-	MonitorLease(ctx context.Context, onShutdown func())
-}
-
 func main() {
+	backend := flag.String("backend", "file", "leader election backend to use (file or kubernetes)")
+	namespace := flag.String("namespace", "default", "namespace for the Kubernetes lease")
+	flag.Parse()
+
 	nodeID := fmt.Sprintf("%d", os.Getpid())
 
 	log.Printf("Starting leader election demo for node: %s", nodeID)
 	log.Printf("💡 Tip: Run multiple instances to see leader election in action")
 
-	elector, err := leaderelection.NewLeaderElector(nodeID)
+	elector, err := newLeaderElector(*backend, nodeID, *namespace)
 	if err != nil {
 		log.Fatalf("Failed to create leader elector: %v", err)
 	}
@@ -72,6 +71,26 @@ func main() {
 	log.Printf("👋 [%s] Shutdown complete", nodeID)
 }
 
+// newLeaderElector creates the leader elector for the chosen backend
+func newLeaderElector(backend, nodeID, namespace string) (leaderelection.LeaderElector, error) {
+	switch backend {
+	case "file":
+		return fileelection.NewLeaderElector(nodeID, fileelection.WithLogger(stdLogger{}))
+	case "kubernetes":
+		return k8selection.NewLeaderElector(nodeID, namespace)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+}
+
+// stdLogger writes the leader elector's logs with the standard library logger
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...any) { log.Printf(format, args...) }
+func (stdLogger) Infof(format string, args ...any)  { log.Printf(format, args...) }
+func (stdLogger) Warnf(format string, args ...any)  { log.Printf(format, args...) }
+func (stdLogger) Errorf(format string, args ...any) { log.Printf(format, args...) }
+
 // workerProcess is an example of a service implementation that must be run in an active/passive manner.
 func workerProcess(ctx context.Context, nodeID string) error {
 	ticker := time.NewTicker(1 * time.Second)
@@ -4,62 +4,240 @@ package leaderelection
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	election "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection"
 )
 
 const (
-	// leaseDuration is how long a leadership lease is valid
-	leaseDuration = 10 * time.Second
-	// retryPeriod is how often to retry acquiring leadership
-	retryPeriod = 2 * time.Second
-	// lockName is the base name for the lock file
-	lockName = "leader-election-demo"
-	// lockDir is the directory where lock files are stored
-	lockDir = "/tmp"
+	// defaultLeaseDuration is how long a leadership lease is valid
+	defaultLeaseDuration = 10 * time.Second
+	// defaultRenewDeadline is how long the leader keeps failing to renew before giving up leadership
+	defaultRenewDeadline = 8 * time.Second
+	// defaultRetryPeriod is how often to retry acquiring leadership
+	defaultRetryPeriod = 2 * time.Second
+	// defaultLockName is the base name for the lock file
+	defaultLockName = "leader-election-demo"
+	// defaultLockDir is the directory where lock files are stored
+	defaultLockDir = "/tmp"
 )
 
+// Config configures the lease timings and lock file location
+type Config struct {
+	// LeaseDuration is how long a leadership lease is valid
+	LeaseDuration time.Duration
+	// RenewDeadline is how long since the last successful renewal the leader keeps failing to renew before giving up
+	// leadership. Renewals start halfway through the lease, so it must be greater than half of LeaseDuration and
+	// less than LeaseDuration. Zero disables the deadline, holding leadership until the lease expires.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often to retry acquiring leadership, it must be less than LeaseDuration
+	RetryPeriod time.Duration
+	// LockName is the base name for the lock file
+	LockName string
+	// LockDir is the directory where lock files are stored
+	LockDir string
+}
+
+// DefaultConfig returns the configuration used by NewLeaderElector
+func DefaultConfig() Config {
+	return Config{
+		LeaseDuration: defaultLeaseDuration,
+		RenewDeadline: defaultRenewDeadline,
+		RetryPeriod:   defaultRetryPeriod,
+		LockName:      defaultLockName,
+		LockDir:       defaultLockDir,
+	}
+}
+
+// errLeaseLost is returned when renewing a lease another node has taken over
+var errLeaseLost = errors.New("lease is no longer held")
+
+// Ensure leaderElector satisfies the shared interface
+var _ election.LeaderElector = (*leaderElector)(nil)
+
 // leaderElector manages leader election using file-based locking
 type leaderElector struct {
 	// identity is the unique identifier for this node
 	identity string
 	// lockFile is the full path to the lock file used for leader election
 	lockFile string
+	// leaseDuration is how long a leadership lease is valid
+	leaseDuration time.Duration
+	// renewDeadline is how long renewals may fail before giving up leadership, zero means no deadline
+	renewDeadline time.Duration
+	// retryPeriod is how often to retry acquiring leadership
+	retryPeriod time.Duration
+	// clock is used for lease timestamps and timers
+	clock clockwork.Clock
+	// logger receives progress and failures of the election
+	logger Logger
+	// token is the fencing token of our most recent acquisition
+	token atomic.Uint64
+	// callbacks are notified of leadership changes
+	callbacks LeaderCallbacks
+
+	// observedLock guards observedLeader
+	observedLock sync.Mutex
+	// observedLeader is the last lease owner seen in the lock file
+	observedLeader string
 }
 
-// NewLeaderElector creates a new leaderElector instance with the given node ID
-func NewLeaderElector(nodeID string) (*leaderElector, error) {
-	if nodeID == "" {
-		return nil, fmt.Errorf("nodeID is required")
+// LeaderCallbacks are notified of leadership changes, any of them may be nil
+type LeaderCallbacks struct {
+	// OnStartedLeading is called when this node acquires leadership
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called when this node stops leading, either because the lease was lost or monitoring stopped
+	OnStoppedLeading func()
+	// OnNewLeader is called whenever the observed lease owner changes, including while this node is a follower
+	OnNewLeader func(identity string)
+}
+
+// lease is the content of the lock file
+type lease struct {
+	// identity is the node holding the lease
+	identity string
+	// token is the fencing token issued when the lease was acquired
+	token uint64
+	// renewed is when the lease was acquired or last renewed
+	renewed time.Time
+}
+
+// released is the renewal time of a tombstone, a lease its owner gave up which every node sees as expired
+var released = time.Unix(0, 0)
+
+// tombstone returns a released copy of the lease, keeping its owner and fencing token
+func (l lease) tombstone() lease {
+	l.renewed = released
+	return l
+}
+
+// isTombstone reports whether the lease was released by its owner rather than left to expire
+func (l lease) isTombstone() bool {
+	return l.renewed.Equal(released)
+}
+
+// String encodes the lease in the format "identity:token:timestamp"
+func (l lease) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.identity, l.token, l.renewed.Unix())
+}
+
+// Option is a functional option for configuring the leader elector
+type Option func(*leaderElector) error
+
+// WithLockFile sets a custom path for the lock file
+func WithLockFile(path string) Option {
+	return func(le *leaderElector) error {
+		if path == "" {
+			return errors.New("lock file path is required")
+		}
+		le.lockFile = path
+		return nil
+	}
+}
+
+// WithClock sets a custom clock for the leader elector
+func WithClock(clock clockwork.Clock) Option {
+	return func(le *leaderElector) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		le.clock = clock
+		return nil
+	}
+}
+
+// WithLogger sets the logger the leader elector reports election progress and failures to
+func WithLogger(logger Logger) Option {
+	return func(le *leaderElector) error {
+		if logger == nil {
+			return errors.New("logger is nil")
+		}
+		le.logger = logger
+		return nil
 	}
+}
+
+// WithCallbacks sets the callbacks notified of leadership changes
+func WithCallbacks(callbacks LeaderCallbacks) Option {
+	return func(le *leaderElector) error {
+		le.callbacks = callbacks
+		return nil
+	}
+}
 
-	// Construct the full path to the lock file
-	lockFile := filepath.Join(lockDir, fmt.Sprintf("%s.lock", lockName))
+// NewLeaderElector creates a new leaderElector instance with the given node ID and the default configuration
+func NewLeaderElector(nodeID string, opts ...Option) (*leaderElector, error) {
+	return NewLeaderElectorWithConfig(nodeID, DefaultConfig(), opts...)
+}
 
-	return &leaderElector{
+// NewLeaderElectorWithConfig creates a new leaderElector instance with the given node ID and configuration
+func NewLeaderElectorWithConfig(nodeID string, cfg Config, opts ...Option) (*leaderElector, error) {
+	switch {
+	case nodeID == "":
+		return nil, fmt.Errorf("nodeID is required")
+	case cfg.LeaseDuration <= 0:
+		return nil, errors.New("lease duration must be greater than 0")
+	case cfg.RenewDeadline < 0:
+		return nil, errors.New("renew deadline cannot be negative")
+	case cfg.RenewDeadline > 0 && cfg.RenewDeadline <= cfg.LeaseDuration/2:
+		return nil, errors.New("renew deadline must be greater than half of lease duration")
+	case cfg.RenewDeadline >= cfg.LeaseDuration:
+		return nil, errors.New("renew deadline must be less than lease duration")
+	case cfg.RetryPeriod <= 0:
+		return nil, errors.New("retry period must be greater than 0")
+	case cfg.RetryPeriod >= cfg.LeaseDuration:
+		return nil, errors.New("retry period must be less than lease duration")
+	case cfg.LockName == "":
+		return nil, errors.New("lock name is required")
+	case cfg.LockDir == "":
+		return nil, errors.New("lock dir is required")
+	}
+
+	le := &leaderElector{
 		identity: nodeID,
-		lockFile: lockFile,
-	}, nil
+		// Construct the full path to the lock file
+		lockFile:      filepath.Join(cfg.LockDir, fmt.Sprintf("%s.lock", cfg.LockName)),
+		leaseDuration: cfg.LeaseDuration,
+		renewDeadline: cfg.RenewDeadline,
+		retryPeriod:   cfg.RetryPeriod,
+		clock:         clockwork.NewRealClock(), // Default to real clock
+		logger:        noopLogger{},
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(le); err != nil {
+			return nil, err
+		}
+	}
+
+	return le, nil
 }
 
 // AcquireLease attempts to acquire leadership by creating a lock file
 // It will block and keep retrying until successful or the context is cancelled
 func (le *leaderElector) AcquireLease(ctx context.Context) error {
-	log.Printf("[%s] Attempting to acquire leadership...", le.identity)
+	le.logger.Infof("[%s] Attempting to acquire leadership...", le.identity)
 
 	// Try once immediately to avoid unnecessary delay
 	if le.tryAcquireLease() {
-		log.Printf("🎉 [%s] Successfully acquired leadership!", le.identity)
+		le.logger.Infof("🎉 [%s] Successfully acquired leadership!", le.identity)
+		le.startedLeading(ctx)
 		return nil
 	}
 
 	// If not successful, use ticker for periodic retries
-	ticker := time.NewTicker(retryPeriod)
+	ticker := le.clock.NewTicker(le.retryPeriod)
 	defer ticker.Stop()
 
 	// Keep trying until we acquire leadership or context is cancelled
@@ -68,10 +246,11 @@ func (le *leaderElector) AcquireLease(ctx context.Context) error {
 		case <-ctx.Done():
 			// Context cancelled, stop trying
 			return ctx.Err()
-		case <-ticker.C:
+		case <-ticker.Chan():
 			// Time for another attempt
 			if le.tryAcquireLease() {
-				log.Printf("🎉 [%s] Successfully acquired leadership!", le.identity)
+				le.logger.Infof("🎉 [%s] Successfully acquired leadership!", le.identity)
+				le.startedLeading(ctx)
 				return nil
 			}
 		}
@@ -81,14 +260,34 @@ func (le *leaderElector) AcquireLease(ctx context.Context) error {
 // tryAcquireLease attempts to acquire the leadership lease
 // Returns true if successful, false otherwise
 func (le *leaderElector) tryAcquireLease() bool {
+	// Serialise the check-and-replace below across nodes so an expired lease is only reclaimed once
+	unlock, err := le.lockGuard()
+	if err != nil {
+		return false
+	}
+	defer unlock()
+
 	// Check if lock file already exists
 	if _, err := os.Stat(le.lockFile); err == nil {
 		// Lock file exists, check if it's expired
 		if !le.isLeaseExpired() {
 			// Lease is still valid, cannot acquire
+			le.observeLeader()
 			return false
 		}
-		log.Printf("[%s] Found expired lease, attempting to acquire", le.identity)
+		le.logger.Infof("[%s] Found expired lease, attempting to acquire", le.identity)
+
+		// Remove the stale lease so it can be recreated below, no other node can
+		// write a fresh lease while we hold the guard
+		if err := os.Remove(le.lockFile); err != nil && !os.IsNotExist(err) {
+			return false
+		}
+	}
+
+	// Issue a new fencing token, higher than any issued before
+	token, err := le.nextToken()
+	if err != nil {
+		return false
 	}
 
 	// Try to create the lock file atomically using O_EXCL
@@ -100,163 +299,324 @@ func (le *leaderElector) tryAcquireLease() bool {
 	}
 	defer file.Close()
 
-	// Write our identity and timestamp to the lock file
-	leaseData := fmt.Sprintf("%s:%d", le.identity, time.Now().Unix())
-	if _, err := file.WriteString(leaseData); err != nil {
+	// Write our identity, token and timestamp to the lock file
+	leaseData := lease{identity: le.identity, token: token, renewed: le.clock.Now()}
+	if _, err := file.WriteString(leaseData.String()); err != nil {
 		// Failed to write data, clean up the file
 		os.Remove(le.lockFile)
 		return false
 	}
 
 	// Successfully acquired the lease
+	le.token.Store(token)
 	return true
 }
 
-// isLeaseExpired checks if the current lease has expired
-// Returns true if expired or if there's any error reading the lease
-func (le *leaderElector) isLeaseExpired() bool {
-	// Try to read the lock file
-	data, err := os.ReadFile(le.lockFile)
+// lockGuard takes an exclusive lock serialising changes to the lock file across nodes.
+// The advisory lock is released by the OS if this process dies while holding it.
+func (le *leaderElector) lockGuard() (func(), error) {
+	guard, err := os.OpenFile(le.lockFile+".guard", os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
-		// Cannot read file, consider it expired
-		return true
+		return nil, err
+	}
+	if err := lockFile(guard); err != nil {
+		guard.Close()
+		return nil, err
 	}
 
-	// Parse the lease data format: "identity:timestamp"
-	parts := strings.Split(string(data), ":")
-	if len(parts) != 2 {
-		// Invalid format, consider it expired
-		return true
+	return func() {
+		unlockFile(guard)
+		guard.Close()
+	}, nil
+}
+
+// Release steps down by replacing the lease with a tombstone so another node can acquire leadership immediately.
+// The tombstone is always seen as expired, whereas removing the lock file could race with this node recreating
+// it and look to followers like no change. It does nothing if this node doesn't hold a valid lease.
+func (le *leaderElector) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Parse the timestamp
-	timestamp, err := strconv.ParseInt(parts[1], 10, 64)
+	unlock, err := le.lockGuard()
 	if err != nil {
-		// Invalid timestamp, consider it expired
+		return fmt.Errorf("failed to lock lease: %w", err)
+	}
+	defer unlock()
+
+	// Never remove a lease another node has taken over
+	if !le.isCurrentLeader() {
+		return nil
+	}
+
+	l, err := le.readLease()
+	if err != nil {
+		return fmt.Errorf("failed to read lease: %w", err)
+	}
+	if err := le.writeLease(l.tombstone()); err != nil {
+		return fmt.Errorf("failed to write tombstone: %w", err)
+	}
+
+	le.logger.Infof("[%s] Released leadership", le.identity)
+	return nil
+}
+
+// nextToken increments and returns the fencing token counter.
+// The counter lives outside the lock file so it survives the lease being removed,
+// it must only be called while holding the guard.
+func (le *leaderElector) nextToken() (uint64, error) {
+	tokenFile := le.lockFile + ".token"
+
+	var token uint64
+	data, err := os.ReadFile(tokenFile)
+	switch {
+	case err == nil:
+		token, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid fencing token: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return 0, err
+	}
+
+	token++
+	if err := os.WriteFile(tokenFile, []byte(strconv.FormatUint(token, 10)), 0644); err != nil {
+		return 0, err
+	}
+
+	return token, nil
+}
+
+// FencingToken returns the token issued by our most recent acquisition, or 0 if we have never led.
+// Resources should reject writes carrying a token lower than the highest they have seen.
+func (le *leaderElector) FencingToken() uint64 {
+	return le.token.Load()
+}
+
+// IsLeader reports whether this node currently holds a valid lease
+func (le *leaderElector) IsLeader() bool {
+	return le.isCurrentLeader()
+}
+
+// isLeaseExpired checks if the current lease has expired
+// Returns true if expired or if there's any error reading the lease
+func (le *leaderElector) isLeaseExpired() bool {
+	l, err := le.readLease()
+	if err != nil {
+		// Cannot read or parse the lease, consider it expired
 		return true
 	}
 
-	// Check if the lease duration has passed
-	leaseTime := time.Unix(timestamp, 0)
-	return time.Since(leaseTime) > leaseDuration
+	// A released lease is available straight away, otherwise check if the lease duration has passed
+	return l.isTombstone() || le.clock.Since(l.renewed) > le.leaseDuration
 }
 
 // MonitorLease continuously monitors the leadership status and renews the lease
-// Calls onShutdown if leadership is lost and cleans up the lock file
+// Calls onShutdown if leadership is lost or can't be renewed before the renew deadline,
+// and releases the lease when the context is cancelled
 func (le *leaderElector) MonitorLease(ctx context.Context, onShutdown func()) {
 	// Check lease status every second
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := le.clock.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	log.Printf("[%s] Starting lease monitoring...", le.identity)
+	le.logger.Infof("[%s] Starting lease monitoring...", le.identity)
 
 	for {
 		select {
 		case <-ctx.Done():
 			// Context cancelled, stop monitoring and clean up
-			log.Printf("[%s] Lease monitoring stopped", le.identity)
-			err := os.Remove(le.lockFile)
-			if err != nil {
-				log.Printf("[%s] Error removing lock file: %v", le.identity, err)
+			le.logger.Infof("[%s] Lease monitoring stopped", le.identity)
+			le.stoppedLeading()
+			// The monitoring context is already done, release with a fresh one
+			if err := le.Release(context.WithoutCancel(ctx)); err != nil {
+				le.logger.Errorf("[%s] Error releasing lease: %v", le.identity, err)
 			}
 			return
-		case <-ticker.C:
+		case <-ticker.Chan():
 			// Regular lease check
 			if !le.isCurrentLeader() {
-				// We're no longer the leader, shut down gracefully
-				log.Printf("🚨 [%s] Lease lost! Shutting down...", le.identity)
-				onShutdown()
-
-				// Clean up the lock file
-				err := os.Remove(le.lockFile)
-				if err != nil {
-					log.Printf("[%s] Error removing lock file: %v", le.identity, err)
-				}
+				le.leaseLost(onShutdown)
 				return
 			}
 
 			// Renew the lease if it's time to do so
 			if le.shouldRenewLease() {
 				if err := le.renewLease(); err != nil {
-					log.Printf("[%s] Failed to renew lease: %v", le.identity, err)
+					if errors.Is(err, errLeaseLost) {
+						// Taken over since the check above, never overwrite the new leader's lease
+						le.leaseLost(onShutdown)
+						return
+					}
+
+					le.logger.Warnf("[%s] Failed to renew lease: %v", le.identity, err)
+
+					if le.renewDeadlineExceeded() {
+						// Step down before the lease expires so another node can't lead alongside us
+						le.logger.Errorf("🚨 [%s] Failed to renew lease before the deadline! Shutting down...", le.identity)
+						le.stoppedLeading()
+						onShutdown()
+						return
+					}
 				}
 			}
 		}
 	}
 }
 
+// leaseLost steps down after another node took over or the lease expired
+func (le *leaderElector) leaseLost(onShutdown func()) {
+	// We're no longer the leader, shut down gracefully
+	le.logger.Warnf("🚨 [%s] Lease lost! Shutting down...", le.identity)
+	le.observeLeader()
+	le.stoppedLeading()
+	onShutdown()
+	// The lock file now belongs to another node, or has expired and will be reclaimed, so leave it in place
+}
+
+// startedLeading records this node as the leader and notifies the callbacks
+func (le *leaderElector) startedLeading(ctx context.Context) {
+	le.observe(le.identity)
+	if le.callbacks.OnStartedLeading != nil {
+		le.callbacks.OnStartedLeading(ctx)
+	}
+}
+
+// stoppedLeading notifies the callbacks that this node is no longer leading
+func (le *leaderElector) stoppedLeading() {
+	if le.callbacks.OnStoppedLeading != nil {
+		le.callbacks.OnStoppedLeading()
+	}
+}
+
+// observeLeader reads the current lease owner and notifies the callbacks if it changed
+func (le *leaderElector) observeLeader() {
+	l, err := le.readLease()
+	if err != nil || l.isTombstone() {
+		// No readable or held lease, there is no leader to observe
+		return
+	}
+	le.observe(l.identity)
+}
+
+// observe notifies the callbacks if identity differs from the last observed leader
+func (le *leaderElector) observe(identity string) {
+	le.observedLock.Lock()
+	changed := identity != le.observedLeader
+	le.observedLeader = identity
+	le.observedLock.Unlock()
+
+	if changed {
+		le.logger.Infof("👥 [%s] New leader elected: %s", le.identity, identity)
+		if le.callbacks.OnNewLeader != nil {
+			le.callbacks.OnNewLeader(identity)
+		}
+	}
+}
+
 // isCurrentLeader checks if this node is currently the leader
 // Returns true if we own the lease and it's still valid
 func (le *leaderElector) isCurrentLeader() bool {
-	// Read the current lock file
-	data, err := os.ReadFile(le.lockFile)
+	l, err := le.readLease()
 	if err != nil {
-		// Cannot read file, we're not the leader
+		// Cannot read or parse the lease, we're not the leader
 		return false
 	}
 
-	// Parse the lease data format: "identity:timestamp"
-	parts := strings.Split(string(data), ":")
-	if len(parts) != 2 {
-		// Invalid format, we're not the leader
-		return false
-	}
-
-	// Check if we own the lease
-	if parts[0] != le.identity {
+	// Check if we own the lease, a different token means it was reacquired since we took it
+	if l.identity != le.identity || l.token != le.token.Load() || l.isTombstone() {
 		// Someone else owns the lease
 		return false
 	}
 
 	// Check if our lease is still valid (not expired)
-	timestamp, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		// Invalid timestamp, we're not the leader
-		return false
-	}
-
-	leaseTime := time.Unix(timestamp, 0)
-	return time.Since(leaseTime) <= leaseDuration
+	return le.clock.Since(l.renewed) <= le.leaseDuration
 }
 
 // shouldRenewLease determines if it's time to renew the leadership lease
 // Returns true if we should renew (when halfway through lease duration)
 func (le *leaderElector) shouldRenewLease() bool {
-	// Read the current lock file to get the last renewal time
-	data, err := os.ReadFile(le.lockFile)
+	l, err := le.readLease()
 	if err != nil {
-		// Cannot read file, cannot renew
+		// Cannot read or parse the lease, cannot renew
 		return false
 	}
 
-	// Parse the lease data format: "identity:timestamp"
-	parts := strings.Split(string(data), ":")
-	if len(parts) != 2 {
-		// Invalid format, cannot renew
+	// Renew when we're halfway through the lease duration
+	// This provides a safety margin before the lease expires
+	return le.clock.Since(l.renewed) > le.leaseDuration/2
+}
+
+// renewDeadlineExceeded reports whether renewals have been failing for longer than the renew deadline
+func (le *leaderElector) renewDeadlineExceeded() bool {
+	if le.renewDeadline == 0 {
 		return false
 	}
 
-	// Parse the last renewal timestamp
-	timestamp, err := strconv.ParseInt(parts[1], 10, 64)
+	l, err := le.readLease()
 	if err != nil {
-		// Invalid timestamp, cannot renew
-		return false
+		// Cannot read or parse the lease, treat it as past the deadline
+		return true
+	}
+	return le.clock.Since(l.renewed) > le.renewDeadline
+}
+
+// readLease reads and parses the lock file
+func (le *leaderElector) readLease() (lease, error) {
+	data, err := os.ReadFile(le.lockFile)
+	if err != nil {
+		return lease{}, err
 	}
+	return parseLease(string(data))
+}
 
-	// Calculate time since last renewal
-	leaseTime := time.Unix(timestamp, 0)
-	timeSinceRenewal := time.Since(leaseTime)
+// parseLease parses lease data in the format "identity:token:timestamp".
+// The token and timestamp follow the last two colons so identities may themselves contain colons (e.g. host:port).
+func parseLease(data string) (lease, error) {
+	i := strings.LastIndex(data, ":")
+	if i <= 0 {
+		return lease{}, fmt.Errorf("invalid lease format: %q", data)
+	}
+	j := strings.LastIndex(data[:i], ":")
+	if j <= 0 {
+		return lease{}, fmt.Errorf("invalid lease format: %q", data)
+	}
 
-	// Renew when we're halfway through the lease duration
-	// This provides a safety margin before the lease expires
-	return timeSinceRenewal > leaseDuration/2
+	token, err := strconv.ParseUint(data[j+1:i], 10, 64)
+	if err != nil {
+		return lease{}, fmt.Errorf("invalid lease token: %w", err)
+	}
+	timestamp, err := strconv.ParseInt(data[i+1:], 10, 64)
+	if err != nil {
+		return lease{}, fmt.Errorf("invalid lease timestamp: %w", err)
+	}
+
+	return lease{identity: data[:j], token: token, renewed: time.Unix(timestamp, 0)}, nil
 }
 
 // renewLease updates the lease timestamp to extend our leadership
-// Returns an error if the renewal fails
+// Returns errLeaseLost if another node now owns the lease, or an error if the renewal fails
 func (le *leaderElector) renewLease() error {
-	// Create new lease data with current timestamp
-	leaseData := fmt.Sprintf("%s:%d", le.identity, time.Now().Unix())
-	// Atomically update the lock file with new timestamp
-	return os.WriteFile(le.lockFile, []byte(leaseData), 0644)
+	// Hold the guard so the lease can't change hands between checking and replacing it
+	unlock, err := le.lockGuard()
+	if err != nil {
+		return fmt.Errorf("failed to lock lease: %w", err)
+	}
+	defer unlock()
+
+	if !le.isCurrentLeader() {
+		return errLeaseLost
+	}
+
+	// Create new lease data with current timestamp, keeping our fencing token
+	return le.writeLease(lease{identity: le.identity, token: le.token.Load(), renewed: le.clock.Now()})
+}
+
+// writeLease atomically replaces the lock file with l by renaming a fully written copy over it.
+// It must only be called while holding the guard.
+func (le *leaderElector) writeLease(l lease) error {
+	tmpFile := le.lockFile + ".tmp"
+	if err := os.WriteFile(tmpFile, []byte(l.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, le.lockFile)
 }
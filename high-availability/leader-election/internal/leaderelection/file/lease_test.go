@@ -0,0 +1,669 @@
+package leaderelection_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	leaderelection "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection/file"
+)
+
+// writeLease writes a lease owned by identity that was last renewed at renewed
+func writeLease(t *testing.T, path, identity string, renewed time.Time) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(fmt.Sprintf("%s:0:%d", identity, renewed.Unix())), 0644)
+	require.NoError(t, err)
+}
+
+func TestNewLeaderElector(t *testing.T) {
+	t.Run("empty node ID", func(t *testing.T) {
+		le, err := leaderelection.NewLeaderElector("")
+		require.Error(t, err)
+		require.Nil(t, le)
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithClock(nil))
+		require.Error(t, err)
+		require.Nil(t, le)
+		require.Contains(t, err.Error(), "clock is nil")
+	})
+
+	t.Run("empty lock file", func(t *testing.T) {
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(""))
+		require.Error(t, err)
+		require.Nil(t, le)
+		require.Contains(t, err.Error(), "lock file path is required")
+	})
+}
+
+func TestAcquireLease(t *testing.T) {
+	t.Run("acquires when no lease exists", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+
+		err = le.AcquireLease(context.Background())
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(lockFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "node-1:")
+	})
+
+	t.Run("does not acquire a valid lease", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		writeLease(t, lockFile, "node-0", time.Now())
+
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err = le.AcquireLease(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("reclaims a lease once it expires", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		writeLease(t, lockFile, "node-0", fakeClock.Now())
+
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		errChan := make(chan error)
+		go func() {
+			errChan <- le.AcquireLease(ctx)
+		}()
+
+		// The first attempt fails while the lease is valid, the next retry after expiry succeeds
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(leaderelection.DefaultConfig().LeaseDuration + time.Second)
+
+		require.NoError(t, <-errChan)
+
+		data, err := os.ReadFile(lockFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "node-1:")
+	})
+
+	t.Run("exactly one node reclaims an expired lease", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		writeLease(t, lockFile, "node-0", time.Now().Add(-time.Minute))
+
+		const nodes = 10
+		var wg sync.WaitGroup
+		errs := make([]error, nodes)
+		for i := 0; i < nodes; i++ {
+			le, err := leaderelection.NewLeaderElector(fmt.Sprintf("node-%d", i+1), leaderelection.WithLockFile(lockFile))
+			require.NoError(t, err)
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				// Shorter than the retry period so each node gets a single attempt
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+				errs[i] = le.AcquireLease(ctx)
+			}(i)
+		}
+		wg.Wait()
+
+		var winners int
+		for _, err := range errs {
+			if err == nil {
+				winners++
+			}
+		}
+		require.Equal(t, 1, winners)
+	})
+}
+
+func TestColonIdentities(t *testing.T) {
+	for _, identity := range []string{"10.0.0.1:8080", "[::1]:8080", "fe80::1"} {
+		t.Run(identity, func(t *testing.T) {
+			t.Run("valid lease is not treated as expired", func(t *testing.T) {
+				lockFile := filepath.Join(t.TempDir(), "test.lock")
+				writeLease(t, lockFile, identity, time.Now())
+
+				le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+				require.NoError(t, err)
+
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				err = le.AcquireLease(ctx)
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			})
+
+			t.Run("leader keeps and renews its lease while monitoring", func(t *testing.T) {
+				fakeClock := clockwork.NewFakeClock()
+				lockFile := filepath.Join(t.TempDir(), "test.lock")
+				le, err := leaderelection.NewLeaderElector(identity, leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+				require.NoError(t, err)
+
+				err = le.AcquireLease(context.Background())
+				require.NoError(t, err)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				var lost bool
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					le.MonitorLease(ctx, func() { lost = true })
+				}()
+
+				// Past the renewal point but within the lease
+				fakeClock.BlockUntilContext(ctx, 1)
+				fakeClock.Advance(6 * time.Second)
+
+				renewed := fmt.Sprintf("%s:%d:%d", identity, le.FencingToken(), fakeClock.Now().Unix())
+				require.Eventually(t, func() bool {
+					data, err := os.ReadFile(lockFile)
+					return err == nil && string(data) == renewed
+				}, time.Second, time.Millisecond)
+
+				cancel()
+				<-done
+				require.False(t, lost)
+			})
+		})
+	}
+}
+
+func TestFencingToken(t *testing.T) {
+	t.Run("no token before acquiring", func(t *testing.T) {
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(filepath.Join(t.TempDir(), "test.lock")))
+		require.NoError(t, err)
+
+		require.Zero(t, le.FencingToken())
+		require.False(t, le.IsLeader())
+	})
+
+	t.Run("tokens strictly increase across handovers", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		node1, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+		node2, err := leaderelection.NewLeaderElector("node-2", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		// node-1 leads first
+		require.NoError(t, node1.AcquireLease(ctx))
+		require.True(t, node1.IsLeader())
+		first := node1.FencingToken()
+		require.Positive(t, first)
+
+		// node-1 steps down and node-2 takes over
+		require.NoError(t, os.Remove(lockFile))
+		require.NoError(t, node2.AcquireLease(ctx))
+		second := node2.FencingToken()
+		require.Greater(t, second, first)
+		require.True(t, node2.IsLeader())
+		require.False(t, node1.IsLeader())
+
+		// node-2 pauses past its lease and node-1 reclaims it
+		fakeClock.Advance(leaderelection.DefaultConfig().LeaseDuration + time.Second)
+		require.NoError(t, node1.AcquireLease(ctx))
+		third := node1.FencingToken()
+		require.Greater(t, third, second)
+		require.True(t, node1.IsLeader())
+		require.False(t, node2.IsLeader())
+	})
+
+	t.Run("same identity with a stale token is not leader", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		paused, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+		restarted, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+
+		require.NoError(t, paused.AcquireLease(ctx))
+		require.NoError(t, os.Remove(lockFile))
+		require.NoError(t, restarted.AcquireLease(ctx))
+
+		require.True(t, restarted.IsLeader())
+		require.False(t, paused.IsLeader())
+	})
+}
+
+func TestNewLeaderElectorWithConfig(t *testing.T) {
+	validConfig := func(dir string) leaderelection.Config {
+		return leaderelection.Config{
+			LeaseDuration: time.Second,
+			RetryPeriod:   100 * time.Millisecond,
+			LockName:      "group-a",
+			LockDir:       dir,
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*leaderelection.Config)
+		err    string
+	}{
+		{name: "zero lease duration", modify: func(c *leaderelection.Config) { c.LeaseDuration = 0 }, err: "lease duration must be greater than 0"},
+		{name: "negative renew deadline", modify: func(c *leaderelection.Config) { c.RenewDeadline = -time.Second }, err: "renew deadline cannot be negative"},
+		{name: "renew deadline before renewals start", modify: func(c *leaderelection.Config) { c.RenewDeadline = c.LeaseDuration / 2 }, err: "renew deadline must be greater than half of lease duration"},
+		{name: "renew deadline not less than lease duration", modify: func(c *leaderelection.Config) { c.RenewDeadline = c.LeaseDuration }, err: "renew deadline must be less than lease duration"},
+		{name: "zero retry period", modify: func(c *leaderelection.Config) { c.RetryPeriod = 0 }, err: "retry period must be greater than 0"},
+		{name: "retry period not less than lease duration", modify: func(c *leaderelection.Config) { c.RetryPeriod = c.LeaseDuration }, err: "retry period must be less than lease duration"},
+		{name: "empty lock name", modify: func(c *leaderelection.Config) { c.LockName = "" }, err: "lock name is required"},
+		{name: "empty lock dir", modify: func(c *leaderelection.Config) { c.LockDir = "" }, err: "lock dir is required"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig(t.TempDir())
+			tc.modify(&cfg)
+
+			le, err := leaderelection.NewLeaderElectorWithConfig("node-1", cfg)
+			require.Error(t, err)
+			require.Nil(t, le)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	t.Run("custom directory and lock name", func(t *testing.T) {
+		dir := t.TempDir()
+		le, err := leaderelection.NewLeaderElectorWithConfig("node-1", validConfig(dir))
+		require.NoError(t, err)
+
+		require.NoError(t, le.AcquireLease(context.Background()))
+
+		data, err := os.ReadFile(filepath.Join(dir, "group-a.lock"))
+		require.NoError(t, err)
+		require.Contains(t, string(data), "node-1:")
+	})
+
+	t.Run("separate lock names do not contend", func(t *testing.T) {
+		dir := t.TempDir()
+		groupA, err := leaderelection.NewLeaderElectorWithConfig("node-1", validConfig(dir))
+		require.NoError(t, err)
+
+		cfg := validConfig(dir)
+		cfg.LockName = "group-b"
+		groupB, err := leaderelection.NewLeaderElectorWithConfig("node-2", cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, groupA.AcquireLease(context.Background()))
+		require.NoError(t, groupB.AcquireLease(context.Background()))
+	})
+
+	t.Run("custom lease duration", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		dir := t.TempDir()
+		writeLease(t, filepath.Join(dir, "group-a.lock"), "node-0", fakeClock.Now())
+
+		// 3s later the lease is valid under the default lease duration but expired under a 1s lease
+		fakeClock.Advance(3 * time.Second)
+
+		cfg := validConfig(dir)
+		cfg.LeaseDuration = leaderelection.DefaultConfig().LeaseDuration
+		le, err := leaderelection.NewLeaderElectorWithConfig("node-1", cfg, leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		// A cancelled context limits AcquireLease to its first attempt
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, le.AcquireLease(ctx), context.Canceled)
+
+		le, err = leaderelection.NewLeaderElectorWithConfig("node-1", validConfig(dir), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+		require.NoError(t, le.AcquireLease(context.Background()))
+	})
+}
+
+// recorder collects leadership callback events in order
+type recorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *recorder) callbacks() leaderelection.LeaderCallbacks {
+	return leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(context.Context) { r.record("started") },
+		OnStoppedLeading: func() { r.record("stopped") },
+		OnNewLeader:      func(identity string) { r.record("new leader " + identity) },
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	t.Run("acquire", func(t *testing.T) {
+		rec := &recorder{}
+		le, err := leaderelection.NewLeaderElector("node-1",
+			leaderelection.WithLockFile(filepath.Join(t.TempDir(), "test.lock")),
+			leaderelection.WithCallbacks(rec.callbacks()),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, le.AcquireLease(context.Background()))
+		require.Equal(t, []string{"new leader node-1", "started"}, rec.get())
+	})
+
+	t.Run("follower observes leader changes", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		writeLease(t, lockFile, "node-0", fakeClock.Now())
+
+		rec := &recorder{}
+		le, err := leaderelection.NewLeaderElector("node-1",
+			leaderelection.WithLockFile(lockFile),
+			leaderelection.WithClock(fakeClock),
+			leaderelection.WithCallbacks(rec.callbacks()),
+		)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errChan := make(chan error)
+		go func() {
+			errChan <- le.AcquireLease(ctx)
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		require.Equal(t, []string{"new leader node-0"}, rec.get())
+
+		// A different node takes the lock
+		writeLease(t, lockFile, "node-2", fakeClock.Now())
+		fakeClock.Advance(leaderelection.DefaultConfig().RetryPeriod)
+
+		require.Eventually(t, func() bool {
+			return len(rec.get()) == 2
+		}, time.Second, time.Millisecond)
+		require.Equal(t, []string{"new leader node-0", "new leader node-2"}, rec.get())
+
+		cancel()
+		require.ErrorIs(t, <-errChan, context.Canceled)
+	})
+
+	t.Run("loss", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+
+		rec := &recorder{}
+		le, err := leaderelection.NewLeaderElector("node-1",
+			leaderelection.WithLockFile(lockFile),
+			leaderelection.WithClock(fakeClock),
+			leaderelection.WithCallbacks(rec.callbacks()),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, le.AcquireLease(ctx))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { rec.record("shutdown") })
+		}()
+
+		// Another node overwrites the lease
+		fakeClock.BlockUntilContext(ctx, 1)
+		writeLease(t, lockFile, "node-2", fakeClock.Now())
+		fakeClock.Advance(time.Second)
+
+		<-done
+		require.Equal(t, []string{"new leader node-1", "started", "new leader node-2", "stopped", "shutdown"}, rec.get())
+	})
+}
+
+// readFile returns the contents of the file at path
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRelease(t *testing.T) {
+	t.Run("owner release lets another node acquire immediately", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		node1, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+		node2, err := leaderelection.NewLeaderElector("node-2", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+
+		require.NoError(t, node1.AcquireLease(ctx))
+		require.NoError(t, node1.Release(ctx))
+		require.Equal(t, fmt.Sprintf("node-1:%d:0", node1.FencingToken()), readFile(t, lockFile))
+		require.False(t, node1.IsLeader())
+
+		// A cancelled context limits AcquireLease to its first attempt
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.NoError(t, node2.AcquireLease(cancelled))
+		require.True(t, node2.IsLeader())
+	})
+
+	t.Run("non-owner release is a no-op", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		node1, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+		node2, err := leaderelection.NewLeaderElector("node-2", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+
+		require.NoError(t, node1.AcquireLease(ctx))
+		require.NoError(t, node2.Release(ctx))
+		require.FileExists(t, lockFile)
+		require.True(t, node1.IsLeader())
+	})
+
+	t.Run("release without a lease is a no-op", func(t *testing.T) {
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(filepath.Join(t.TempDir(), "test.lock")))
+		require.NoError(t, err)
+
+		require.NoError(t, le.Release(context.Background()))
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+		require.NoError(t, le.AcquireLease(context.Background()))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, le.Release(ctx), context.Canceled)
+		require.FileExists(t, lockFile)
+	})
+
+	t.Run("losing the lease leaves the new owner's lock file", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, le.AcquireLease(ctx))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() {})
+		}()
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		writeLease(t, lockFile, "node-2", fakeClock.Now())
+		fakeClock.Advance(time.Second)
+		<-done
+
+		data, err := os.ReadFile(lockFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "node-2:")
+	})
+
+	t.Run("cancelling monitoring releases the lease", func(t *testing.T) {
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile))
+		require.NoError(t, err)
+		require.NoError(t, le.AcquireLease(context.Background()))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		le.MonitorLease(ctx, func() {})
+		require.Equal(t, "node-1:1:0", readFile(t, lockFile))
+		require.False(t, le.IsLeader())
+	})
+
+	t.Run("tombstone is acquirable before the lease would expire", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		var leaders []string
+		node1, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+		node2, err := leaderelection.NewLeaderElector("node-2",
+			leaderelection.WithLockFile(lockFile),
+			leaderelection.WithClock(fakeClock),
+			leaderelection.WithCallbacks(leaderelection.LeaderCallbacks{
+				OnNewLeader: func(identity string) { leaders = append(leaders, identity) },
+			}),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, node1.AcquireLease(ctx))
+		require.NoError(t, node1.Release(ctx))
+
+		// The clock never moves, so node-2 only gets in on its first attempt because the lease was released
+		acquired := make(chan error, 1)
+		go func() { acquired <- node2.AcquireLease(ctx) }()
+		select {
+		case err := <-acquired:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("released lease was not acquired without waiting a retry period")
+		}
+		require.True(t, node2.IsLeader())
+		require.False(t, node1.IsLeader())
+		require.Greater(t, node2.FencingToken(), node1.FencingToken())
+		// The tombstone is never reported as a leader
+		require.Equal(t, []string{"node-2"}, leaders)
+	})
+
+	t.Run("releasing and reacquiring issues a new token", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+		ctx := context.Background()
+
+		le, err := leaderelection.NewLeaderElector("node-1", leaderelection.WithLockFile(lockFile), leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		require.NoError(t, le.AcquireLease(ctx))
+		first := le.FencingToken()
+		require.NoError(t, le.Release(ctx))
+		require.NoError(t, le.AcquireLease(ctx))
+
+		require.True(t, le.IsLeader())
+		require.Greater(t, le.FencingToken(), first)
+		require.Equal(t, fmt.Sprintf("node-1:%d:%d", le.FencingToken(), fakeClock.Now().Unix()), readFile(t, lockFile))
+	})
+}
+
+func TestRenewDeadline(t *testing.T) {
+	// monitor acquires leadership and monitors it with renewals forced to fail, returning a channel closed on shutdown
+	monitor := func(t *testing.T, fakeClock *clockwork.FakeClock, cfg leaderelection.Config) (context.Context, chan struct{}) {
+		t.Helper()
+		cfg.LockDir = t.TempDir()
+		le, err := leaderelection.NewLeaderElectorWithConfig("node-1", cfg, leaderelection.WithClock(fakeClock))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, le.AcquireLease(ctx))
+
+		// Renewals write a temporary file first, a directory in its place makes them fail
+		lockFile := filepath.Join(cfg.LockDir, cfg.LockName+".lock")
+		require.NoError(t, os.Mkdir(lockFile+".tmp", 0755))
+
+		shutdown := make(chan struct{})
+		go le.MonitorLease(ctx, func() { close(shutdown) })
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		return ctx, shutdown
+	}
+
+	t.Run("shuts down at the deadline rather than at expiry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		cfg := leaderelection.DefaultConfig()
+		_, shutdown := monitor(t, fakeClock, cfg)
+
+		// Renewals have been failing since halfway through the lease, but the deadline hasn't passed
+		fakeClock.Advance(cfg.RenewDeadline - time.Second)
+		require.Never(t, func() bool {
+			select {
+			case <-shutdown:
+				return true
+			default:
+				return false
+			}
+		}, 50*time.Millisecond, 5*time.Millisecond)
+
+		// Past the deadline but still within the lease
+		fakeClock.Advance(2 * time.Second)
+
+		select {
+		case <-shutdown:
+		case <-time.After(time.Second):
+			t.Fatal("expected shutdown at the renew deadline")
+		}
+	})
+
+	t.Run("without a deadline leadership is held until expiry", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		cfg := leaderelection.DefaultConfig()
+		cfg.RenewDeadline = 0
+		ctx, shutdown := monitor(t, fakeClock, cfg)
+
+		fakeClock.Advance(cfg.LeaseDuration - time.Second)
+		require.Never(t, func() bool {
+			select {
+			case <-shutdown:
+				return true
+			default:
+				return false
+			}
+		}, 50*time.Millisecond, 5*time.Millisecond)
+
+		fakeClock.BlockUntilContext(ctx, 1)
+		fakeClock.Advance(2 * time.Second)
+
+		select {
+		case <-shutdown:
+		case <-time.After(time.Second):
+			t.Fatal("expected shutdown once the lease expired")
+		}
+	})
+}
@@ -0,0 +1,100 @@
+//go:build unix
+
+package leaderelection_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	leaderelection "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection/file"
+)
+
+// holdGuard takes the guard lock on lockFile, returning a function releasing it
+func holdGuard(t *testing.T, lockFile string) func() {
+	t.Helper()
+	guard, err := os.OpenFile(lockFile+".guard", os.O_CREATE|os.O_RDWR, 0644)
+	require.NoError(t, err)
+	require.NoError(t, syscall.Flock(int(guard.Fd()), syscall.LOCK_EX))
+
+	return func() {
+		syscall.Flock(int(guard.Fd()), syscall.LOCK_UN)
+		guard.Close()
+	}
+}
+
+// waitForGuardWaiter waits until another lock holder is blocked on lockFile's guard, using /proc/locks
+func waitForGuardWaiter(t *testing.T, lockFile string) {
+	t.Helper()
+	info, err := os.Stat(lockFile + ".guard")
+	require.NoError(t, err)
+	inode := fmt.Sprintf(":%d ", info.Sys().(*syscall.Stat_t).Ino)
+
+	if _, err := os.ReadFile("/proc/locks"); err != nil {
+		t.Skip("/proc/locks is unavailable")
+	}
+
+	require.Eventually(t, func() bool {
+		locks, err := os.ReadFile("/proc/locks")
+		require.NoError(t, err)
+		for _, line := range strings.Split(string(locks), "\n") {
+			if strings.Contains(line, "->") && strings.Contains(line, inode) {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+}
+
+func TestRenewOwnership(t *testing.T) {
+	t.Run("steps down instead of overwriting a lease taken over during renewal", func(t *testing.T) {
+		fakeClock := clockwork.NewFakeClock()
+		lockFile := filepath.Join(t.TempDir(), "test.lock")
+
+		rec := &recorder{}
+		le, err := leaderelection.NewLeaderElector("node-1",
+			leaderelection.WithLockFile(lockFile),
+			leaderelection.WithClock(fakeClock),
+			leaderelection.WithCallbacks(rec.callbacks()),
+		)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, le.AcquireLease(ctx))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { rec.record("shutdown") })
+		}()
+		fakeClock.BlockUntilContext(ctx, 1)
+
+		// Block the renewal once our lease has passed its halfway point, then hand the lease to another node
+		release := holdGuard(t, lockFile)
+		fakeClock.Advance(leaderelection.DefaultConfig().LeaseDuration/2 + time.Second)
+		waitForGuardWaiter(t, lockFile)
+
+		writeLease(t, lockFile, "node-2", fakeClock.Now())
+		release()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected monitoring to stop once the lease was taken over")
+		}
+
+		data, err := os.ReadFile(lockFile)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "node-2:")
+		require.False(t, le.IsLeader())
+		require.Equal(t, []string{"new leader node-1", "started", "new leader node-2", "stopped", "shutdown"}, rec.get())
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"k8s.io/client-go/tools/leaderelection"
 	rl "k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
+
+	election "github.com/cshep4/resiliency-patterns/high-availability/leader-election/internal/leaderelection"
 )
 
 const (
@@ -20,25 +22,110 @@ const (
 	lockName = "leader-election-demo"
 )
 
+// Ensure leaderElector satisfies the shared interface
+var _ election.LeaderElector = (*leaderElector)(nil)
+
+// runner runs Kubernetes leader election until leadership is lost or the context is done
+type runner interface {
+	Run(ctx context.Context)
+}
+
 type leaderElector struct {
 	// identity is the unique identifier for this node
 	identity string
 	// lockNamespace is the namespace where the lock is created
 	lockNamespace string
 	// leaderElector is the Kubernetes leader election instance
-	elector *leaderelection.LeaderElector
+	elector runner
+	// callbacks are notified of leadership changes
+	callbacks LeaderCallbacks
 
-	// leadershipLost is a channel to signal when leadership is lost
+	// leadershipLost is a channel to signal when leadership is lost, buffered so signalling never blocks
 	leadershipLost chan struct{}
-	// leadershipGained is a channel to signal when leadership is gained
+	// leadershipGained is a channel to signal when leadership is gained, buffered so signalling never blocks
 	leadershipGained chan struct{}
 }
 
-func NewLeaderElector(nodeID, lockNamespace string) (*leaderElector, error) {
+// LeaderCallbacks are notified of leadership changes, any of them may be nil
+type LeaderCallbacks struct {
+	// OnDemotedToFollower enables passive mode. Instead of MonitorLease calling onShutdown when leadership is lost,
+	// it calls OnDemotedToFollower and keeps running as a read-only follower, competing for leadership again.
+	OnDemotedToFollower func()
+	// OnPromotedToLeader is called by MonitorLease when a follower in passive mode wins leadership back
+	OnPromotedToLeader func()
+}
+
+// Option is a functional option for configuring the leader elector
+type Option func(*leaderElector) error
+
+// WithCallbacks sets the callbacks notified of leadership changes
+func WithCallbacks(callbacks LeaderCallbacks) Option {
+	return func(le *leaderElector) error {
+		le.callbacks = callbacks
+		return nil
+	}
+}
+
+// NewLeaderElector creates a new leaderElector competing for a Kubernetes Lease in lockNamespace
+func NewLeaderElector(nodeID, lockNamespace string, opts ...Option) (*leaderElector, error) {
+	return newLeaderElector(nodeID, lockNamespace, newKubernetesElector, opts...)
+}
+
+// newLeaderElector creates a new leaderElector running leader election with the runner returned by newRunner
+func newLeaderElector(nodeID, lockNamespace string, newRunner func(nodeID, lockNamespace string, callbacks leaderelection.LeaderCallbacks) (runner, error), opts ...Option) (*leaderElector, error) {
 	if nodeID == "" {
 		return nil, fmt.Errorf("nodeID is required")
 	}
 
+	le := &leaderElector{
+		identity:         nodeID,
+		lockNamespace:    lockNamespace,
+		leadershipLost:   make(chan struct{}, 1),
+		leadershipGained: make(chan struct{}, 1),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		if err := opt(le); err != nil {
+			return nil, err
+		}
+	}
+
+	elector, err := newRunner(nodeID, lockNamespace, leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			log.Printf(" [%s] BECAME LEADER - Starting leadership duties", nodeID)
+			select {
+			case le.leadershipGained <- struct{}{}:
+			case <-ctx.Done():
+			}
+		},
+		OnStoppedLeading: func() {
+			log.Printf("🚨 [%s] LEADERSHIP LOST - Stopping leadership duties", nodeID)
+			// Nothing may be monitoring any more once the context is cancelled, so don't wait for a receiver.
+			// A signal already pending is enough for MonitorLease to notice the loss.
+			select {
+			case le.leadershipLost <- struct{}{}:
+			default:
+			}
+		},
+		OnNewLeader: func(identity string) {
+			if identity != nodeID && le.callbacks.OnDemotedToFollower != nil {
+				log.Printf("👥 [%s] New leader elected: %s, following as read-only", nodeID, identity)
+				return
+			}
+			log.Printf("👥 [%s] New leader elected: %s", nodeID, identity)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	le.elector = elector
+
+	return le, nil
+}
+
+// newKubernetesElector creates a client-go leader elector competing for a Lease in lockNamespace
+func newKubernetesElector(nodeID, lockNamespace string, callbacks leaderelection.LeaderCallbacks) (runner, error) {
 	// Get the active kubernetes context
 	cfg, err := ctrl.GetConfig()
 	if err != nil {
@@ -60,9 +147,6 @@ func NewLeaderElector(nodeID, lockNamespace string) (*leaderElector, error) {
 		return nil, fmt.Errorf("failed to create resource lock: %w", err)
 	}
 
-	leadershipLost := make(chan struct{})
-	leadershipGained := make(chan struct{})
-
 	// Create a new leader election configuration
 	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
 		Lock:          l,
@@ -70,31 +154,13 @@ func NewLeaderElector(nodeID, lockNamespace string) (*leaderElector, error) {
 		RenewDeadline: leaseDuration / 2,
 		RetryPeriod:   retryPeriod,
 		Name:          lockName,
-		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: func(ctx context.Context) {
-				log.Printf(" [%s] BECAME LEADER - Starting leadership duties", nodeID)
-				leadershipGained <- struct{}{}
-			},
-			OnStoppedLeading: func() {
-				log.Printf("🚨 [%s] LEADERSHIP LOST - Stopping leadership duties", nodeID)
-				leadershipLost <- struct{}{}
-			},
-			OnNewLeader: func(identity string) {
-				log.Printf("👥 [%s] New leader elected: %s", nodeID, identity)
-			},
-		},
+		Callbacks:     callbacks,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create leader elector: %w", err)
 	}
 
-	return &leaderElector{
-		identity:         nodeID,
-		lockNamespace:    lockNamespace,
-		elector:          elector,
-		leadershipLost:   leadershipLost,
-		leadershipGained: leadershipGained,
-	}, nil
+	return elector, nil
 }
 
 // AcquireLease attempts to acquire leadership
@@ -119,16 +185,39 @@ func (le *leaderElector) AcquireLease(ctx context.Context) error {
 }
 
 // MonitorLease continuously monitors the leadership status and renews the lease
-// Calls onShutdown if leadership is lost and cleans up the lock
+// Calls onShutdown if leadership is lost and cleans up the lock. In passive mode it instead calls
+// OnDemotedToFollower and competes for leadership again, blocking until the context is cancelled.
 func (le *leaderElector) MonitorLease(ctx context.Context, onShutdown func()) {
 	log.Printf("[%s] Starting lease monitoring...", le.identity)
 
-	// Monitor for leadership loss
-	select {
-	case <-le.leadershipLost:
-		log.Printf("🛑 [%s] Leadership lost, calling shutdown callback", le.identity)
-		onShutdown()
-	case <-ctx.Done():
-		log.Printf("[%s] Context cancelled, stopping lease monitoring", le.identity)
+	for {
+		// Monitor for leadership loss
+		select {
+		case <-le.leadershipLost:
+			if le.callbacks.OnDemotedToFollower == nil {
+				log.Printf("🛑 [%s] Leadership lost, calling shutdown callback", le.identity)
+				onShutdown()
+				return
+			}
+			if ctx.Err() != nil {
+				// Leadership was given up because monitoring stopped, not lost to another node
+				log.Printf("[%s] Context cancelled, stopping lease monitoring", le.identity)
+				return
+			}
+
+			log.Printf("⬇️ [%s] Leadership lost, continuing as a read-only follower", le.identity)
+			le.callbacks.OnDemotedToFollower()
+
+			// The previous run returned when leadership was lost, so start competing again
+			go le.elector.Run(ctx)
+		case <-le.leadershipGained:
+			log.Printf("👑 [%s] Leadership regained", le.identity)
+			if le.callbacks.OnPromotedToLeader != nil {
+				le.callbacks.OnPromotedToLeader()
+			}
+		case <-ctx.Done():
+			log.Printf("[%s] Context cancelled, stopping lease monitoring", le.identity)
+			return
+		}
 	}
 }
@@ -0,0 +1,182 @@
+package leaderelection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/leaderelection"
+)
+
+// fakeRunner records every run of leader election, letting the test drive the callbacks instead of a cluster
+type fakeRunner struct {
+	callbacks leaderelection.LeaderCallbacks
+	runs      chan struct{}
+}
+
+func (f *fakeRunner) Run(context.Context) {
+	f.runs <- struct{}{}
+}
+
+// newFakeLeaderElector creates a leaderElector running leader election with a fakeRunner
+func newFakeLeaderElector(t *testing.T, opts ...Option) (*leaderElector, *fakeRunner) {
+	t.Helper()
+	fake := &fakeRunner{runs: make(chan struct{}, 10)}
+	le, err := newLeaderElector("node-1", "default", func(_, _ string, callbacks leaderelection.LeaderCallbacks) (runner, error) {
+		fake.callbacks = callbacks
+		return fake, nil
+	}, opts...)
+	require.NoError(t, err)
+	return le, fake
+}
+
+// acquire makes le the leader, as if it won the election
+func acquire(t *testing.T, ctx context.Context, le *leaderElector, fake *fakeRunner) {
+	t.Helper()
+	acquired := make(chan error)
+	go func() { acquired <- le.AcquireLease(ctx) }()
+	<-fake.runs
+	fake.callbacks.OnStartedLeading(ctx)
+	require.NoError(t, <-acquired)
+}
+
+func TestNewLeaderElector(t *testing.T) {
+	t.Run("empty node ID", func(t *testing.T) {
+		le, err := NewLeaderElector("", "default")
+		require.Error(t, err)
+		require.Nil(t, le)
+		require.Contains(t, err.Error(), "nodeID is required")
+	})
+}
+
+func TestMonitorLease(t *testing.T) {
+	t.Run("losing leadership calls onShutdown", func(t *testing.T) {
+		le, fake := newFakeLeaderElector(t)
+		ctx := context.Background()
+		acquire(t, ctx, le, fake)
+
+		shutdown := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { close(shutdown) })
+		}()
+
+		fake.callbacks.OnStoppedLeading()
+		<-shutdown
+		<-done
+		require.Empty(t, fake.runs)
+	})
+
+	t.Run("passive mode demotes to follower without shutting down", func(t *testing.T) {
+		demoted := make(chan struct{}, 1)
+		le, fake := newFakeLeaderElector(t, WithCallbacks(LeaderCallbacks{
+			OnDemotedToFollower: func() { demoted <- struct{}{} },
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		acquire(t, ctx, le, fake)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { t.Error("onShutdown called in passive mode") })
+		}()
+
+		fake.callbacks.OnNewLeader("node-2")
+		fake.callbacks.OnStoppedLeading()
+		<-demoted
+
+		// The node competes for leadership again and keeps monitoring
+		<-fake.runs
+		select {
+		case <-done:
+			t.Fatal("MonitorLease returned after demotion")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		// Regaining leadership and losing it again demotes the node again
+		fake.callbacks.OnStartedLeading(ctx)
+		fake.callbacks.OnStoppedLeading()
+		<-demoted
+		<-fake.runs
+
+		cancel()
+		<-done
+	})
+
+	t.Run("passive mode promotes a demoted follower that is re-elected", func(t *testing.T) {
+		demoted := make(chan struct{}, 1)
+		promoted := make(chan struct{}, 1)
+		le, fake := newFakeLeaderElector(t, WithCallbacks(LeaderCallbacks{
+			OnDemotedToFollower: func() { demoted <- struct{}{} },
+			OnPromotedToLeader:  func() { promoted <- struct{}{} },
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		acquire(t, ctx, le, fake)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { t.Error("onShutdown called in passive mode") })
+		}()
+
+		fake.callbacks.OnStoppedLeading()
+		<-demoted
+		<-fake.runs
+		require.Empty(t, promoted)
+
+		fake.callbacks.OnStartedLeading(ctx)
+		<-promoted
+
+		cancel()
+		<-done
+	})
+
+	t.Run("losing leadership after monitoring stopped doesn't block", func(t *testing.T) {
+		le, fake := newFakeLeaderElector(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		acquire(t, ctx, le, fake)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() {})
+		}()
+		cancel()
+		<-done
+
+		// client-go reports leadership lost, possibly more than once, after the context is cancelled
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			fake.callbacks.OnStoppedLeading()
+			fake.callbacks.OnStoppedLeading()
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("OnStoppedLeading blocked with nothing monitoring the lease")
+		}
+	})
+
+	t.Run("passive mode stops when the context is cancelled", func(t *testing.T) {
+		le, fake := newFakeLeaderElector(t, WithCallbacks(LeaderCallbacks{
+			OnDemotedToFollower: func() { t.Error("demoted after the context was cancelled") },
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		acquire(t, ctx, le, fake)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			le.MonitorLease(ctx, func() { t.Error("onShutdown called in passive mode") })
+		}()
+
+		cancel()
+		<-done
+		require.Empty(t, fake.runs)
+	})
+}
@@ -0,0 +1,13 @@
+// Package leaderelection defines the contract shared by every leader election backend
+// found in its subpackages.
+package leaderelection
+
+import "context"
+
+// LeaderElector is implemented by each leader election backend
+type LeaderElector interface {
+	// AcquireLease blocks until leadership is acquired or the context is cancelled
+	AcquireLease(ctx context.Context) error
+	// MonitorLease blocks while leadership is held, calling onShutdown if it is lost
+	MonitorLease(ctx context.Context, onShutdown func())
+}